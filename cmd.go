@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/logger"
+	"web-app/logic"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+// command 运维子命令，flags 注册子命令的参数，可以为 nil
+type command struct {
+	flags func(fs *flag.FlagSet)
+	run   func() error
+}
+
+// commands 运维子命令，用法：bluebell <command> [flags] [config.yaml]
+var commands = map[string]command{
+	"rebuild-bloom": {run: logic.RebuildBloomFilters},              // 按当前配置从 MySQL 重建布隆过滤器
+	"rebuild-redis": {flags: rebuildRedisFlags, run: rebuildRedis}, // 从 MySQL 重建排行榜、社区集合、投票记录和布隆过滤器
+	"verify":        {flags: verifyFlags, run: verify},             // 检查 MySQL 和 Redis 排行榜、社区集合是否一致
+
+	"backfill-user-posts": {flags: batchSizeFlag, run: backfillUserPosts}, // 补上关注动态上线之前作者的帖子列表
+	"backfill-views":      {flags: batchSizeFlag, run: backfillViews},     // 补上浏览量统计上线之前的帖子的浏览量排行
+	"export-votes":        {flags: batchSizeFlag, run: exportVotes},       // 把只保存在 Redis 中的旧投票写入 MySQL
+}
+
+var batchSize int
+
+func batchSizeFlag(fs *flag.FlagSet) {
+	fs.IntVar(&batchSize, "batch-size", 500, "每批从 MySQL 读取的帖子数")
+}
+
+// backfillUserPosts 只补写 user:posts，不影响排行榜，可以在线执行
+func backfillUserPosts() error {
+	count, err := logic.BackfillUserPosts(batchSize)
+	fmt.Printf("backfilled %d posts\n", count)
+	return err
+}
+
+// backfillViews 只补写 post:views 中缺少的帖子，已有的浏览量不修改，可以在线执行
+func backfillViews() error {
+	count, err := logic.BackfillPostViews(batchSize)
+	fmt.Printf("backfilled %d posts\n", count)
+	return err
+}
+
+// exportVotes 只写入 MySQL，不修改 Redis，可以在线执行，需要在第一次 rebuild-redis 之前执行
+func exportVotes() error {
+	posts, votes, err := logic.ExportVotes(batchSize)
+	fmt.Printf("exported %d votes of %d posts\n", votes, posts)
+	return err
+}
+
+var rebuildRedisOpts logic.RebuildRedisOptions
+
+func rebuildRedisFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&rebuildRedisOpts.DryRun, "dry-run", false, "只统计需要写入的数据，不修改 Redis")
+	fs.IntVar(&rebuildRedisOpts.BatchSize, "batch-size", 500, "每批从 MySQL 读取的帖子数")
+}
+
+// rebuildRedis Redis 数据丢失后从 MySQL 重建，建议在停止写入（投票、发帖）时执行
+func rebuildRedis() error {
+	rebuildRedisOpts.Progress = func(p logic.RebuildRedisResult) {
+		percent := 100.0
+		if p.Total > 0 {
+			percent = float64(p.Posts) / float64(p.Total) * 100
+		}
+		fmt.Printf("\rrebuild-redis: %d/%d posts (%.1f%%), %d votes", p.Posts, p.Total, percent, p.Votes)
+	}
+	result, err := logic.RebuildRedis(rebuildRedisOpts)
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+	if rebuildRedisOpts.DryRun {
+		fmt.Println("dry run, redis not modified")
+	}
+	fmt.Printf("published: %d, pending review: %d, votes: %d, communities: %d, authors: %d, catch up: %d\n",
+		result.Published, result.Pending, result.Votes, result.Communities, result.Authors, result.CatchUp)
+	if rebuildRedisOpts.DryRun {
+		return nil
+	}
+	// 布隆过滤器也保存在 Redis 中，数据丢失后一起重建，否则要等各个实例发现之后才会重建
+	if err := logic.RebuildBloomFilters(); err != nil && !errors.Is(err, logic.ErrorBloomDisabled) {
+		return err
+	}
+	return nil
+}
+
+var verifyOpts logic.VerifyOptions
+
+func verifyFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&verifyOpts.Fix, "fix", false, "修复发现的问题")
+	fs.IntVar(&verifyOpts.BatchSize, "batch-size", 500, "每批检查的帖子数")
+}
+
+// verify 输出不一致的数据，没有 -fix 且发现问题时返回错误，可以用退出码做定时检查
+func verify() error {
+	report, err := logic.VerifyRedis(verifyOpts)
+	if err != nil {
+		return err
+	}
+	for _, issue := range report.Issues {
+		fmt.Printf("%-10s %-16s %-20s %s\n", issue.Type, issue.Key, issue.PostID, issue.Detail)
+	}
+	if report.Truncated {
+		fmt.Println("...")
+	}
+	fmt.Printf("posts: %d, missing: %d, orphaned: %d, mismatched: %d\n",
+		report.Posts, report.Missing, report.Orphaned, report.Mismatched)
+	issues := report.Missing + report.Orphaned + report.Mismatched
+	if report.Fixed {
+		fmt.Printf("fixed %d issues\n", issues)
+		return nil
+	}
+	if issues > 0 {
+		return fmt.Errorf("found %d issues, run with -fix to repair", issues)
+	}
+	return nil
+}
+
+// runCommand 加载配置、初始化日志和数据库连接后执行子命令，返回进程的退出码
+func runCommand(name string, args []string) int {
+	cmd := commands[name]
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	configFile := fs.Arg(0)
+	if err := settings.Init(configFile); err != nil {
+		fmt.Printf("settings.Init() failed, err: %v \n", err)
+		return 1
+	}
+	if err := logger.Init(settings.Conf.LogConfig, settings.Conf.Mode); err != nil {
+		fmt.Printf("logger.Init() failed, err: %v \n", err)
+		return 1
+	}
+	defer zap.L().Sync()
+	if err := mysql.Init(settings.Conf.MySQLConfig); err != nil {
+		fmt.Printf("mysql.Init() failed, err: %v \n", err)
+		return 1
+	}
+	defer mysql.Close()
+	if err := redis.Init(settings.Conf.RedisConfig); err != nil {
+		fmt.Printf("redis.Init() failed, err: %v \n", err)
+		return 1
+	}
+	defer redis.Close()
+
+	start := time.Now()
+	if err := cmd.run(); err != nil {
+		fmt.Printf("%s failed, err: %v \n", name, err)
+		return 1
+	}
+	fmt.Printf("%s completed in %s\n", name, time.Since(start).Round(time.Millisecond))
+	return 0
+}
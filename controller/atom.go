@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/logic"
+	"web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// --- Atom 订阅源 ---
+// 订阅源、站点地图给阅读器和爬虫使用，出错时直接返回 HTTP 状态码，不使用统一的 JSON 响应
+
+// SiteAtomFeedHandler 全站最新帖子的订阅源 GET /feeds/all.atom
+func SiteAtomFeedHandler(c *gin.Context) {
+	feed, err := logic.GetSiteAtomFeed()
+	writeAtomFeed(c, feed, err)
+}
+
+// CommunityAtomFeedHandler 社区最新帖子的订阅源 GET /feeds/community/:id.atom
+func CommunityAtomFeedHandler(c *gin.Context) {
+	communityID, ok := parseIDWithSuffix(c, "id", ".atom")
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	feed, err := logic.GetCommunityAtomFeed(communityID)
+	writeAtomFeed(c, feed, err)
+}
+
+// UserAtomFeedHandler 作者最新帖子的订阅源 GET /feeds/user/:id.atom
+func UserAtomFeedHandler(c *gin.Context) {
+	userID, ok := parseIDWithSuffix(c, "id", ".atom")
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	feed, err := logic.GetUserAtomFeed(userID)
+	writeAtomFeed(c, feed, err)
+}
+
+// parseIDWithSuffix gin 的路径参数不能带后缀，路由注册为 :id，这里去掉 .atom/.xml 等后缀
+func parseIDWithSuffix(c *gin.Context, key, suffix string) (int64, bool) {
+	param, ok := strings.CutSuffix(c.Param(key), suffix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(param, 10, 64)
+	return id, err == nil
+}
+
+// writeAtomFeed 输出订阅源
+func writeAtomFeed(c *gin.Context, feed *models.AtomFeed, err error) {
+	if err != nil {
+		responseXMLError(c, err)
+		return
+	}
+	serveXML(c, "application/atom+xml; charset=utf-8", feed, feed.Updated)
+}
+
+// responseXMLError 订阅源、站点地图出错时返回对应的 HTTP 状态码
+func responseXMLError(c *gin.Context, err error) {
+	if errors.Is(err, mysql.ErrorInvalidID) || errors.Is(err, mysql.ErrorUserNotExist) ||
+		errors.Is(err, logic.ErrorSitemapNotExist) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	zap.L().Error("get xml document failed", zap.String("path", c.Request.URL.Path), zap.Error(err))
+	c.Status(http.StatusInternalServerError)
+}
+
+// serveXML 输出 XML 文档，支持 ETag/Last-Modified 条件请求
+// 内容没有变化时返回 304，阅读器和爬虫不用重复下载
+func serveXML(c *gin.Context, contentType string, v interface{}, modTime time.Time) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		zap.L().Error("xml.Marshal() failed", zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	body = append([]byte(xml.Header), body...)
+
+	sum := sha1.Sum(body)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(logic.FeedMaxAge().Seconds())))
+	c.Header("Content-Type", contentType)
+	// ServeContent 会处理 If-None-Match 和 If-Modified-Since，modTime 为零值时不设置 Last-Modified
+	http.ServeContent(c.Writer, c.Request, "", modTime, bytes.NewReader(body))
+}
@@ -14,6 +14,13 @@ const(
 	CodeNeedLogin
 	CodeInvalidToken
 
+	CodeContentRejected
+	CodeDuplicateContent
+	CodePostTooFrequent
+	CodeNoPermission
+	CodeDuplicateLink
+	CodeCommunityRule
+
 )
 
 var codeMsgMap = map[ResCode]string{
@@ -25,6 +32,13 @@ var codeMsgMap = map[ResCode]string{
 	CodeServerBusy: 	 "服务器繁忙",
 	CodeInvalidToken:     "无效的Token",
 	CodeNeedLogin:       "需要登录",
+
+	CodeContentRejected:  "内容包含违禁词",
+	CodeDuplicateContent: "请勿重复发布相同内容",
+	CodePostTooFrequent:  "发帖过于频繁，请稍后再试",
+	CodeNoPermission:     "无权操作",
+	CodeDuplicateLink:    "该链接已经发布过",
+	CodeCommunityRule:    "不符合社区的发帖规则",
 }
 
 func (c ResCode) Msg() string{                  // 接收者是 ResCode 类型  相当于绑定到这个类型作成员函数
@@ -2,11 +2,16 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"time"
 	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/logic"
+	"web-app/models"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // GetDBStatsHandler 获取数据库连接池统计信息
@@ -133,6 +138,15 @@ func GetDBHealthHandler(c *gin.Context) {
 		}
 	}
 
+	// Redis 不可用时服务降级运行：帖子列表从 MySQL 查询，投票写入本地队列，仍然返回200
+	redisErr := redis.Ping()
+	if redisErr != nil {
+		healthStatus = "degraded"
+		warnings = append(warnings, "Redis 不可用，服务降级运行")
+	} else if logic.VoteQueueLen() > 0 {
+		warnings = append(warnings, "有投票等待重放")
+	}
+
 	statusCode := http.StatusOK
 	if healthStatus == "warning" {
 		statusCode = http.StatusOK // 警告状态仍返回200，但在响应体中标明
@@ -143,6 +157,11 @@ func GetDBHealthHandler(c *gin.Context) {
 		"timestamp": time.Now().Format("2006-01-02 15:04:05"),
 		"checks": gin.H{
 			"database_ping": true,
+			"redis_ping":    redisErr == nil,
+		},
+		"redis": gin.H{
+			"breaker":    redis.BreakerStats(),
+			"vote_queue": logic.VoteQueueStats(),
 		},
 	}
 
@@ -242,3 +261,32 @@ func OptimizeDBPoolHandler(c *gin.Context) {
 		},
 	})
 }
+
+// VerifyRedisHandler 检查 MySQL 和 Redis 是否一致（管理员）
+// @Summary      检查 Redis 排行榜一致性
+// @Description  对比 MySQL 中的帖子和 Redis 中的 post:time、post:score、社区集合，列出缺少、多余和不一致的数据，fix=true 时同时修复，仅管理员可用
+// @Tags         运维
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        fix         query     bool    false  "是否修复"
+// @Param        batch_size  query     int     false  "每批检查的帖子数"  default(500)
+// @Success      200         {object}  ResponseData{data=logic.VerifyReport}
+// @Router       /admin/redis/verify [post]
+func VerifyRedisHandler(c *gin.Context) {
+	p := &models.ParamsVerify{BatchSize: 500}
+	if err := c.ShouldBindQuery(p); err != nil || p.BatchSize < 1 || p.BatchSize > 5000 {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	report, err := logic.VerifyRedis(logic.VerifyOptions{BatchSize: p.BatchSize, Fix: p.Fix})
+	if errors.Is(err, logic.ErrorRebuildRunning) {
+		ResponseErrorWithMsg(c, CodeServerBusy, err.Error())
+		return
+	}
+	if err != nil {
+		zap.L().Error("logic.VerifyRedis() failed", zap.Bool("fix", p.Fix), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, report)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"web-app/dao/mysql"
+	"web-app/logic"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// --- 关注及关注动态 ---
+
+// FollowHandler 关注用户
+// @Summary      关注用户
+// @Description  关注指定用户，重复关注不会报错
+// @Tags         用户
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "被关注的用户ID"
+// @Success      200  {object}  ResponseData
+// @Router       /users/{id}/follow [post]
+func FollowHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	followerID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	if err := logic.Follow(userID, followerID); err != nil {
+		zap.L().Error("logic.Follow() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("follower_id", followerID),
+			zap.Error(err))
+		switch {
+		case errors.Is(err, logic.ErrorFollowSelf):
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		case errors.Is(err, mysql.ErrorUserNotExist):
+			ResponseError(c, CodeUserNotExist)
+		default:
+			ResponseError(c, CodeServerBusy)
+		}
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// UnfollowHandler 取消关注
+// @Summary      取消关注
+// @Description  取消关注指定用户
+// @Tags         用户
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "被关注的用户ID"
+// @Success      200  {object}  ResponseData
+// @Router       /users/{id}/follow [delete]
+func UnfollowHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	followerID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	if err := logic.Unfollow(userID, followerID); err != nil {
+		zap.L().Error("logic.Unfollow() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("follower_id", followerID),
+			zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// GetFollowersHandler 粉丝列表
+// @Summary      粉丝列表
+// @Description  分页获取用户的粉丝列表及粉丝数
+// @Tags         用户
+// @Produce      json
+// @Param        id    path      int  true   "用户ID"
+// @Param        page  query     int  false  "页码"  default(1)
+// @Param        size  query     int  false  "条数"  default(10)
+// @Success      200   {object}  ResponseData{data=models.ApiFollowList}
+// @Router       /users/{id}/followers [get]
+func GetFollowersHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	page, size := getPageInfo(c)
+
+	data, err := logic.GetFollowers(userID, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetFollowers() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// GetFollowingHandler 关注列表
+// @Summary      关注列表
+// @Description  分页获取用户关注的人及关注数
+// @Tags         用户
+// @Produce      json
+// @Param        id    path      int  true   "用户ID"
+// @Param        page  query     int  false  "页码"  default(1)
+// @Param        size  query     int  false  "条数"  default(10)
+// @Success      200   {object}  ResponseData{data=models.ApiFollowList}
+// @Router       /users/{id}/following [get]
+func GetFollowingHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	page, size := getPageInfo(c)
+
+	data, err := logic.GetFollowing(userID, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetFollowing() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// GetFollowingFeedHandler 关注动态
+// @Summary      关注动态
+// @Description  获取关注的作者最近发布的帖子
+// @Tags         帖子
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page  query     int  false  "页码"  default(1)
+// @Param        size  query     int  false  "条数"  default(10)
+// @Success      200   {object}  ResponseData{data=[]models.ApiPostDetail}
+// @Router       /feed/following [get]
+func GetFollowingFeedHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	page, size := getPageInfo(c)
+
+	data, err := logic.GetFollowingFeed(userID, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetFollowingFeed() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, withViewerState(c, data))
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"errors"
+	"web-app/logic"
+	"web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// --- 链接帖子 ---
+
+// CheckLinkHandler 检查链接是否发布过
+// @Summary      检查链接
+// @Description  发帖前检查链接在哪些社区发布过，同一个社区不能重复发布同一个链接
+// @Tags         帖子
+// @Produce      json
+// @Param        url  query     string  true  "链接地址"
+// @Success      200  {object}  ResponseData{data=models.ApiLinkCheck}
+// @Router       /link/check [get]
+func CheckLinkHandler(c *gin.Context) {
+	p := new(models.ParamsLinkCheck)
+	if err := c.ShouldBindQuery(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.CheckLink(p.URL)
+	if err != nil {
+		if errors.Is(err, logic.ErrorInvalidLink) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("logic.CheckLink() failed", zap.String("url", p.URL), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"web-app/logic"
+	"web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// --- 站内通知 ---
+
+// GetNotificationsHandler 通知列表
+// @Summary      通知列表
+// @Description  按游标分页获取当前用户的通知，同时返回未读数
+// @Tags         通知
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        cursor  query     string  false  "上一页返回的 next_cursor"
+// @Param        size    query     int     false  "条数"  default(20)
+// @Success      200     {object}  ResponseData{data=models.ApiNotificationList}
+// @Router       /notifications [get]
+func GetNotificationsHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := &models.ParamsNotificationList{Size: 20}
+	if err := c.ShouldBindQuery(p); err != nil || p.Size < 1 || p.Size > 100 {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+
+	data, err := logic.GetNotifications(userID, p)
+	if err != nil {
+		zap.L().Error("logic.GetNotifications() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// GetUnreadCountHandler 未读通知数
+// @Summary      未读通知数
+// @Tags         通知
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  ResponseData
+// @Router       /notifications/unread [get]
+func GetUnreadCountHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	count, err := logic.GetUnreadCount(userID)
+	if err != nil {
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, gin.H{"unread": count})
+}
+
+// MarkNotificationReadHandler 标记通知已读
+// @Summary      标记通知已读
+// @Tags         通知
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      string  true  "通知ID"
+// @Success      200  {object}  ResponseData
+// @Router       /notifications/{id}/read [post]
+func MarkNotificationReadHandler(c *gin.Context) {
+	notificationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	if err := logic.MarkNotificationRead(userID, notificationID); err != nil {
+		if errors.Is(err, logic.ErrorNotificationNotExist) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// MarkAllNotificationsReadHandler 全部标记为已读
+// @Summary      全部标记为已读
+// @Tags         通知
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  ResponseData
+// @Router       /notifications/read_all [post]
+func MarkAllNotificationsReadHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	if err := logic.MarkAllNotificationsRead(userID); err != nil {
+		zap.L().Error("logic.MarkAllNotificationsRead() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// GetNotificationSettingHandler 获取通知偏好
+// @Summary      获取通知偏好
+// @Tags         通知
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  ResponseData{data=models.NotificationSetting}
+// @Router       /notifications/settings [get]
+func GetNotificationSettingHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	data, err := logic.GetNotificationSetting(userID)
+	if err != nil {
+		zap.L().Error("logic.GetNotificationSetting() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// UpdateNotificationSettingHandler 更新通知偏好
+// @Summary      更新通知偏好
+// @Tags         通知
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        body  body      models.NotificationSetting  true  "通知偏好"
+// @Success      200   {object}  ResponseData
+// @Router       /notifications/settings [put]
+func UpdateNotificationSettingHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := new(models.NotificationSetting)
+	if err := c.ShouldBindJSON(p); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.UpdateNotificationSetting(userID, p); err != nil {
+		zap.L().Error("logic.UpdateNotificationSetting() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
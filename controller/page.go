@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/logic"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// --- 服务端渲染页面 ---
+// 首页是单页应用，爬虫和链接预览拿不到内容，帖子页面和站点地图由服务端生成
+
+// PostPageHandler 帖子页面 GET /p/:id/:slug
+// slug 和标题不一致（改过标题或者没有带 slug）时 301 跳转到规范地址
+func PostPageHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	page, err := logic.GetPostPage(postID)
+	if err != nil {
+		if errors.Is(err, mysql.ErrorInvalidID) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		zap.L().Error("logic.GetPostPage() failed", zap.Int64("post_id", postID), zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if c.Request.URL.EscapedPath() != page.Path {
+		c.Redirect(http.StatusMovedPermanently, page.Path)
+		return
+	}
+	logic.RecordPostView(page.Detail.Post, getVisitorID(c))
+	c.HTML(http.StatusOK, "post.html", page)
+}
+
+// SitemapIndexHandler 站点地图索引 GET /sitemap.xml
+func SitemapIndexHandler(c *gin.Context) {
+	index, err := logic.GetSitemapIndex()
+	if err != nil {
+		responseXMLError(c, err)
+		return
+	}
+	serveXML(c, "application/xml; charset=utf-8", index, time.Time{})
+}
+
+// SitemapHandler 一页站点地图 GET /sitemaps/posts/:page.xml
+func SitemapHandler(c *gin.Context) {
+	page, ok := parseIDWithSuffix(c, "page", ".xml")
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	set, lastMod, err := logic.GetSitemap(page)
+	if err != nil {
+		responseXMLError(c, err)
+		return
+	}
+	serveXML(c, "application/xml; charset=utf-8", set, lastMod)
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"web-app/logic"
+	"web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.uber.org/zap"
+)
+
+// --- 帖子投票 ---
+
+// GetPollHandler 获取帖子的投票
+// @Summary      帖子的投票
+// @Description  获取帖子附带的投票，投过票或者投票截止后才返回各选项的票数
+// @Tags         帖子
+// @Produce      json
+// @Param        id   path      int  true  "帖子ID"
+// @Success      200  {object}  ResponseData{data=models.Poll}
+// @Router       /post/{id}/poll [get]
+func GetPollHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, _ := getCurrentUserID(c) // 未登录时为 0
+
+	data, err := logic.GetPoll(postID, userID)
+	if err != nil {
+		zap.L().Error("logic.GetPoll() failed", zap.Int64("post_id", postID), zap.Error(err))
+		if code := pollErrorCode(err); code == CodeInvalidParam {
+			ResponseErrorWithMsg(c, code, err.Error())
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// VotePollHandler 参与帖子的投票
+// @Summary      参与投票
+// @Description  选择投票的选项，每人只能投一次，返回投票后的结果
+// @Tags         帖子
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path      int                    true  "帖子ID"
+// @Param        body  body      models.ParamsPollVote  true  "选择的选项"
+// @Success      200   {object}  ResponseData{data=models.Poll}
+// @Router       /post/{id}/poll/vote [post]
+func VotePollHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamsPollVote)
+	if err := c.ShouldBindJSON(p); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			ResponseError(c, CodeInvalidParam)
+			return
+		}
+		ResponseErrorWithMsg(c, CodeInvalidParam, removeTopStruct(errs.Translate(trans)))
+		return
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	data, err := logic.VotePoll(userID, postID, p)
+	if err != nil {
+		zap.L().Error("logic.VotePoll() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("post_id", postID),
+			zap.Error(err))
+		if code := pollErrorCode(err); code == CodeInvalidParam {
+			ResponseErrorWithMsg(c, code, err.Error())
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// pollErrorCode 把投票的业务错误转换成响应码
+func pollErrorCode(err error) ResCode {
+	switch {
+	case errors.Is(err, logic.ErrorPostNotExist),
+		errors.Is(err, logic.ErrorPollNotExist),
+		errors.Is(err, logic.ErrorPollClosed),
+		errors.Is(err, logic.ErrorPollVoted),
+		errors.Is(err, logic.ErrorInvalidChoice):
+		return CodeInvalidParam
+	}
+	return CodeServerBusy
+}
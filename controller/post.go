@@ -1,10 +1,10 @@
 package controller
 
 import (
-	"fmt"
+	"errors"
 	"strconv"
 	"time"
-	"web-app/dao/redis"
+	"web-app/dao/mysql"
 	"web-app/logic"
 	"web-app/models"
 
@@ -19,12 +19,12 @@ import (
 // @Accept       json
 // @Produce      json
 // @Security     ApiKeyAuth
-// @Param        body  body      models.Post  true  "帖子内容"
+// @Param        body  body      models.ParamsCreatePost  true  "帖子内容"
 // @Success      200   {object}  ResponseData
 // @Router       /post [post]
 func CreatePostHandler(c *gin.Context) {
 	// 1.获取参数及参数校验
-	p := new(models.Post)
+	p := new(models.ParamsCreatePost)
 	if err := c.ShouldBindJSON(p); err != nil {
 		zap.L().Error("CreatePost with invalid param", zap.Error(err))
 		ResponseError(c, CodeInvalidParam)
@@ -40,11 +40,210 @@ func CreatePostHandler(c *gin.Context) {
 	// 2.创建帖子
 	if err := logic.CreatePost(p); err != nil {
 		zap.L().Error("logic.CreatePost() failed", zap.Error(err))
-		ResponseError(c, CodeServerBusy)
+		responsePostError(c, err)
 		return
 	}
+	go logic.RecordUserClient(userID, getClientInfo(c))
 	// 3.返回响应
-	ResponseSuccess(c, CodeSuccess)
+	ResponseSuccess(c, gin.H{
+		"post_id": strconv.FormatInt(p.ID, 10),
+		"status":  p.Status, // 0表示命中过滤规则，需要审核通过后才会展示
+	})
+}
+
+// UpdatePostHandler 编辑帖子
+// @Summary      编辑帖子
+// @Description  作者编辑自己的帖子，内容同样经过敏感词过滤
+// @Tags         帖子
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path      int                      true  "帖子ID"
+// @Param        body  body      models.ParamsUpdatePost  true  "帖子内容"
+// @Success      200   {object}  ResponseData
+// @Router       /post/{id} [put]
+func UpdatePostHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamsUpdatePost)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("UpdatePost with invalid param", zap.Error(err))
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	post, err := logic.UpdatePost(userID, postID, p)
+	if err != nil {
+		zap.L().Error("logic.UpdatePost() failed", zap.Int64("post_id", postID), zap.Error(err))
+		responsePostError(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{
+		"post_id": strconv.FormatInt(post.ID, 10),
+		"status":  post.Status,
+	})
+}
+
+// postErrorCode 把发帖/编辑帖子的业务错误转换成响应码
+func postErrorCode(err error) ResCode {
+	var dupLink *logic.DuplicateLinkError
+	var ruleErr *logic.CommunityRuleError
+	switch {
+	case errors.As(err, &dupLink):
+		return CodeDuplicateLink
+	case errors.As(err, &ruleErr):
+		return CodeCommunityRule
+	case errors.Is(err, logic.ErrorContentRejected):
+		return CodeContentRejected
+	case errors.Is(err, logic.ErrorDuplicateContent):
+		return CodeDuplicateContent
+	case errors.Is(err, logic.ErrorPostTooFrequent):
+		return CodePostTooFrequent
+	case errors.Is(err, logic.ErrorPermissionDenied):
+		return CodeNoPermission
+	case errors.Is(err, mysql.ErrorInvalidID),
+		errors.Is(err, logic.ErrorInvalidPoll),
+		errors.Is(err, logic.ErrorEmptyPost),
+		errors.Is(err, logic.ErrorNotDraft),
+		errors.Is(err, logic.ErrorInvalidPublishTime),
+		errors.Is(err, logic.ErrorInvalidLink),
+		errors.Is(err, logic.ErrorCrosspostSource),
+		errors.Is(err, logic.ErrorCrosspostSameCommunity),
+		errors.Is(err, logic.ErrorCrosspostExists):
+		return CodeInvalidParam
+	}
+	return CodeServerBusy
+}
+
+// responsePostError 返回发帖/编辑帖子的错误，参数错误、重复链接和不符合社区规则时带上具体原因
+func responsePostError(c *gin.Context, err error) {
+	code := postErrorCode(err)
+	if code == CodeInvalidParam || code == CodeDuplicateLink || code == CodeCommunityRule {
+		ResponseErrorWithMsg(c, code, err.Error())
+		return
+	}
+	ResponseError(c, code)
+}
+
+// PublishDraftHandler 发布草稿
+// @Summary      发布草稿
+// @Description  发布自己的草稿，传入晚于当前时间的 publish_at 时改为定时发布
+// @Tags         帖子
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path      int                       true   "帖子ID"
+// @Param        body  body      models.ParamsPublishPost  false  "定时发布的时间"
+// @Success      200   {object}  ResponseData
+// @Router       /post/{id}/publish [post]
+func PublishDraftHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamsPublishPost)
+	// 请求体可以为空，表示立即发布
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(p); err != nil {
+			zap.L().Error("PublishDraft with invalid param", zap.Error(err))
+			ResponseError(c, CodeInvalidParam)
+			return
+		}
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	post, err := logic.PublishDraft(userID, postID, p)
+	if err != nil {
+		zap.L().Error("logic.PublishDraft() failed", zap.Int64("post_id", postID), zap.Error(err))
+		responsePostError(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{
+		"post_id":    strconv.FormatInt(post.ID, 10),
+		"status":     post.Status,
+		"publish_at": post.PublishTime,
+	})
+}
+
+// CrosspostHandler 转发帖子
+// @Summary      转发帖子
+// @Description  把帖子转发到另一个社区，转发在该社区有自己的投票和排名，详情展示原帖的内容和作者
+// @Tags         帖子
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path      int                     true  "帖子ID"
+// @Param        body  body      models.ParamsCrosspost  true  "转发参数"
+// @Success      200   {object}  ResponseData
+// @Router       /post/{id}/crosspost [post]
+func CrosspostHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamsCrosspost)
+	if err := c.ShouldBindJSON(p); err != nil {
+		zap.L().Error("Crosspost with invalid param", zap.Error(err))
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	post, err := logic.Crosspost(userID, postID, p)
+	if err != nil {
+		zap.L().Error("logic.Crosspost() failed", zap.Int64("post_id", postID), zap.Error(err))
+		responsePostError(c, err)
+		return
+	}
+	ResponseSuccess(c, gin.H{
+		"post_id": strconv.FormatInt(post.ID, 10),
+		"status":  post.Status,
+	})
+}
+
+// GetMyDraftsHandler 我的草稿
+// @Summary      我的草稿
+// @Description  分页获取自己的草稿和定时发布的帖子，按最后编辑时间倒序
+// @Tags         帖子
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page  query     int  false  "页码"  default(1)
+// @Param        size  query     int  false  "条数"  default(10)
+// @Success      200   {object}  ResponseData{data=[]models.Post}
+// @Router       /me/drafts [get]
+func GetMyDraftsHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	page, size := getPageInfo(c)
+
+	data, err := logic.GetMyDrafts(userID, page, size)
+	if err != nil {
+		zap.L().Error("logic.GetMyDrafts() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
 }
 
 // GetPostDetailHandler 获取帖子详情
@@ -71,8 +270,13 @@ func GetPostDetailHandler(c *gin.Context) {
 		ResponseError(c, CodeServerBusy)
 		return
 	}
+	if !canViewPost(c, data) {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "帖子不存在")
+		return
+	}
+	logic.RecordPostView(data.Post, getVisitorID(c))
 	// 3. 返回相应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerStateOne(c, data))
 }
 
 // GetPostDetailConcurrentHandler 获取帖子详情（并发优化版本）
@@ -109,8 +313,13 @@ func GetPostDetailConcurrentHandler(c *gin.Context) {
 		zap.Int64("post_id", postID),
 		zap.Duration("duration", duration))
 
+	if !canViewPost(c, data) {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "帖子不存在")
+		return
+	}
+	logic.RecordPostView(data.Post, getVisitorID(c))
 	// 3. 返回响应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerStateOne(c, data))
 }
 
 // GetPostListHandler 获取帖子列表的处理函数
@@ -133,7 +342,7 @@ func GetPostListHandler(c *gin.Context) {
 		return
 	}
 	// 2. 返回响应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerState(c, data))
 }
 
 // GetPostListHandler2 升级版帖子列表接口
@@ -149,9 +358,11 @@ func GetPostListHandler2(c *gin.Context) {
 	// @Produce      json
 	// @Param        page         query     int     false  "页码"  default(1)
 	// @Param        size         query     int     false  "条数"  default(10)
-	// @Param        order        query     string  false  "排序: time/score"  default(time)
+	// @Param        order        query     string  false  "排序: time/score/views"  default(time)
 	// @Param        community_id query     int     false  "社区ID"
 	// @Success      200          {object}  ResponseData
+	// @Header       200          {string}  X-As-Of        "这一页生成的时间"
+	// @Header       200          {string}  X-Cache-Stale  "为 1 时表示返回的是旧版本，正在后台重新生成"
 	// @Router       /posts2 [get]
 	// GET请求参数（query string）： /api/v1/post2?page=1&size=10&order=time
 	p := &models.ParamsPostList{
@@ -169,17 +380,20 @@ func GetPostListHandler2(c *gin.Context) {
 	}
 	// 已在上方完成 Query 绑定到 p，无需再次绑定
 
-	data, err := logic.GetPostListNew(p) // 调用合并后的接口
-
-	// 1. 获取数据
-
+	// 1. 获取数据，列表分页带缓存，发帖、投票后缓存的版本号会变化
+	page, err := logic.GetPostListPage(p)
 	if err != nil {
-		zap.L().Error("logic.GetPostList() failed", zap.Error(err))
+		zap.L().Error("logic.GetPostListPage() failed", zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
+	// 分页生成的时间，返回旧版本时前端可以提示刷新
+	c.Header("X-As-Of", page.AsOf.Format(time.RFC3339))
+	if page.Stale {
+		c.Header("X-Cache-Stale", "1")
+	}
 	// 2. 返回响应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerState(c, page.Posts))
 }
 
 // // 根据社区去查询帖子列表
@@ -251,7 +465,7 @@ func GetPostListOptimizedHandler(c *gin.Context) {
 		zap.String("optimization", "N+1_query_solved"))
 
 	// 2. 返回响应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerState(c, data))
 }
 
 // GetPostDetailCachedHandler 获取帖子详情（带缓存）
@@ -291,8 +505,13 @@ func GetPostDetailCachedHandler(c *gin.Context) {
 		zap.Duration("duration", duration),
 		zap.String("optimization", "redis_cache"))
 
+	if !canViewPost(c, data) {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "帖子不存在")
+		return
+	}
+	logic.RecordPostView(data.Post, getVisitorID(c))
 	// 3. 返回响应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerStateOne(c, data))
 }
 
 // GetPostListCachedHandler 获取帖子列表（带缓存）
@@ -330,38 +549,44 @@ func GetPostListCachedHandler(c *gin.Context) {
 		zap.String("optimization", "N+1_with_cache"))
 
 	// 3. 返回响应
-	ResponseSuccess(c, data)
+	ResponseSuccess(c, withViewerState(c, data))
 }
 
 // GetCacheStatsHandler 获取缓存统计信息（调试用）
 // @Summary      获取缓存统计信息
-// @Description  获取Redis缓存的命中率、错误率等统计信息
+// @Description  获取进程内一级缓存和Redis二级缓存的命中率（累计以及最近1分钟/5分钟/1小时）、Redis命令耗时分布和各前缀key的数量及内存
 // @Tags         系统
 // @Accept       json
 // @Produce      json
 // @Success      200  {object}  ResponseData{data=map[string]interface{}}
 // @Router       /cache/stats [get]
 func GetCacheStatsHandler(c *gin.Context) {
-	// 获取缓存统计信息
-	stats := redis.GetCacheStats()
-
-	// 计算命中率
-	result := make(map[string]interface{})
-	for cacheType, stat := range stats {
-		total := stat.HitCount + stat.MissCount
-		hitRate := float64(0)
-		if total > 0 {
-			hitRate = float64(stat.HitCount) / float64(total) * 100
-		}
+	// 获取缓存统计信息，一级缓存（进程内）和二级缓存（Redis）的命中率分开统计
+	result := logic.GetCacheStats()
 
-		result[cacheType] = map[string]interface{}{
-			"hit_count":   stat.HitCount,
-			"miss_count":  stat.MissCount,
-			"error_count": stat.ErrorCount,
-			"hit_rate":    fmt.Sprintf("%.2f%%", hitRate),
-		}
+	zap.L().Debug("Cache stats requested", zap.Any("stats", result))
+	ResponseSuccess(c, result)
+}
+
+// withViewerState 补充赞成/反对票数，登录用户访问时还会补充自己的投票和收藏状态
+func withViewerState(c *gin.Context, data []*models.ApiPostDetail) []*models.ApiPostDetail {
+	userID, _ := getCurrentUserID(c) // 未登录时为 0
+	return logic.FillViewerState(userID, data)
+}
+
+// withViewerStateOne 同 withViewerState，用于帖子详情
+func withViewerStateOne(c *gin.Context, data *models.ApiPostDetail) *models.ApiPostDetail {
+	if data == nil {
+		return data
 	}
+	return withViewerState(c, []*models.ApiPostDetail{data})[0]
+}
 
-	zap.L().Info("Cache stats requested", zap.Any("stats", result))
-	ResponseSuccess(c, result)
+// canViewPost 草稿和定时发布的帖子只有作者自己可以查看
+func canViewPost(c *gin.Context, data *models.ApiPostDetail) bool {
+	if data == nil {
+		return false
+	}
+	userID, _ := getCurrentUserID(c) // 未登录时为 0
+	return logic.CanViewPost(data.Post, userID)
 }
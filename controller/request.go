@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"strconv"
+	"web-app/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -47,4 +50,20 @@ func getPageInfo(c *gin.Context) (int64, int64) {
 	}
 
 	return page, size
-}
\ No newline at end of file
+}
+// getClientInfo 获取请求方的IP和设备ID，设备ID由客户端通过 X-Device-ID 请求头上报
+func getClientInfo(c *gin.Context) *models.ClientInfo {
+	return &models.ClientInfo{
+		IP:       c.ClientIP(),
+		DeviceID: c.GetHeader("X-Device-ID"),
+	}
+}
+
+// getVisitorID 统计浏览量使用的访客标识：登录用户使用用户id，匿名访客使用IP、设备和UA的指纹
+func getVisitorID(c *gin.Context) string {
+	if userID, err := getCurrentUserID(c); err == nil {
+		return "u:" + strconv.FormatInt(userID, 10)
+	}
+	sum := sha1.Sum([]byte(c.ClientIP() + "|" + c.GetHeader("X-Device-ID") + "|" + c.Request.UserAgent()))
+	return "a:" + hex.EncodeToString(sum[:8])
+}
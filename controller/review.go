@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"web-app/dao/mysql"
+	"web-app/logic"
+	"web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetReviewPostsHandler 审核队列（管理员）
+// @Summary      审核队列
+// @Description  分页获取命中内容过滤规则、等待人工审核的帖子，先进入审核的在前，仅管理员可用
+// @Tags         审核
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page  query     int  false  "页码"  default(1)
+// @Param        size  query     int  false  "条数"  default(10)
+// @Success      200   {object}  ResponseData{data=[]models.Post}
+// @Router       /admin/review/posts [get]
+func GetReviewPostsHandler(c *gin.Context) {
+	page, size := getPageInfo(c)
+	if page < 1 || size < 1 || size > 100 {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	data, err := logic.GetReviewPosts(page, size)
+	if err != nil {
+		zap.L().Error("logic.GetReviewPosts() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// ApprovePostHandler 审核通过（管理员）
+// @Summary      审核通过
+// @Description  待审核的帖子审核通过后发布，仅管理员可用
+// @Tags         审核
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "帖子ID"
+// @Success      200  {object}  ResponseData
+// @Router       /admin/post/{id}/approve [post]
+func ApprovePostHandler(c *gin.Context) {
+	reviewPost(c, logic.ApprovePost)
+}
+
+// RejectPostHandler 审核不通过（管理员）
+// @Summary      审核不通过
+// @Description  待审核的帖子审核不通过，之后只有作者自己可见，作者编辑后重新进入审核，仅管理员可用
+// @Tags         审核
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "帖子ID"
+// @Success      200  {object}  ResponseData
+// @Router       /admin/post/{id}/reject [post]
+func RejectPostHandler(c *gin.Context) {
+	reviewPost(c, logic.RejectPost)
+}
+
+// reviewPost 审核通过和不通过的公共处理，帖子不存在或者不在审核中时返回参数错误
+func reviewPost(c *gin.Context, review func(postID int64) (*models.Post, error)) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	post, err := review(postID)
+	if err != nil {
+		if errors.Is(err, mysql.ErrorInvalidID) || errors.Is(err, logic.ErrorNotPending) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("review post failed", zap.Int64("post_id", postID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, gin.H{
+		"post_id": strconv.FormatInt(post.ID, 10),
+		"status":  post.Status,
+	})
+}
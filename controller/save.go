@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+	"web-app/dao/mysql"
+	"web-app/logic"
+	"web-app/models"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// --- 收藏 ---
+
+// SavePostHandler 收藏帖子
+// @Summary      收藏帖子
+// @Description  收藏帖子到指定收藏夹，已收藏时移动到新的收藏夹
+// @Tags         帖子
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path      int                    true   "帖子ID"
+// @Param        body  body      models.ParamsSavePost  false  "收藏夹"
+// @Success      200   {object}  ResponseData
+// @Router       /post/{id}/save [post]
+func SavePostHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := new(models.ParamsSavePost)
+	// 请求体可以为空，表示收藏到默认收藏夹
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(p); err != nil {
+			zap.L().Debug("c.ShouldBindJSON(p) error", zap.Any("err", err))
+			ResponseError(c, CodeInvalidParam)
+			return
+		}
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	if err := logic.SavePost(userID, postID, p.Collection); err != nil {
+		zap.L().Error("logic.SavePost() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("post_id", postID),
+			zap.Error(err))
+		if errors.Is(err, mysql.ErrorInvalidID) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, "帖子不存在")
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// UnsavePostHandler 取消收藏
+// @Summary      取消收藏
+// @Description  取消收藏帖子，未收藏时不会报错
+// @Tags         帖子
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "帖子ID"
+// @Success      200  {object}  ResponseData
+// @Router       /post/{id}/save [delete]
+func UnsavePostHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	if err := logic.UnsavePost(userID, postID); err != nil {
+		zap.L().Error("logic.UnsavePost() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("post_id", postID),
+			zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// GetSavedPostsHandler 我的收藏
+// @Summary      我的收藏
+// @Description  分页获取收藏的帖子，按收藏时间倒序
+// @Tags         帖子
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        collection  query     string  false  "收藏夹名称，不传表示全部"
+// @Param        page        query     int     false  "页码"  default(1)
+// @Param        size        query     int     false  "条数"  default(10)
+// @Success      200         {object}  ResponseData{data=models.ApiSavedList}
+// @Router       /me/saved [get]
+func GetSavedPostsHandler(c *gin.Context) {
+	p := &models.ParamsSavedList{
+		Page: 1,
+		Size: 10,
+	}
+	if err := c.ShouldBindQuery(p); err != nil || p.Page < 1 || p.Size < 1 || p.Size > 100 {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	data, err := logic.GetSavedPosts(userID, p)
+	if err != nil {
+		zap.L().Error("logic.GetSavedPosts() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	data.List = withViewerState(c, data.List)
+	ResponseSuccess(c, data)
+}
+
+// GetSavedCollectionsHandler 我的收藏夹
+// @Summary      我的收藏夹
+// @Description  获取收藏夹列表及每个收藏夹中的帖子数，空名称表示默认收藏夹
+// @Tags         帖子
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  ResponseData{data=[]models.SavedCollection}
+// @Router       /me/saved/collections [get]
+func GetSavedCollectionsHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+
+	data, err := logic.GetSavedCollections(userID)
+	if err != nil {
+		zap.L().Error("logic.GetSavedCollections() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"web-app/logic"
+	"web-app/pkg/jwt"
+	"web-app/pkg/stream"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// --- 实时推送 ---
+
+const (
+	maxStreamTopics    = 50               // 单个连接最多订阅的 topic 数
+	streamWriteTimeout = 10 * time.Second // WebSocket 单次写超时
+)
+
+// StreamHandler 订阅实时事件
+// @Summary      实时事件推送
+// @Description  默认使用 SSE 推送，携带 transport=ws 或 WebSocket 握手头时使用 WebSocket
+// @Description  订阅通知需要登录，EventSource 和 WebSocket 无法设置请求头时先通过 /stream/ticket 获取一次性凭证，放在 ticket 参数中
+// @Description  只能订阅自己可见的帖子，订阅自己的草稿、定时发布和待审核的帖子同样需要登录
+// @Tags         帖子
+// @Produce      text/event-stream
+// @Param        post           query  string  false  "帖子ID，多个用逗号分隔"
+// @Param        community      query  string  false  "社区ID，多个用逗号分隔"
+// @Param        notifications  query  bool    false  "是否订阅自己的通知"
+// @Param        transport      query  string  false  "sse/ws"  default(sse)
+// @Param        ticket         query  string  false  "一次性凭证"
+// @Router       /stream [get]
+func StreamHandler(c *gin.Context) {
+	topics, postIDs, err := parseStreamTopics(c)
+	if err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	notifications := c.Query("notifications") == "true" || c.Query("notifications") == "1"
+	if len(topics) == 0 && !notifications {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "至少需要订阅一个帖子、社区或通知")
+		return
+	}
+	webSocket := c.Query("transport") == "ws" || strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+	if webSocket {
+		// 在使用凭证之前检查，跨站页面发起的握手不会消耗凭证
+		if err := checkStreamOrigin(c); err != nil {
+			zap.L().Warn("websocket origin rejected", zap.String("origin", c.GetHeader("Origin")))
+			ResponseError(c, CodeNoPermission)
+			return
+		}
+	}
+	// 订阅通知必须登录；只订阅帖子和社区时可以不登录，带了凭证时按登录用户检查草稿等帖子是否可见
+	var userID int64
+	if notifications || c.GetHeader("Authorization") != "" || c.Query("ticket") != "" {
+		if userID, err = parseStreamUser(c); err != nil {
+			ResponseError(c, CodeNeedLogin)
+			return
+		}
+	}
+	if notifications {
+		topics = append(topics, logic.TopicUser(userID))
+	}
+	if err := logic.CheckStreamPosts(postIDs, userID); err != nil {
+		if errors.Is(err, logic.ErrorPostNotExist) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+
+	client, err := logic.SubscribeStream(topics)
+	if err != nil {
+		zap.L().Error("logic.SubscribeStream() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	defer logic.UnsubscribeStream(client)
+
+	if webSocket {
+		serveWebSocket(c, client)
+		return
+	}
+	serveSSE(c, client)
+}
+
+// parseStreamTopics 解析要订阅的帖子和社区，同时返回帖子id用于检查是否可见
+func parseStreamTopics(c *gin.Context) (topics []string, postIDs []int64, err error) {
+	topics = make([]string, 0)
+	for _, item := range []struct {
+		param string
+		topic func(int64) string
+	}{
+		{"post", logic.TopicPost},
+		{"community", logic.TopicCommunity},
+	} {
+		for _, idStr := range strings.Split(c.Query(item.param), ",") {
+			if idStr = strings.TrimSpace(idStr); idStr == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("无效的%s参数: %s", item.param, idStr)
+			}
+			topics = append(topics, item.topic(id))
+			if item.param == "post" {
+				postIDs = append(postIDs, id)
+			}
+		}
+	}
+	if len(topics) > maxStreamTopics {
+		return nil, nil, fmt.Errorf("最多订阅%d个帖子或社区", maxStreamTopics)
+	}
+	return topics, postIDs, nil
+}
+
+// StreamTicketHandler 获取订阅通知的一次性凭证
+// @Summary      获取订阅凭证
+// @Description  EventSource 和 WebSocket 无法设置 Authorization 请求头，订阅通知前先获取凭证，凭证几十秒内有效且只能使用一次
+// @Tags         帖子
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  ResponseData
+// @Router       /stream/ticket [post]
+func StreamTicketHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	ticket, ttl, err := logic.CreateStreamTicket(userID)
+	if err != nil {
+		zap.L().Error("logic.CreateStreamTicket() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, gin.H{
+		"ticket":     ticket,
+		"expires_in": int64(ttl / time.Second),
+	})
+}
+
+// parseStreamUser 从 Authorization 请求头或 ticket 参数中解析当前用户
+// 不接受放在 URL 中的 JWT：URL 会出现在访问日志、代理日志和浏览器历史中，泄露后在有效期内都可以使用
+func parseStreamUser(c *gin.Context) (int64, error) {
+	if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+		mc, err := jwt.ParseToken(parts[1])
+		if err != nil {
+			return 0, err
+		}
+		return mc.UserID, nil
+	}
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		return 0, logic.ErrorInvalidStreamTicket
+	}
+	return logic.UseStreamTicket(ticket)
+}
+
+// checkStreamOrigin 浏览器发起的 WebSocket 握手会带上 Origin，只接受和配置的站点地址（site.base_url）同源的页面
+// 没有 Origin 的请求不是浏览器发起的，不做检查
+func checkStreamOrigin(c *gin.Context) error {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return nil
+	}
+	o, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	site, err := url.Parse(logic.SiteBaseURL())
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(o.Scheme, site.Scheme) || !strings.EqualFold(o.Host, site.Host) {
+		return errors.New("origin not allowed")
+	}
+	return nil
+}
+
+// serveSSE 以 Server-Sent Events 的方式推送
+func serveSSE(c *gin.Context, client *stream.Client) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 的缓冲
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	w.Flush()
+
+	heartbeat := time.NewTicker(logic.StreamHeartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			// 客户端断开
+			return
+		case <-client.Done():
+			// 服务端停机或者客户端消费过慢，通知客户端稍后重连
+			fmt.Fprintf(w, "event: close\ndata: {}\n\n")
+			w.Flush()
+			return
+		case payload := <-client.Events():
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprintf(w, ": ping\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
+
+// serveWebSocket 以 WebSocket 的方式推送
+func serveWebSocket(c *gin.Context, client *stream.Client) {
+	server := websocket.Server{
+		// Origin 已经在 StreamHandler 中按站点地址检查过
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// 客户端不需要发消息，读循环只用来感知连接断开
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				var msg string
+				for websocket.Message.Receive(ws, &msg) == nil {
+				}
+			}()
+
+			heartbeat := time.NewTicker(logic.StreamHeartbeat())
+			defer heartbeat.Stop()
+
+			send := func(msg string) bool {
+				_ = ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				return websocket.Message.Send(ws, msg) == nil
+			}
+			for {
+				select {
+				case <-closed:
+					return
+				case <-client.Done():
+					send(`{"type":"close"}`)
+					return
+				case payload := <-client.Events():
+					if !send(string(payload)) {
+						return
+					}
+				case <-heartbeat.C:
+					if !send(`{"type":"ping"}`) {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
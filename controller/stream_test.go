@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+	"web-app/logic"
+	"web-app/settings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckStreamOrigin(t *testing.T) {
+	if err := logic.InitSite(&settings.SiteConfig{BaseURL: "https://bluebell.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		host, origin string
+		ok           bool
+	}{
+		{host: "bluebell.example.com", origin: "https://bluebell.example.com", ok: true},
+		{host: "bluebell.example.com", origin: "", ok: true}, // 不是浏览器发起的请求
+		{host: "bluebell.example.com", origin: "http://bluebell.example.com"},
+		{host: "bluebell.example.com", origin: "https://evil.example.com"},
+		// 伪造的 Host 和 Origin 一致时也不能通过
+		{host: "evil.example.com", origin: "https://evil.example.com"},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/v1/stream/ws", nil)
+		c.Request.Host = tt.host
+		c.Request.Header.Set("X-Forwarded-Proto", "https")
+		if tt.origin != "" {
+			c.Request.Header.Set("Origin", tt.origin)
+		}
+		if err := checkStreamOrigin(c); (err == nil) != tt.ok {
+			t.Errorf("host %q origin %q: checkStreamOrigin() error = %v, want ok = %v", tt.host, tt.origin, err, tt.ok)
+		}
+	}
+}
@@ -104,6 +104,7 @@ func LoginHandler(c *gin.Context) {
 		ResponseError(c, CodeServerBusy)
 		return
 	}
+	go logic.RecordUserClient(user.UserID, getClientInfo(c))
 	// 3. 返回响应
 	ResponseSuccess(c, gin.H{
 		"user_id":  strconv.FormatInt(user.UserID, 10), // id 值大于 1<<53-1 （JSON        int64类型的最大值 1<<63-1
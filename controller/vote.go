@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"strconv"
 	"web-app/logic"
 	"web-app/models"
 
@@ -47,10 +48,92 @@ func PostVoteController(c *gin.Context) {
 		return
 	}
 
-	if err := logic.VoteForPost(userID, p); err != nil {
+	if err := logic.VoteForPost(userID, p, getClientInfo(c)); err != nil {
 		zap.L().Error("logic.VoteForPost failed", zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
 	ResponseSuccess(c, nil)
 }
+
+// GetMyVotesHandler 我的投票记录
+// @Summary      我的投票记录
+// @Description  按游标分页获取当前用户的投票记录，包含投票时间和投票前的状态
+// @Tags         投票
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        direction  query     string  false  "up/down/cancel，不传表示全部"
+// @Param        cursor     query     string  false  "上一页返回的 next_cursor"
+// @Param        size       query     int     false  "条数"  default(20)
+// @Success      200        {object}  ResponseData{data=models.ApiVoteLogList}
+// @Router       /me/votes [get]
+func GetMyVotesHandler(c *gin.Context) {
+	userID, err := getCurrentUserID(c)
+	if err != nil {
+		ResponseError(c, CodeNeedLogin)
+		return
+	}
+	p := &models.ParamsVoteLogList{Size: 20}
+	if err := c.ShouldBindQuery(p); err != nil || p.Size < 1 || p.Size > 100 {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+
+	data, err := logic.GetMyVotes(userID, p)
+	if err != nil {
+		zap.L().Error("logic.GetMyVotes() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// GetPostVotesHandler 帖子的投票记录（管理员）
+// @Summary      帖子的投票记录
+// @Description  按游标分页获取帖子的全部投票记录，用于排查刷票，仅管理员可用
+// @Tags         投票
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path      int     true   "帖子ID"
+// @Param        cursor  query     string  false  "上一页返回的 next_cursor"
+// @Param        size    query     int     false  "条数"  default(50)
+// @Success      200     {object}  ResponseData{data=models.ApiVoteLogList}
+// @Router       /admin/post/{id}/votes [get]
+func GetPostVotesHandler(c *gin.Context) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	p := &models.ParamsVoteLogList{Size: 50}
+	if err := c.ShouldBindQuery(p); err != nil || p.Size < 1 || p.Size > 500 {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+
+	data, err := logic.GetPostVotes(postID, p)
+	if err != nil {
+		zap.L().Error("logic.GetPostVotes() failed", zap.Int64("post_id", postID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// GetAbuseClustersHandler 疑似刷票团伙（管理员）
+// @Summary      疑似刷票团伙
+// @Description  列出总是一起投票的账号团伙，按团伙人数倒序，仅管理员可用
+// @Tags         投票
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  ResponseData{data=[]models.AbuseCluster}
+// @Router       /admin/abuse/clusters [get]
+func GetAbuseClustersHandler(c *gin.Context) {
+	data, err := logic.GetAbuseClusters()
+	if err != nil {
+		zap.L().Error("logic.GetAbuseClusters() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
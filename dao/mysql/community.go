@@ -32,7 +32,7 @@ func GetCommunityDetailByID(id int64) (community *models.CommunityDetail, err er
 				where community_id = ?`
 	// 读操作使用读数据库
 	readDB := GetReadDB()
-	if err := readDB.Get(community, sqlStr, id); err != nil {
+	if err = readDB.Get(community, sqlStr, id); err != nil {
 		if err == sql.ErrNoRows {
 			zap.L().Warn("there is no community in db")
 			err = ErrorInvalidID
@@ -107,3 +107,28 @@ func BatchGetCommunitiesByIDs(communityIDs []int64) (communityMap map[int64]*mod
 
 	return communityMap, nil
 }
+
+// GetCommunityRule 查询社区的发帖规则，没有配置时返回默认规则
+func GetCommunityRule(communityID int64) (rule *models.CommunityRule, err error) {
+	sqlStr := `select community_id, allow_crosspost, allow_link, min_account_days, min_karma
+	from community_rule
+	where community_id = ?`
+	rule = new(models.CommunityRule)
+	readDB := GetReadDB()
+	err = readDB.Get(rule, sqlStr, communityID)
+	if err == sql.ErrNoRows {
+		return &models.CommunityRule{CommunityID: communityID, AllowCrosspost: true, AllowLink: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetCommunityIDsAfter 按 community_id 顺序查询大于 afterID 的社区id，用于分批遍历所有社区
+func GetCommunityIDsAfter(afterID int64, limit int) (ids []int64, err error) {
+	sqlStr := `select community_id from community where community_id > ? order by community_id limit ?`
+	readDB := GetReadDB()
+	err = readDB.Select(&ids, sqlStr, afterID, limit)
+	return
+}
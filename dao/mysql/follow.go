@@ -0,0 +1,69 @@
+package mysql
+
+import "web-app/models"
+
+// InsertFollow 新增关注关系，已经关注过时返回 false
+func InsertFollow(userID, followerID int64) (bool, error) {
+	sqlStr := `insert ignore into follow(user_id, follower_id) values(?, ?)`
+	writeDB := GetWriteDB()
+	ret, err := writeDB.Exec(sqlStr, userID, followerID)
+	if err != nil {
+		return false, err
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteFollow 删除关注关系，本来就没有关注时返回 false
+func DeleteFollow(userID, followerID int64) (bool, error) {
+	sqlStr := `delete from follow where user_id = ? and follower_id = ?`
+	writeDB := GetWriteDB()
+	ret, err := writeDB.Exec(sqlStr, userID, followerID)
+	if err != nil {
+		return false, err
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// GetFollowers 分页查询用户的粉丝，按关注时间倒序
+func GetFollowers(userID, page, size int64) (list []*models.FollowUser, err error) {
+	sqlStr := `select u.user_id, u.username, f.create_time
+	from follow f join user u on u.user_id = f.follower_id
+	where f.user_id = ?
+	order by f.create_time desc
+	limit ?, ?`
+	list = make([]*models.FollowUser, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, userID, (page-1)*size, size)
+	return
+}
+
+// GetFollowing 分页查询用户关注的人，按关注时间倒序
+func GetFollowing(followerID, page, size int64) (list []*models.FollowUser, err error) {
+	sqlStr := `select u.user_id, u.username, f.create_time
+	from follow f join user u on u.user_id = f.user_id
+	where f.follower_id = ?
+	order by f.create_time desc
+	limit ?, ?`
+	list = make([]*models.FollowUser, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, followerID, (page-1)*size, size)
+	return
+}
+
+// CountFollowers 查询用户的粉丝数
+func CountFollowers(userID int64) (count int64, err error) {
+	sqlStr := `select count(*) from follow where user_id = ?`
+	readDB := GetReadDB()
+	err = readDB.Get(&count, sqlStr, userID)
+	return
+}
+
+// CountFollowing 查询用户的关注数
+func CountFollowing(followerID int64) (count int64, err error) {
+	sqlStr := `select count(*) from follow where follower_id = ?`
+	readDB := GetReadDB()
+	err = readDB.Get(&count, sqlStr, followerID)
+	return
+}
@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+	"web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// GetLinkPosts 查询发布过某个链接的帖子，按发帖时间倒序，communityID 为 0 时查询所有社区
+func GetLinkPosts(urlHash string, communityID, limit int64) (list []*models.LinkPost, err error) {
+	sqlStr := `select p.post_id, p.title, p.community_id, ifnull(c.community_name, '') as community_name, p.create_time
+	from post p
+	left join community c on c.community_id = p.community_id
+	where p.url_hash = ? and p.status = 1 and (? = 0 or p.community_id = ?)
+	order by p.create_time desc
+	limit ?`
+	list = make([]*models.LinkPost, 0)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, urlHash, communityID, communityID, limit)
+	return
+}
+
+// InsertLinkPreview 新建一条待抓取的链接预览，已经存在时不做修改
+func InsertLinkPreview(urlHash, url string) (created bool, err error) {
+	sqlStr := `insert ignore into link_preview(url_hash, url) values(?, ?)`
+	writeDB := GetWriteDB()
+	ret, err := writeDB.Exec(sqlStr, urlHash, url)
+	if err != nil {
+		return false, err
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// GetLinkPreview 查询单个链接的预览
+func GetLinkPreview(urlHash string) (preview *models.LinkPreview, err error) {
+	sqlStr := `select url_hash, url, status, title, description, image, site_name, fail_count, fetch_time
+	from link_preview
+	where url_hash = ?`
+	preview = new(models.LinkPreview)
+	readDB := GetReadDB()
+	err = readDB.Get(preview, sqlStr, urlHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrorInvalidID
+	}
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// GetLinkPreviews 批量查询链接的预览，返回 url_hash -> 预览
+func GetLinkPreviews(urlHashes []string) (map[string]*models.LinkPreview, error) {
+	previews := make(map[string]*models.LinkPreview, len(urlHashes))
+	if len(urlHashes) == 0 {
+		return previews, nil
+	}
+	sqlStr := `select url_hash, url, status, title, description, image, site_name, fail_count, fetch_time
+	from link_preview
+	where url_hash in (?)`
+	query, args, err := sqlx.In(sqlStr, urlHashes)
+	if err != nil {
+		return nil, err
+	}
+	readDB := GetReadDB()
+	list := make([]*models.LinkPreview, 0, len(urlHashes))
+	if err := readDB.Select(&list, readDB.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		previews[p.URLHash] = p
+	}
+	return previews, nil
+}
+
+// UpdateLinkPreview 保存抓取成功的预览信息
+func UpdateLinkPreview(p *models.LinkPreview) (err error) {
+	sqlStr := `update link_preview
+	set status = ?, title = ?, description = ?, image = ?, site_name = ?, fail_count = 0, fetch_time = ?
+	where url_hash = ?`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, models.LinkStatusSuccess, p.Title, p.Description, p.Image, p.SiteName, time.Now(), p.URLHash)
+	return
+}
+
+// MarkLinkPreviewFailed 记录一次抓取失败，之前抓取成功过的保留原来的预览信息
+func MarkLinkPreviewFailed(urlHash string) (err error) {
+	sqlStr := `update link_preview
+	set status = if(status = ?, status, ?), fail_count = fail_count + 1
+	where url_hash = ?`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, models.LinkStatusSuccess, models.LinkStatusFailed, urlHash)
+	return
+}
+
+// GetLinkPreviewsToFetch 查询需要补抓的链接：超过 before 还没有抓取的，以及失败次数没有超过上限的
+func GetLinkPreviewsToFetch(before time.Time, maxFailures int, limit int64) (list []*models.LinkPreview, err error) {
+	sqlStr := `select url_hash, url, status, title, description, image, site_name, fail_count, fetch_time
+	from link_preview
+	where (status = ? or (status = ? and fail_count < ?)) and update_time < ?
+	order by update_time
+	limit ?`
+	list = make([]*models.LinkPreview, 0, limit)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, models.LinkStatusPending, models.LinkStatusFailed, maxFailures, before, limit)
+	return
+}
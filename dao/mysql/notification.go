@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"database/sql"
+	"web-app/models"
+)
+
+// InsertNotification 新增一条通知
+func InsertNotification(n *models.Notification) (err error) {
+	sqlStr := `insert into notification(notification_id, user_id, actor_id, type, post_id, content)
+	values(?, ?, ?, ?, ?, ?)`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, n.ID, n.UserID, n.ActorID, n.Type, n.PostID, n.Content)
+	return
+}
+
+// GetNotifications 按游标分页查询用户的通知，cursor 为 0 表示从最新的开始
+// 通知id由雪花算法生成，按id倒序即按时间倒序
+func GetNotifications(userID, cursor, size int64) (list []*models.Notification, err error) {
+	list = make([]*models.Notification, 0, size)
+	readDB := GetReadDB()
+	if cursor > 0 {
+		sqlStr := `select notification_id, user_id, actor_id, type, post_id, content, is_read, create_time
+		from notification
+		where user_id = ? and notification_id < ?
+		order by notification_id desc
+		limit ?`
+		err = readDB.Select(&list, sqlStr, userID, cursor, size)
+		return
+	}
+	sqlStr := `select notification_id, user_id, actor_id, type, post_id, content, is_read, create_time
+	from notification
+	where user_id = ?
+	order by notification_id desc
+	limit ?`
+	err = readDB.Select(&list, sqlStr, userID, size)
+	return
+}
+
+// MarkNotificationRead 把一条通知标记为已读，通知不存在或已经是已读时返回 false
+func MarkNotificationRead(userID, notificationID int64) (bool, error) {
+	sqlStr := `update notification set is_read = 1 where user_id = ? and notification_id = ? and is_read = 0`
+	writeDB := GetWriteDB()
+	ret, err := writeDB.Exec(sqlStr, userID, notificationID)
+	if err != nil {
+		return false, err
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// MarkAllNotificationsRead 把用户的全部通知标记为已读
+func MarkAllNotificationsRead(userID int64) (err error) {
+	sqlStr := `update notification set is_read = 1 where user_id = ? and is_read = 0`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, userID)
+	return
+}
+
+// CountUnreadNotifications 查询用户的未读通知数
+func CountUnreadNotifications(userID int64) (count int64, err error) {
+	sqlStr := `select count(*) from notification where user_id = ? and is_read = 0`
+	readDB := GetReadDB()
+	err = readDB.Get(&count, sqlStr, userID)
+	return
+}
+
+// GetNotificationSetting 查询用户的通知偏好，没有设置过时返回全部开启
+func GetNotificationSetting(userID int64) (setting *models.NotificationSetting, err error) {
+	setting = &models.NotificationSetting{Mention: true, Vote: true}
+	sqlStr := `select mention, vote from notification_setting where user_id = ?`
+	readDB := GetReadDB()
+	err = readDB.Get(setting, sqlStr, userID)
+	if err == sql.ErrNoRows {
+		return setting, nil
+	}
+	return
+}
+
+// SaveNotificationSetting 保存用户的通知偏好
+func SaveNotificationSetting(userID int64, setting *models.NotificationSetting) (err error) {
+	sqlStr := `insert into notification_setting(user_id, mention, vote) values(?, ?, ?)
+	on duplicate key update mention = values(mention), vote = values(vote)`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, userID, setting.Mention, setting.Vote)
+	return
+}
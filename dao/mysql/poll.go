@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	"database/sql"
+	"web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// insertPoll 保存帖子附带的投票及选项，在发帖的事务中调用
+func insertPoll(tx *sqlx.Tx, poll *models.Poll) error {
+	if _, err := tx.Exec(`insert into poll(post_id, multiple, close_time) values(?, ?, ?)`,
+		poll.PostID, poll.Multiple, poll.CloseTime); err != nil {
+		return err
+	}
+	for _, option := range poll.Options {
+		if _, err := tx.Exec(`insert into poll_option(post_id, idx, text) values(?, ?, ?)`,
+			poll.PostID, option.Index, option.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPoll 查询帖子附带的投票，没有投票时返回 nil
+func GetPoll(postID int64) (poll *models.Poll, err error) {
+	readDB := GetReadDB()
+	poll = new(models.Poll)
+	err = readDB.Get(poll, `select post_id, multiple, close_time, closed from poll where post_id = ?`, postID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	poll.Options = make([]*models.PollOption, 0)
+	err = readDB.Select(&poll.Options, `select idx, text, votes from poll_option where post_id = ? order by idx`, postID)
+	return poll, err
+}
+
+// ClosePoll 投票截止，保存最终票数
+func ClosePoll(postID int64, counts map[int]int64) (err error) {
+	writeDB := GetWriteDB()
+	tx, err := writeDB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for idx, votes := range counts {
+		if _, err = tx.Exec(`update poll_option set votes = ? where post_id = ? and idx = ?`, votes, postID, idx); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(`update poll set closed = 1 where post_id = ?`, postID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
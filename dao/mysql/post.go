@@ -3,27 +3,44 @@ package mysql
 import (
 	"database/sql"
 	"strings"
+	"time"
 	"web-app/models"
 
 	"github.com/jmoiron/sqlx"
 )
 
-// CreatePost 创建帖子
-func CreatePost(p *models.Post) (err error) {
+// CreatePost 创建帖子，poll 不为空时在同一个事务中保存帖子附带的投票
+func CreatePost(p *models.Post, poll *models.Poll) (err error) {
 	sqlStr := `insert into post(
-post_id, title, content, author_id, community_id)
-value(?, ?, ?, ?, ?)`
+post_id, title, content, author_id, community_id, status, publish_time, url, url_hash, crosspost_of)
+value(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	// 写操作使用写数据库
 	writeDB := GetWriteDB()
-	_, err = writeDB.Exec(sqlStr, p.ID, p.Title, p.Content, p.AuthorID, p.CommunityID)
+	tx, err := writeDB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
 
-	return
+	if _, err = tx.Exec(sqlStr, p.ID, p.Title, p.Content, p.AuthorID, p.CommunityID, p.Status, p.PublishTime, p.URL, p.URLHash, p.CrosspostOf); err != nil {
+		return err
+	}
+	if poll != nil {
+		if err = insertPoll(tx, poll); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // GetPostByID 根据帖子id获取单个帖子详情
 func GetPostByID(postID int64) (post *models.Post, err error) {
 	sqlStr := `select
-post_id, title, content, author_id, community_id, status, create_time
+post_id, title, content, author_id, community_id, status, create_time, update_time, publish_time, url, url_hash, crosspost_of
 from post
 where post_id = ?`
 	post = new(models.Post)
@@ -41,8 +58,9 @@ where post_id = ?`
 
 func GetPostList(page, size int64) (posts []*models.Post, err error) {
 	sqlStr := `select
-post_id, title, content, author_id, community_id, status, create_time
+post_id, title, content, author_id, community_id, status, create_time, url, url_hash, crosspost_of
 from post
+where status = 1
 order by create_time desc
 limit ?, ?`
 	posts = make([]*models.Post, 0, 2) // 预先分配好容量，避免多次切片扩容 不要写成make([]*models.Post, 2)
@@ -55,9 +73,9 @@ limit ?, ?`
 
 // GetPostListByIDs根据给定的id列表查询帖子数据
 func GetPostListByIDs(ids []string) (postList []*models.Post, err error) {
-	sqlStr := `select post_id, title, content, author_id, community_id, create_time
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time, update_time, publish_time, url, url_hash, crosspost_of
 	from post
-	where post_id in (?)
+	where post_id in (?) and status = 1
 	order by FIND_IN_SET(post_id, ?)
 	`
 	query, args, err := sqlx.In(sqlStr, ids, strings.Join(ids, ","))
@@ -73,3 +91,169 @@ func GetPostListByIDs(ids []string) (postList []*models.Post, err error) {
 	return
 
 }
+
+// GetPendingPostsByIDs 按给定的id顺序查询待审核的帖子，已经审核过的不返回
+func GetPendingPostsByIDs(ids []string) (postList []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time, update_time, publish_time, url, url_hash, crosspost_of
+	from post
+	where post_id in (?) and status = ?
+	order by FIND_IN_SET(post_id, ?)`
+	query, args, err := sqlx.In(sqlStr, ids, models.PostStatusPending, strings.Join(ids, ","))
+	if err != nil {
+		return nil, err
+	}
+	readDB := GetReadDB()
+	err = readDB.Select(&postList, readDB.Rebind(query), args...)
+	return
+}
+
+// GetPostListOrdered Redis 不可用时直接从 MySQL 查询帖子列表，communityID 为 0 时查询所有社区
+// 按浏览量排序时使用定时同步的 view_count，其他排序都按发帖时间
+func GetPostListOrdered(communityID int64, order string, page, size int64) (posts []*models.Post, err error) {
+	orderBy := "create_time desc"
+	if order == models.OrderViews {
+		orderBy = "view_count desc, create_time desc"
+	}
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time, update_time, publish_time, url, url_hash, crosspost_of
+	from post
+	where status = 1 and (? = 0 or community_id = ?)
+	order by ` + orderBy + `
+	limit ?, ?`
+	posts = make([]*models.Post, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&posts, sqlStr, communityID, communityID, (page-1)*size, size)
+	return
+}
+
+// UpdatePost 更新帖子标题、内容、状态和定时发布时间
+func UpdatePost(p *models.Post) (err error) {
+	sqlStr := `update post set title = ?, content = ?, status = ?, publish_time = ? where post_id = ?`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, p.Title, p.Content, p.Status, p.PublishTime, p.ID)
+	return
+}
+
+// GetDuePosts 查询到了发布时间的定时帖子
+func GetDuePosts(now time.Time, limit int64) (postList []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time, publish_time, url, url_hash, crosspost_of
+	from post
+	where status = ? and publish_time <= ?
+	order by publish_time
+	limit ?`
+	readDB := GetReadDB()
+	err = readDB.Select(&postList, sqlStr, models.PostStatusScheduled, now, limit)
+	return
+}
+
+// UpdatePostStatus 把帖子从 from 状态改为 to 状态，返回是否修改成功
+// 多个实例同时修改同一个帖子时只有一个会成功
+func UpdatePostStatus(postID int64, from, to int32) (bool, error) {
+	sqlStr := `update post set status = ? where post_id = ? and status = ?`
+	writeDB := GetWriteDB()
+	ret, err := writeDB.Exec(sqlStr, to, postID, from)
+	if err != nil {
+		return false, err
+	}
+	n, err := ret.RowsAffected()
+	return n > 0, err
+}
+
+// GetUserDrafts 分页查询作者的草稿和定时发布的帖子
+func GetUserDrafts(userID, page, size int64) (postList []*models.Post, err error) {
+	sqlStr := `select post_id, title, content, author_id, community_id, status, create_time, publish_time, url, url_hash, crosspost_of
+	from post
+	where author_id = ? and status in (?, ?)
+	order by update_time desc
+	limit ?, ?`
+	postList = make([]*models.Post, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&postList, sqlStr, userID, models.PostStatusDraft, models.PostStatusScheduled, (page-1)*size, size)
+	return
+}
+
+// UpdatePostViews 同步帖子的浏览量和独立访客数，不更新 update_time
+func UpdatePostViews(postID, views, uniqueVisitors int64) (err error) {
+	sqlStr := `update post set view_count = ?, unique_visitors = ?, update_time = update_time where post_id = ?`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, views, uniqueVisitors, postID)
+	return
+}
+
+// CountPublishedPosts 查询已发布的帖子数
+func CountPublishedPosts() (count int64, err error) {
+	sqlStr := `select count(*) from post where status = 1`
+	readDB := GetReadDB()
+	err = readDB.Get(&count, sqlStr)
+	return
+}
+
+// GetSitemapPosts 按发帖顺序分页查询已发布的帖子，用于生成站点地图
+func GetSitemapPosts(page, size int64) (list []*models.SitemapPost, err error) {
+	sqlStr := `select post_id, title, ifnull(update_time, create_time) as update_time
+	from post
+	where status = 1
+	order by id
+	limit ?, ?`
+	list = make([]*models.SitemapPost, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, (page-1)*size, size)
+	return
+}
+
+// GetCrosspostID 查询原帖在某个社区的转发，没有时返回 0
+func GetCrosspostID(originalID, communityID int64) (postID int64, err error) {
+	sqlStr := `select post_id from post
+	where crosspost_of = ? and community_id = ? and status in (?, ?)
+	limit 1`
+	readDB := GetReadDB()
+	err = readDB.Get(&postID, sqlStr, originalID, communityID, models.PostStatusPending, models.PostStatusNormal)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return
+}
+
+// GetPostRanksAfter 按 post_id 顺序查询大于 afterID 的已发布和待审核的帖子，用于重建 Redis 排行榜
+func GetPostRanksAfter(afterID int64, limit int) (posts []*models.PostRank, err error) {
+	sqlStr := `select post_id, author_id, community_id, status, create_time, publish_time, view_count
+	from post
+	where post_id > ? and status in (?, ?)
+	order by post_id
+	limit ?`
+	posts = make([]*models.PostRank, 0, limit)
+	readDB := GetReadDB()
+	err = readDB.Select(&posts, sqlStr, afterID, models.PostStatusPending, models.PostStatusNormal, limit)
+	return
+}
+
+// GetPostRanksByIDs 按id批量查询帖子，用于检查 Redis 排行榜中的帖子是否存在、是否已发布
+func GetPostRanksByIDs(ids []int64) (posts []*models.PostRank, err error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`select post_id, author_id, community_id, status, create_time, publish_time, view_count
+	from post
+	where post_id in (?)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	readDB := GetReadDB()
+	err = readDB.Select(&posts, readDB.Rebind(query), args...)
+	return
+}
+
+// CountRankPosts 查询已发布和待审核的帖子数，用于显示重建进度
+func CountRankPosts() (count int64, err error) {
+	sqlStr := `select count(*) from post where status in (?, ?)`
+	readDB := GetReadDB()
+	err = readDB.Get(&count, sqlStr, models.PostStatusPending, models.PostStatusNormal)
+	return
+}
+
+// GetPostIDsAfter 按 post_id 顺序查询大于 afterID 的帖子id，用于分批遍历所有帖子
+func GetPostIDsAfter(afterID int64, limit int) (ids []int64, err error) {
+	sqlStr := `select post_id from post where post_id > ? order by post_id limit ?`
+	readDB := GetReadDB()
+	err = readDB.Select(&ids, sqlStr, afterID, limit)
+	return
+}
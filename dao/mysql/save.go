@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SavePost 收藏帖子，已经收藏过时移动到新的收藏夹
+func SavePost(userID, postID int64, collection string) (err error) {
+	sqlStr := `insert into post_save(user_id, post_id, collection) values(?, ?, ?)
+	on duplicate key update collection = values(collection)`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, userID, postID, collection)
+	return
+}
+
+// UnsavePost 取消收藏
+func UnsavePost(userID, postID int64) (err error) {
+	sqlStr := `delete from post_save where user_id = ? and post_id = ?`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, userID, postID)
+	return
+}
+
+// GetSavedPostIDs 分页查询收藏的帖子id，按收藏时间倒序，collection 为 nil 时查询全部收藏夹
+func GetSavedPostIDs(userID int64, collection *string, page, size int64) (ids []string, err error) {
+	ids = make([]string, 0, size)
+	readDB := GetReadDB()
+	if collection == nil {
+		sqlStr := `select post_id from post_save where user_id = ? order by create_time desc limit ?, ?`
+		err = readDB.Select(&ids, sqlStr, userID, (page-1)*size, size)
+		return
+	}
+	sqlStr := `select post_id from post_save where user_id = ? and collection = ? order by create_time desc limit ?, ?`
+	err = readDB.Select(&ids, sqlStr, userID, *collection, (page-1)*size, size)
+	return
+}
+
+// CountSavedPosts 查询收藏数，collection 为 nil 时统计全部收藏夹
+func CountSavedPosts(userID int64, collection *string) (count int64, err error) {
+	readDB := GetReadDB()
+	if collection == nil {
+		err = readDB.Get(&count, `select count(*) from post_save where user_id = ?`, userID)
+		return
+	}
+	err = readDB.Get(&count, `select count(*) from post_save where user_id = ? and collection = ?`, userID, *collection)
+	return
+}
+
+// GetSavedCollections 查询用户的收藏夹及其中的帖子数
+func GetSavedCollections(userID int64) (list []*models.SavedCollection, err error) {
+	sqlStr := `select collection, count(*) as count from post_save where user_id = ? group by collection order by collection`
+	list = make([]*models.SavedCollection, 0)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, userID)
+	return
+}
+
+// GetSavedPostSet 查询给定帖子中哪些被用户收藏了
+func GetSavedPostSet(userID int64, postIDs []int64) (saved map[int64]bool, err error) {
+	saved = make(map[int64]bool, len(postIDs))
+	if len(postIDs) == 0 {
+		return saved, nil
+	}
+	query, args, err := sqlx.In(`select post_id from post_save where user_id = ? and post_id in (?)`, userID, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	readDB := GetReadDB()
+	var ids []int64
+	if err = readDB.Select(&ids, readDB.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		saved[id] = true
+	}
+	return saved, nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 	"web-app/models"
 
+	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
@@ -149,3 +151,36 @@ func BatchGetUsersByIDs(userIDs []int64) (userMap map[int64]*models.User, err er
 
 	return userMap, nil
 }
+
+// GetUsersByUsernames 根据用户名列表批量查询用户
+func GetUsersByUsernames(usernames []string) (users []*models.User, err error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`select user_id, username from user where username in (?)`, usernames)
+	if err != nil {
+		return nil, err
+	}
+	readDB := GetReadDB()
+	err = readDB.Select(&users, readDB.Rebind(query), args...)
+	return
+}
+
+// GetUserCreateTime 查询用户的注册时间
+func GetUserCreateTime(userID int64) (createTime time.Time, err error) {
+	sqlStr := `select create_time from user where user_id = ?`
+	readDB := GetReadDB()
+	err = readDB.Get(&createTime, sqlStr, userID)
+	if err == sql.ErrNoRows {
+		err = ErrorUserNotExist
+	}
+	return
+}
+
+// GetUserIDsAfter 按 user_id 顺序查询大于 afterID 的用户id，用于分批遍历所有用户
+func GetUserIDsAfter(afterID int64, limit int) (ids []int64, err error) {
+	sqlStr := `select user_id from user where user_id > ? order by user_id limit ?`
+	readDB := GetReadDB()
+	err = readDB.Select(&ids, sqlStr, afterID, limit)
+	return
+}
@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"strings"
+	"web-app/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertVoteLog 追加一条投票记录
+func InsertVoteLog(v *models.VoteLog) (err error) {
+	sqlStr := `insert into vote_log(post_id, user_id, direction, old_direction, weight) values(?, ?, ?, ?, ?)`
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, v.PostID, v.UserID, v.Direction, v.OldDirection, v.Weight)
+	return
+}
+
+// GetUserVoteLogs 按游标分页查询用户的投票记录，direction 为 nil 时不过滤
+func GetUserVoteLogs(userID int64, direction *int8, cursor, size int64) (list []*models.VoteLog, err error) {
+	sqlStr := `select v.id, v.post_id, v.user_id, v.direction, v.old_direction, v.weight, coalesce(p.title, '') as title, v.create_time
+	from vote_log v left join post p on p.post_id = v.post_id
+	where v.user_id = ?`
+	args := []interface{}{userID}
+	if direction != nil {
+		sqlStr += ` and v.direction = ?`
+		args = append(args, *direction)
+	}
+	if cursor > 0 {
+		sqlStr += ` and v.id < ?`
+		args = append(args, cursor)
+	}
+	sqlStr += ` order by v.id desc limit ?`
+	args = append(args, size)
+
+	list = make([]*models.VoteLog, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, args...)
+	return
+}
+
+// GetPostVoteLogs 按游标分页查询帖子的投票记录
+func GetPostVoteLogs(postID, cursor, size int64) (list []*models.VoteLog, err error) {
+	sqlStr := `select v.id, v.post_id, v.user_id, v.direction, v.old_direction, v.weight, coalesce(u.username, '') as username, v.create_time
+	from vote_log v left join user u on u.user_id = v.user_id
+	where v.post_id = ?`
+	args := []interface{}{postID}
+	if cursor > 0 {
+		sqlStr += ` and v.id < ?`
+		args = append(args, cursor)
+	}
+	sqlStr += ` order by v.id desc limit ?`
+	args = append(args, size)
+
+	list = make([]*models.VoteLog, 0, size)
+	readDB := GetReadDB()
+	err = readDB.Select(&list, sqlStr, args...)
+	return
+}
+
+// UpsertPostVotes 批量保存用户对帖子当前的投票，同一个帖子和用户只保留最新的一条
+func UpsertPostVotes(votes []*models.PostVote) (err error) {
+	if len(votes) == 0 {
+		return nil
+	}
+	sqlStr := `insert into post_vote(post_id, user_id, direction, weight) values ` +
+		strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?), ", len(votes)), ", ") +
+		` on duplicate key update direction = values(direction), weight = values(weight)`
+	args := make([]interface{}, 0, len(votes)*4)
+	for _, v := range votes {
+		args = append(args, v.PostID, v.UserID, v.Direction, v.Weight)
+	}
+	writeDB := GetWriteDB()
+	_, err = writeDB.Exec(sqlStr, args...)
+	return
+}
+
+// GetPostVotesByPostIDs 查询一批帖子当前有效的投票（不包括已取消的）
+func GetPostVotesByPostIDs(postIDs []int64) (votes []*models.PostVote, err error) {
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`select post_id, user_id, direction, weight from post_vote
+	where post_id in (?) and direction != 0`, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	readDB := GetReadDB()
+	err = readDB.Select(&votes, readDB.Rebind(query), args...)
+	return
+}
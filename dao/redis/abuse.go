@@ -0,0 +1,154 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// abuseTTL 刷票检测相关数据的保留时间
+const abuseTTL = 30 * 24 * time.Hour
+
+// CoVoteStat 和某个用户共同投票的统计
+type CoVoteStat struct {
+	UserID     string
+	CoVotes    int64 // 共同投票次数
+	TotalVotes int64 // 对方的投票总数
+}
+
+// RecordUserClient 记录用户近期使用过的IP和设备
+func RecordUserClient(userID int64, ip, deviceID string) error {
+	uid := strconv.FormatInt(userID, 10)
+	pipeline := client.Pipeline()
+	if ip != "" {
+		key := getRedisKey(KeyAbuseIPSetPF + uid)
+		pipeline.SAdd(key, ip)
+		pipeline.Expire(key, abuseTTL)
+	}
+	if deviceID != "" {
+		key := getRedisKey(KeyAbuseDeviceSetPF + uid)
+		pipeline.SAdd(key, deviceID)
+		pipeline.Expire(key, abuseTTL)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// CheckClientOverlap 判断IP和设备是否被某个用户（帖子作者）近期使用过
+func CheckClientOverlap(userID int64, ip, deviceID string) (ipHit, deviceHit bool, err error) {
+	uid := strconv.FormatInt(userID, 10)
+	pipeline := client.Pipeline()
+	ipCmd := pipeline.SIsMember(getRedisKey(KeyAbuseIPSetPF+uid), ip)
+	deviceCmd := pipeline.SIsMember(getRedisKey(KeyAbuseDeviceSetPF+uid), deviceID)
+	if _, err = pipeline.Exec(); err != nil {
+		return false, false, err
+	}
+	return ip != "" && ipCmd.Val(), deviceID != "" && deviceCmd.Val(), nil
+}
+
+// GetKarma 查询用户声望
+func GetKarma(userID int64) (float64, error) {
+	karma, err := client.Get(getRedisKey(KeyUserKarmaPF + strconv.FormatInt(userID, 10))).Float64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return karma, err
+}
+
+// IncrKarma 累加用户声望
+func IncrKarma(userID int64, delta float64) error {
+	return client.IncrByFloat(getRedisKey(KeyUserKarmaPF+strconv.FormatInt(userID, 10)), delta).Err()
+}
+
+// GetCoVoteStats 查询用户和帖子最近的投票人的共同投票情况，同时返回用户自己的投票总数
+func GetCoVoteStats(userID, postID string) (stats []CoVoteStat, totalVotes int64, err error) {
+	voters, err := client.ZRange(getRedisKey(KeyAbuseVotersZSetPF+postID), 0, -1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	coVoteKey := getRedisKey(KeyAbuseCoVoteZSetPF + userID)
+	pipeline := client.Pipeline()
+	totalCmd := pipeline.Get(getRedisKey(KeyAbuseVoteCountPF + userID))
+	coVoteCmds := make([]*redis.FloatCmd, 0, len(voters))
+	countCmds := make([]*redis.StringCmd, 0, len(voters))
+	others := make([]string, 0, len(voters))
+	for _, voter := range voters {
+		if voter == userID {
+			continue
+		}
+		others = append(others, voter)
+		coVoteCmds = append(coVoteCmds, pipeline.ZScore(coVoteKey, voter))
+		countCmds = append(countCmds, pipeline.Get(getRedisKey(KeyAbuseVoteCountPF+voter)))
+	}
+	// 没有记录的 key 返回 nil，按 0 处理
+	if _, err = pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, 0, err
+	}
+
+	totalVotes, _ = totalCmd.Int64()
+	stats = make([]CoVoteStat, 0, len(others))
+	for i, other := range others {
+		coVotes := int64(coVoteCmds[i].Val())
+		if coVotes == 0 {
+			continue
+		}
+		otherTotal, _ := countCmds[i].Int64()
+		stats = append(stats, CoVoteStat{UserID: other, CoVotes: coVotes, TotalVotes: otherTotal})
+	}
+	return stats, totalVotes, nil
+}
+
+// RecordCoVotes 记录一次新投票：和帖子最近的投票人互相累加共同投票次数，并把自己加入最近的投票人
+func RecordCoVotes(userID, postID string, recentVoters int64) error {
+	votersKey := getRedisKey(KeyAbuseVotersZSetPF + postID)
+	voters, err := client.ZRange(votersKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	pipeline := client.Pipeline()
+	coVoteKey := getRedisKey(KeyAbuseCoVoteZSetPF + userID)
+	for _, voter := range voters {
+		if voter == userID {
+			continue
+		}
+		otherKey := getRedisKey(KeyAbuseCoVoteZSetPF + voter)
+		pipeline.ZIncrBy(coVoteKey, 1, voter)
+		pipeline.ZIncrBy(otherKey, 1, userID)
+		pipeline.Expire(otherKey, abuseTTL)
+	}
+	pipeline.Expire(coVoteKey, abuseTTL)
+
+	countKey := getRedisKey(KeyAbuseVoteCountPF + userID)
+	pipeline.Incr(countKey)
+	pipeline.Expire(countKey, abuseTTL)
+
+	// 只保留最近的投票人
+	pipeline.ZAdd(votersKey, redis.Z{
+		Score:  float64(time.Now().UnixNano()),
+		Member: userID,
+	})
+	pipeline.ZRemRangeByRank(votersKey, 0, -recentVoters-1)
+	pipeline.Expire(votersKey, oneWeekInSeconds*time.Second) // 超过投票期的帖子不会再有新投票
+	_, err = pipeline.Exec()
+	return err
+}
+
+// MarkSuspiciousPair 记录疑似结团的账号对
+func MarkSuspiciousPair(userA, userB int64, coVotes int64) error {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	member := strconv.FormatInt(userA, 10) + ":" + strconv.FormatInt(userB, 10)
+	return client.ZAdd(getRedisKey(KeyAbusePairZSet), redis.Z{
+		Score:  float64(coVotes),
+		Member: member,
+	}).Err()
+}
+
+// GetSuspiciousPairs 按共同投票数倒序查询疑似结团的账号对
+func GetSuspiciousPairs(limit int64) ([]redis.Z, error) {
+	return client.ZRevRangeWithScores(getRedisKey(KeyAbusePairZSet), 0, limit-1).Result()
+}
@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"web-app/pkg/bloom"
+
+	"github.com/go-redis/redis"
+)
+
+// bloomKey 集群模式下和重建用的临时 key 在同一个 slot，才能 RENAME
+func bloomKey(kind string) string {
+	return getSlotKey("bloom:"+kind, KeyBloomFilter+":"+kind)
+}
+
+// bloomRebuildKey 重建时先写入临时 key，完成后再替换，重建期间旧的过滤器仍然可用
+func bloomRebuildKey(kind string) string {
+	return bloomKey(kind) + ":rebuild"
+}
+
+// bloomBuiltOffset 构建完成的标记位，放在位数组之后，只在重建完成时设置
+// key 被删除或者淘汰之后 BloomAdd 会重新创建 key，但是不会有这个标记，据此判断过滤器不完整
+func bloomBuiltOffset(p bloom.Params) int64 {
+	return int64(p.M)
+}
+
+func bloomParamsValue(p bloom.Params) string {
+	return fmt.Sprintf("%d:%d", p.M, p.K)
+}
+
+func bloomAdd(key string, p bloom.Params, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for _, id := range ids {
+		for _, offset := range p.Locations([]byte(strconv.FormatInt(id, 10))) {
+			pipeline.SetBit(key, int64(offset), 1)
+		}
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// BloomAdd 把 id 加入布隆过滤器
+func BloomAdd(kind string, p bloom.Params, ids ...int64) error {
+	return bloomAdd(bloomKey(kind), p, ids)
+}
+
+// BloomExists id 是否可能存在，返回 false 时一定不存在
+// 过滤器没有按参数 p 构建完成（参数变化、key 被删除或者淘汰、Redis 数据丢失）时返回 redis.Nil，
+// 这时过滤器中缺少已有的 id，不能用来拦截
+func BloomExists(kind string, p bloom.Params, id int64) (bool, error) {
+	key := bloomKey(kind)
+	pipeline := client.Pipeline()
+	meta := pipeline.HGet(getRedisKey(KeyBloomMetaHash), kind)
+	built := pipeline.GetBit(key, bloomBuiltOffset(p))
+	cmds := make([]*redis.IntCmd, 0, p.K)
+	for _, offset := range p.Locations([]byte(strconv.FormatInt(id, 10))) {
+		cmds = append(cmds, pipeline.GetBit(key, int64(offset)))
+	}
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return false, err
+	}
+	if meta.Val() != bloomParamsValue(p) || built.Val() == 0 {
+		return false, redis.Nil
+	}
+	for _, cmd := range cmds {
+		if cmd.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// BloomReady 布隆过滤器是否已经按参数 p 构建完成，并且之后没有被删除
+func BloomReady(kind string, p bloom.Params) (bool, error) {
+	pipeline := client.Pipeline()
+	meta := pipeline.HGet(getRedisKey(KeyBloomMetaHash), kind)
+	built := pipeline.GetBit(bloomKey(kind), bloomBuiltOffset(p))
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return false, err
+	}
+	return meta.Val() == bloomParamsValue(p) && built.Val() == 1, nil
+}
+
+// BloomRebuildStart 清空重建用的临时 key
+func BloomRebuildStart(kind string) error {
+	return client.Del(bloomRebuildKey(kind)).Err()
+}
+
+// BloomRebuildAdd 重建时把一批 id 写入临时 key
+func BloomRebuildAdd(kind string, p bloom.Params, ids []int64) error {
+	return bloomAdd(bloomRebuildKey(kind), p, ids)
+}
+
+// BloomRebuildFinish 用临时 key 替换布隆过滤器并记录参数
+func BloomRebuildFinish(kind string, p bloom.Params) error {
+	// 设置构建完成的标记，同时保证没有任何 id 时临时 key 也存在，RENAME 可以执行
+	if err := client.SetBit(bloomRebuildKey(kind), bloomBuiltOffset(p), 1).Err(); err != nil {
+		return err
+	}
+	pipeline := client.TxPipeline()
+	pipeline.Rename(bloomRebuildKey(kind), bloomKey(kind))
+	pipeline.HSet(getRedisKey(KeyBloomMetaHash), kind, bloomParamsValue(p))
+	_, err := pipeline.Exec()
+	return err
+}
+
+// BloomBitCount 布隆过滤器中已经置为 1 的位数，用于估算误判率
+func BloomBitCount(kind string) (int64, error) {
+	return client.BitCount(bloomKey(kind), nil).Result()
+}
+
+// BloomRebuildLock 多个实例同时启动时只由一个实例重建
+func BloomRebuildLock(kind string, ttl time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyCacheLock+"bloom:"+kind), "1", ttl).Result()
+}
+
+// BloomRebuildUnlock 释放重建锁
+func BloomRebuildUnlock(kind string) error {
+	return client.Del(getRedisKey(KeyCacheLock + "bloom:" + kind)).Err()
+}
@@ -0,0 +1,95 @@
+package redis
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"web-app/pkg/breaker"
+
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// ErrorUnavailable Redis 不可用：熔断器打开，或者网络、连接池出错
+var ErrorUnavailable = errors.New("redis 不可用")
+
+var (
+	cb = breaker.New(0, 0, nil)
+	// recoverHooks 熔断器从打开恢复到关闭时调用
+	recoverHooks []func()
+)
+
+// initBreaker 按配置创建熔断器，连续 failures 次不可用后打开，打开 openTimeout 后放行一个探测请求
+func initBreaker(failures int, openTimeout time.Duration) {
+	cb = breaker.New(failures, openTimeout, func(from, to breaker.State) {
+		switch to {
+		case breaker.StateOpen:
+			zap.L().Warn("redis circuit breaker opened", zap.String("from", from.String()))
+		case breaker.StateClosed:
+			zap.L().Info("redis circuit breaker closed, redis recovered")
+			for _, fn := range recoverHooks {
+				go fn()
+			}
+		}
+	})
+}
+
+// OnRecover 注册 Redis 恢复后的回调，比如重放本地缓冲的投票
+func OnRecover(fn func()) {
+	recoverHooks = append(recoverHooks, fn)
+}
+
+// Available 熔断器没有打开时认为 Redis 可用
+func Available() bool {
+	return cb.State() != breaker.StateOpen
+}
+
+// IsUnavailable err 是否表示 Redis 不可用，key 不存在和命令本身的错误（比如类型不对）不算
+func IsUnavailable(err error) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+	if errors.Is(err, ErrorUnavailable) || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	// 正在加载数据、主从切换、集群故障时 Redis 返回的错误
+	for _, prefix := range []string{"LOADING", "READONLY", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	// 客户端自身的错误：连接池超时、连接已关闭
+	return strings.HasPrefix(msg, "redis: ")
+}
+
+// BreakerStats 熔断器的状态
+func BreakerStats() breaker.Stats {
+	return cb.Stats()
+}
+
+// limiter 实现 redis.Limiter，每次从连接池取连接前检查熔断器，熔断器打开时直接返回 ErrorUnavailable，不再等待超时
+// 集群模式下每个节点的客户端共用同一个熔断器；Pub/Sub 使用单独的连接，不经过熔断器
+type limiter struct{}
+
+func (limiter) Allow() error {
+	if cb.Allow() != nil {
+		return ErrorUnavailable
+	}
+	return nil
+}
+
+func (limiter) ReportResult(err error) {
+	cb.Done(IsUnavailable(err))
+}
+
+// Ping 检查 Redis 是否可以连接，熔断器打开时直接返回 ErrorUnavailable
+func Ping() error {
+	return client.Ping().Err()
+}
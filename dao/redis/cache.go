@@ -1,13 +1,13 @@
 package redis
 
 import (
-	"encoding/json"
+	"context"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
-	"web-app/models"
-
-	"github.com/go-redis/redis"
-	"go.uber.org/zap"
+	"web-app/pkg/cache"
+	"web-app/pkg/metrics"
 )
 
 // 缓存过期时间配置
@@ -15,401 +15,229 @@ const (
 	PostDetailCacheExpire    = 30 * time.Minute // 帖子详情缓存30分钟
 	UserInfoCacheExpire      = 60 * time.Minute // 用户信息缓存1小时
 	CommunityInfoCacheExpire = 2 * time.Hour    // 社区信息缓存2小时
-	PostListCacheExpire      = 5 * time.Minute  // 帖子列表缓存5分钟
+	PostListCacheExpire      = 5 * time.Minute  // 帖子列表缓存5分钟，列表有变化时版本号加一，不需要等到过期
+
+	NegativeCacheExpire = time.Minute // 不存在的数据缓存1分钟
 
-	CacheLockExpire = 10 * time.Second      // 缓存锁过期时间
-	CacheLockRetry  = 50 * time.Millisecond // 缓存锁重试间隔
+	CacheLockExpire   = 10 * time.Second      // 缓存锁过期时间
+	CacheLockRetry    = 50 * time.Millisecond // 缓存锁重试间隔
+	CacheExpireJitter = 0.1                   // 过期时间随机浮动 ±10%，避免同时过期
+)
+
+// 缓存统计的滑动窗口中每个指标的下标
+const (
+	statLocalHit = iota
+	statHit
+	statMiss
+	statError
+	statCount
 )
 
-// CacheStats 缓存统计信息
+// CacheStats 缓存统计信息，并发安全
+// LocalHitCount 是进程内一级缓存的命中数，HitCount/MissCount 是 Redis 的命中数和未命中数
+// window 按时间片记录同样的指标，用于计算最近一段时间的命中率
 type CacheStats struct {
-	HitCount   int64 `json:"hit_count"`
-	MissCount  int64 `json:"miss_count"`
-	ErrorCount int64 `json:"error_count"`
+	LocalHitCount atomic.Int64
+	HitCount      atomic.Int64
+	MissCount     atomic.Int64
+	ErrorCount    atomic.Int64
+	window        *metrics.Window
+}
+
+// CacheStatsSnapshot 某一时间段内的缓存统计
+type CacheStatsSnapshot struct {
+	LocalHitCount int64 `json:"l1_hit_count"`
+	HitCount      int64 `json:"hit_count"`
+	MissCount     int64 `json:"miss_count"`
+	ErrorCount    int64 `json:"error_count"`
+}
+
+func newCacheStats() *CacheStats {
+	return &CacheStats{window: metrics.NewWindow(statCount)}
 }
 
 // 全局缓存统计
 var (
-	PostCacheStats      = &CacheStats{}
-	UserCacheStats      = &CacheStats{}
-	CommunityCacheStats = &CacheStats{}
+	PostCacheStats      = newCacheStats()
+	UserCacheStats      = newCacheStats()
+	CommunityCacheStats = newCacheStats()
+	PostListCacheStats  = newCacheStats()
 )
 
-// GetPostDetailFromCache 从缓存获取帖子详情
-func GetPostDetailFromCache(postID int64) (*models.ApiPostDetail, error) {
-	key := getRedisKey(KeyPostDetailPF + strconv.FormatInt(postID, 10))
-
-	// 尝试从缓存获取
-	data, err := client.Get(key).Result()
-	if err == redis.Nil {
-		// 缓存未命中
-		PostCacheStats.MissCount++
-		zap.L().Debug("Post cache miss", zap.Int64("post_id", postID))
-		return nil, nil
-	}
-	if err != nil {
-		// 缓存错误
-		PostCacheStats.ErrorCount++
-		zap.L().Error("Post cache error", zap.Error(err), zap.Int64("post_id", postID))
-		return nil, err
-	}
-
-	// 缓存命中，反序列化数据
-	var postDetail models.ApiPostDetail
-	if err := json.Unmarshal([]byte(data), &postDetail); err != nil {
-		PostCacheStats.ErrorCount++
-		zap.L().Error("Post cache unmarshal error", zap.Error(err), zap.Int64("post_id", postID))
-		return nil, err
-	}
-
-	PostCacheStats.HitCount++
-	zap.L().Debug("Post cache hit", zap.Int64("post_id", postID))
-	return &postDetail, nil
+// RecordLocalHit 记录一级缓存命中次数
+func (s *CacheStats) RecordLocalHit(n int) {
+	s.LocalHitCount.Add(int64(n))
+	s.window.Add(statLocalHit, int64(n))
 }
 
-// SetPostDetailToCache 将帖子详情存入缓存
-func SetPostDetailToCache(postID int64, postDetail *models.ApiPostDetail) error {
-	key := getRedisKey(KeyPostDetailPF + strconv.FormatInt(postID, 10))
-
-	// 序列化数据
-	data, err := json.Marshal(postDetail)
-	if err != nil {
-		zap.L().Error("Post cache marshal error", zap.Error(err), zap.Int64("post_id", postID))
-		return err
-	}
-
-	// 存入缓存
-	err = client.Set(key, data, PostDetailCacheExpire).Err()
-	if err != nil {
-		zap.L().Error("Post cache set error", zap.Error(err), zap.Int64("post_id", postID))
-		return err
-	}
-
-	zap.L().Debug("Post cached successfully",
-		zap.Int64("post_id", postID),
-		zap.Duration("expire", PostDetailCacheExpire))
-	return nil
+// RecordHit 记录命中次数
+func (s *CacheStats) RecordHit(n int) {
+	s.HitCount.Add(int64(n))
+	s.window.Add(statHit, int64(n))
 }
 
-// GetUserFromCache 从缓存获取用户信息
-func GetUserFromCache(userID int64) (*models.User, error) {
-	key := getRedisKey(KeyUserInfoPF + strconv.FormatInt(userID, 10))
-
-	data, err := client.Get(key).Result()
-	if err == redis.Nil {
-		UserCacheStats.MissCount++
-		zap.L().Debug("User cache miss", zap.Int64("user_id", userID))
-		return nil, nil
-	}
-	if err != nil {
-		UserCacheStats.ErrorCount++
-		zap.L().Error("User cache error", zap.Error(err), zap.Int64("user_id", userID))
-		return nil, err
-	}
-
-	var user models.User
-	if err := json.Unmarshal([]byte(data), &user); err != nil {
-		UserCacheStats.ErrorCount++
-		zap.L().Error("User cache unmarshal error", zap.Error(err), zap.Int64("user_id", userID))
-		return nil, err
-	}
-
-	UserCacheStats.HitCount++
-	zap.L().Debug("User cache hit", zap.Int64("user_id", userID))
-	return &user, nil
+// RecordMiss 记录未命中次数
+func (s *CacheStats) RecordMiss(n int) {
+	s.MissCount.Add(int64(n))
+	s.window.Add(statMiss, int64(n))
 }
 
-// SetUserToCache 将用户信息存入缓存
-func SetUserToCache(userID int64, user *models.User) error {
-	key := getRedisKey(KeyUserInfoPF + strconv.FormatInt(userID, 10))
-
-	// 敏感信息脱敏：不缓存密码
-	cacheUser := *user
-	cacheUser.Password = "" // 清空密码字段
-
-	data, err := json.Marshal(cacheUser)
-	if err != nil {
-		zap.L().Error("User cache marshal error", zap.Error(err), zap.Int64("user_id", userID))
-		return err
-	}
-
-	err = client.Set(key, data, UserInfoCacheExpire).Err()
-	if err != nil {
-		zap.L().Error("User cache set error", zap.Error(err), zap.Int64("user_id", userID))
-		return err
-	}
-
-	zap.L().Debug("User cached successfully",
-		zap.Int64("user_id", userID),
-		zap.Duration("expire", UserInfoCacheExpire))
-	return nil
+// RecordError 记录缓存读写出错
+func (s *CacheStats) RecordError() {
+	s.ErrorCount.Add(1)
+	s.window.Add(statError, 1)
 }
 
-// GetCommunityFromCache 从缓存获取社区信息
-func GetCommunityFromCache(communityID int64) (*models.CommunityDetail, error) {
-	key := getRedisKey(KeyCommunityInfoPF + strconv.FormatInt(communityID, 10))
-
-	data, err := client.Get(key).Result()
-	if err == redis.Nil {
-		CommunityCacheStats.MissCount++
-		zap.L().Debug("Community cache miss", zap.Int64("community_id", communityID))
-		return nil, nil
-	}
-	if err != nil {
-		CommunityCacheStats.ErrorCount++
-		zap.L().Error("Community cache error", zap.Error(err), zap.Int64("community_id", communityID))
-		return nil, err
+// Total 启动以来的累计统计
+func (s *CacheStats) Total() CacheStatsSnapshot {
+	return CacheStatsSnapshot{
+		LocalHitCount: s.LocalHitCount.Load(),
+		HitCount:      s.HitCount.Load(),
+		MissCount:     s.MissCount.Load(),
+		ErrorCount:    s.ErrorCount.Load(),
 	}
+}
 
-	var community models.CommunityDetail
-	if err := json.Unmarshal([]byte(data), &community); err != nil {
-		CommunityCacheStats.ErrorCount++
-		zap.L().Error("Community cache unmarshal error", zap.Error(err), zap.Int64("community_id", communityID))
-		return nil, err
+// Recent 最近 d 时间内的统计，最长 1 小时
+func (s *CacheStats) Recent(d time.Duration) CacheStatsSnapshot {
+	v := s.window.Sum(d)
+	return CacheStatsSnapshot{
+		LocalHitCount: v[statLocalHit],
+		HitCount:      v[statHit],
+		MissCount:     v[statMiss],
+		ErrorCount:    v[statError],
 	}
-
-	CommunityCacheStats.HitCount++
-	zap.L().Debug("Community cache hit", zap.Int64("community_id", communityID))
-	return &community, nil
 }
 
-// SetCommunityToCache 将社区信息存入缓存
-func SetCommunityToCache(communityID int64, community *models.CommunityDetail) error {
-	key := getRedisKey(KeyCommunityInfoPF + strconv.FormatInt(communityID, 10))
-
-	data, err := json.Marshal(community)
-	if err != nil {
-		zap.L().Error("Community cache marshal error", zap.Error(err), zap.Int64("community_id", communityID))
-		return err
-	}
+// PostDetailCacheKey 帖子详情缓存的 key
+func PostDetailCacheKey(postID int64) string {
+	return getRedisKey(KeyPostDetailPF + strconv.FormatInt(postID, 10))
+}
 
-	err = client.Set(key, data, CommunityInfoCacheExpire).Err()
-	if err != nil {
-		zap.L().Error("Community cache set error", zap.Error(err), zap.Int64("community_id", communityID))
-		return err
-	}
+// UserCacheKey 用户信息缓存的 key
+func UserCacheKey(userID int64) string {
+	return getRedisKey(KeyUserInfoPF + strconv.FormatInt(userID, 10))
+}
 
-	zap.L().Debug("Community cached successfully",
-		zap.Int64("community_id", communityID),
-		zap.Duration("expire", CommunityInfoCacheExpire))
-	return nil
+// CommunityCacheKey 社区信息缓存的 key
+func CommunityCacheKey(communityID int64) string {
+	return getRedisKey(KeyCommunityInfoPF + strconv.FormatInt(communityID, 10))
 }
 
-// BatchGetUsersFromCache 批量从缓存获取用户信息
-func BatchGetUsersFromCache(userIDs []int64) (map[int64]*models.User, []int64, error) {
-	if len(userIDs) == 0 {
-		return make(map[int64]*models.User), []int64{}, nil
-	}
+// cacheStore 用 Redis 实现 cache.Store
+type cacheStore struct{}
 
-	// 构建所有key
-	keys := make([]string, len(userIDs))
-	for i, userID := range userIDs {
-		keys[i] = getRedisKey(KeyUserInfoPF + strconv.FormatInt(userID, 10))
-	}
+// CacheStore 供 cache.Loader 使用的 Redis 存储
+func CacheStore() cache.Store {
+	return cacheStore{}
+}
 
-	// 批量获取
-	values, err := client.MGet(keys...).Result()
+func (cacheStore) MGet(keys []string) ([][]byte, error) {
+	values, err := mget(keys)
 	if err != nil {
-		UserCacheStats.ErrorCount++
-		zap.L().Error("Batch get users cache error", zap.Error(err))
-		return nil, userIDs, err
+		return nil, err
 	}
-
-	cached := make(map[int64]*models.User)
-	missed := make([]int64, 0)
-
-	for i, value := range values {
-		userID := userIDs[i]
-		if value == nil {
-			// 缓存未命中
-			missed = append(missed, userID)
-			UserCacheStats.MissCount++
-		} else {
-			// 缓存命中，反序列化
-			var user models.User
-			if err := json.Unmarshal([]byte(value.(string)), &user); err != nil {
-				UserCacheStats.ErrorCount++
-				zap.L().Error("User cache unmarshal error", zap.Error(err), zap.Int64("user_id", userID))
-				missed = append(missed, userID)
-			} else {
-				cached[userID] = &user
-				UserCacheStats.HitCount++
-			}
+	result := make([][]byte, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			result[i] = []byte(s)
 		}
 	}
-
-	zap.L().Debug("Batch get users from cache",
-		zap.Int("total", len(userIDs)),
-		zap.Int("hit", len(cached)),
-		zap.Int("miss", len(missed)))
-
-	return cached, missed, nil
+	return result, nil
 }
 
-// BatchSetUsersToCache 批量将用户信息存入缓存
-func BatchSetUsersToCache(users map[int64]*models.User) error {
-	if len(users) == 0 {
-		return nil
-	}
-
+func (cacheStore) MSet(items []cache.Item) error {
 	pipeline := client.Pipeline()
-	for userID, user := range users {
-		key := getRedisKey(KeyUserInfoPF + strconv.FormatInt(userID, 10))
-
-		// 敏感信息脱敏
-		cacheUser := *user
-		cacheUser.Password = ""
-
-		data, err := json.Marshal(cacheUser)
-		if err != nil {
-			zap.L().Error("User cache marshal error", zap.Error(err), zap.Int64("user_id", userID))
-			continue
-		}
-
-		pipeline.Set(key, data, UserInfoCacheExpire)
+	for _, item := range items {
+		pipeline.Set(item.Key, item.Value, item.TTL)
 	}
-
 	_, err := pipeline.Exec()
-	if err != nil {
-		zap.L().Error("Batch set users cache error", zap.Error(err))
-		return err
-	}
-
-	zap.L().Debug("Batch set users to cache", zap.Int("count", len(users)))
-	return nil
+	return err
 }
 
-// BatchGetCommunitiesFromCache 批量从缓存获取社区信息
-func BatchGetCommunitiesFromCache(communityIDs []int64) (map[int64]*models.CommunityDetail, []int64, error) {
-	if len(communityIDs) == 0 {
-		return make(map[int64]*models.CommunityDetail), []int64{}, nil
-	}
-
-	keys := make([]string, len(communityIDs))
-	for i, communityID := range communityIDs {
-		keys[i] = getRedisKey(KeyCommunityInfoPF + strconv.FormatInt(communityID, 10))
-	}
-
-	values, err := client.MGet(keys...).Result()
-	if err != nil {
-		CommunityCacheStats.ErrorCount++
-		zap.L().Error("Batch get communities cache error", zap.Error(err))
-		return nil, communityIDs, err
-	}
-
-	cached := make(map[int64]*models.CommunityDetail)
-	missed := make([]int64, 0)
-
-	for i, value := range values {
-		communityID := communityIDs[i]
-		if value == nil {
-			missed = append(missed, communityID)
-			CommunityCacheStats.MissCount++
-		} else {
-			var community models.CommunityDetail
-			if err := json.Unmarshal([]byte(value.(string)), &community); err != nil {
-				CommunityCacheStats.ErrorCount++
-				zap.L().Error("Community cache unmarshal error", zap.Error(err), zap.Int64("community_id", communityID))
-				missed = append(missed, communityID)
-			} else {
-				cached[communityID] = &community
-				CommunityCacheStats.HitCount++
-			}
-		}
-	}
-
-	zap.L().Debug("Batch get communities from cache",
-		zap.Int("total", len(communityIDs)),
-		zap.Int("hit", len(cached)),
-		zap.Int("miss", len(missed)))
-
-	return cached, missed, nil
+func (cacheStore) Del(keys ...string) error {
+	return deleteCache(keys...)
 }
 
-// BatchSetCommunitiesToCache 批量将社区信息存入缓存
-func BatchSetCommunitiesToCache(communities map[int64]*models.CommunityDetail) error {
-	if len(communities) == 0 {
-		return nil
-	}
-
-	pipeline := client.Pipeline()
-	for communityID, community := range communities {
-		key := getRedisKey(KeyCommunityInfoPF + strconv.FormatInt(communityID, 10))
-
-		data, err := json.Marshal(community)
-		if err != nil {
-			zap.L().Error("Community cache marshal error", zap.Error(err), zap.Int64("community_id", communityID))
-			continue
-		}
-
-		pipeline.Set(key, data, CommunityInfoCacheExpire)
-	}
-
-	_, err := pipeline.Exec()
-	if err != nil {
-		zap.L().Error("Batch set communities cache error", zap.Error(err))
-		return err
-	}
+func (cacheStore) Lock(key string, ttl time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyCacheLock+key), "1", ttl).Result()
+}
 
-	zap.L().Debug("Batch set communities to cache", zap.Int("count", len(communities)))
-	return nil
+func (cacheStore) Unlock(key string) error {
+	return client.Del(getRedisKey(KeyCacheLock + key)).Err()
 }
 
 // DeletePostCache 删除帖子缓存（用于数据更新时）
 func DeletePostCache(postID int64) error {
-	key := getRedisKey(KeyPostDetailPF + strconv.FormatInt(postID, 10))
-	return client.Del(key).Err()
+	return deleteCache(PostDetailCacheKey(postID))
 }
 
 // DeleteUserCache 删除用户缓存
 func DeleteUserCache(userID int64) error {
-	key := getRedisKey(KeyUserInfoPF + strconv.FormatInt(userID, 10))
-	return client.Del(key).Err()
+	return deleteCache(UserCacheKey(userID))
 }
 
 // DeleteCommunityCache 删除社区缓存
 func DeleteCommunityCache(communityID int64) error {
-	key := getRedisKey(KeyCommunityInfoPF + strconv.FormatInt(communityID, 10))
-	return client.Del(key).Err()
+	return deleteCache(CommunityCacheKey(communityID))
 }
 
-// GetCacheStats 获取缓存统计信息
-func GetCacheStats() map[string]*CacheStats {
-	return map[string]*CacheStats{
-		"post":      PostCacheStats,
-		"user":      UserCacheStats,
-		"community": CommunityCacheStats,
+var cacheInvalidateHook func(keys []string)
+
+// OnCacheInvalidate 注册删除一级缓存的回调，本实例删除缓存以及收到其他实例的失效通知时调用
+func OnCacheInvalidate(fn func(keys []string)) {
+	cacheInvalidateHook = fn
+}
+
+// deleteCache 删除 Redis 中的缓存，同时删除本实例的一级缓存并通知其他实例
+func deleteCache(keys ...string) error {
+	if cacheInvalidateHook != nil {
+		cacheInvalidateHook(keys)
 	}
+	if err := del(keys...); err != nil {
+		return err
+	}
+	return client.Publish(getRedisKey(KeyCacheInvalidateChannel), strings.Join(keys, "\n")).Err()
 }
 
-// TryLock 尝试获取分布式锁（防止缓存击穿）
-func TryLock(resourceID string) (bool, error) {
-	key := getRedisKey(KeyCacheLock + resourceID)
+// SubscribeCacheInvalidation 订阅缓存失效通知，阻塞直到 ctx 被取消
+// 每次订阅成功后先调用 onSubscribed：断线期间的通知已经丢失，需要清空一级缓存
+func SubscribeCacheInvalidation(ctx context.Context, onSubscribed func()) error {
+	pubsub := client.Subscribe(getRedisKey(KeyCacheInvalidateChannel))
+	defer pubsub.Close()
 
-	// 使用SET NX EX命令原子性地设置锁
-	result := client.Set(key, "1", CacheLockExpire)
-	if result.Err() != nil {
-		return false, result.Err()
+	if _, err := pubsub.Receive(); err != nil {
+		return err
 	}
+	onSubscribed()
 
-	// 检查是否成功获取锁
-	return result.Val() == "OK", nil
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if cacheInvalidateHook != nil {
+				cacheInvalidateHook(strings.Split(msg.Payload, "\n"))
+			}
+		}
+	}
 }
 
-// ReleaseLock 释放分布式锁
-func ReleaseLock(resourceID string) error {
-	key := getRedisKey(KeyCacheLock + resourceID)
-	return client.Del(key).Err()
+// GetCacheStats 获取缓存统计信息
+func GetCacheStats() map[string]*CacheStats {
+	return map[string]*CacheStats{
+		"post":      PostCacheStats,
+		"user":      UserCacheStats,
+		"community": CommunityCacheStats,
+		"post_list": PostListCacheStats,
+	}
 }
 
-// WarmUpCache 缓存预热（可选功能）
-func WarmUpCache() error {
-	zap.L().Info("Starting cache warm up...")
-
-	// 这里可以预热一些热点数据
-	// 比如最热门的帖子、活跃用户等
-	// 具体实现可以根据业务需求调整
-
-	zap.L().Info("Cache warm up completed")
-	return nil
+// TryWarmUpLock 多个实例中同一时间只有一个实例做缓存预热，锁在 ttl 后自动过期
+func TryWarmUpLock(ttl time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyCacheLock+"warmup"), "1", ttl).Result()
 }
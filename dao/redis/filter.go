@@ -0,0 +1,70 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// CheckDuplicateContent 判断作者在窗口期内是否发过相同内容，没有发过则记录下来
+func CheckDuplicateContent(authorID int64, hash string, window time.Duration) (bool, error) {
+	key := getRedisKey(KeyFilterDupPF + strconv.FormatInt(authorID, 10))
+	now := time.Now()
+
+	pipeline := client.TxPipeline()
+	// 清理窗口期之外的记录
+	pipeline.ZRemRangeByScore(key, "-inf", strconv.FormatInt(now.Add(-window).Unix(), 10))
+	// ZAdd NX 返回0说明窗口期内已经存在相同内容
+	added := pipeline.ZAddNX(key, redis.Z{
+		Score:  float64(now.Unix()),
+		Member: hash,
+	})
+	pipeline.Expire(key, window)
+	if _, err := pipeline.Exec(); err != nil {
+		return false, err
+	}
+	return added.Val() == 0, nil
+}
+
+// IncrPostVelocity 累加作者在统计窗口内的发帖次数并返回累加后的值
+func IncrPostVelocity(authorID int64, window time.Duration) (int64, error) {
+	key := getRedisKey(KeyFilterRatePF + strconv.FormatInt(authorID, 10))
+	count, err := client.Incr(key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		// 第一次计数时设置窗口过期时间
+		client.Expire(key, window)
+	}
+	return count, nil
+}
+
+// AddPostToReview 把帖子加入人工审核队列
+func AddPostToReview(postID int64) error {
+	return client.ZAdd(getRedisKey(KeyPostReviewZSet), redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: postID,
+	}).Err()
+}
+
+// GetReviewPostIDs 按进入审核的时间分页获取待审核的帖子id，先进入的在前
+func GetReviewPostIDs(page, size int64) ([]string, error) {
+	start := (page - 1) * size
+	return client.ZRange(getRedisKey(KeyPostReviewZSet), start, start+size-1).Result()
+}
+
+// RemovePostFromReview 审核完成后把帖子移出审核队列
+func RemovePostFromReview(postID int64) error {
+	return client.ZRem(getRedisKey(KeyPostReviewZSet), postID).Err()
+}
+
+// IsPostRanked 帖子是否已经在排行榜中，编辑后重新进入审核的帖子在审核前已经发布过
+func IsPostRanked(postID int64) (bool, error) {
+	err := client.ZScore(getRankKey(KeyPostTimeZSet), strconv.FormatInt(postID, 10)).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
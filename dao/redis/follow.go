@@ -0,0 +1,182 @@
+package redis
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 关注动态采用推拉结合的方式：
+// 普通作者发帖时把帖子推送到每个粉丝的收件箱（写扩散）
+// 粉丝数超过阈值的大V发帖只写自己的发帖列表，粉丝读取动态时再去拉取合并（读扩散）
+
+// Follow 记录关注关系，已经存在的关系不修改关注时间，可以重复调用
+// 返回粉丝列表中是否新加入了 followerID
+func Follow(userID, followerID int64) (bool, error) {
+	now := float64(time.Now().Unix())
+	pipeline := client.TxPipeline()
+	added := pipeline.ZAddNX(getRedisKey(KeyUserFollowersZSetPF+strconv.FormatInt(userID, 10)), redis.Z{
+		Score:  now,
+		Member: followerID,
+	})
+	pipeline.ZAddNX(getRedisKey(KeyUserFollowingZSetPF+strconv.FormatInt(followerID, 10)), redis.Z{
+		Score:  now,
+		Member: userID,
+	})
+	_, err := pipeline.Exec()
+	return added.Val() > 0, err
+}
+
+// Unfollow 删除关注关系，可以重复调用，返回粉丝列表中是否删除了 followerID
+func Unfollow(userID, followerID int64) (bool, error) {
+	pipeline := client.TxPipeline()
+	removed := pipeline.ZRem(getRedisKey(KeyUserFollowersZSetPF+strconv.FormatInt(userID, 10)), followerID)
+	pipeline.ZRem(getRedisKey(KeyUserFollowingZSetPF+strconv.FormatInt(followerID, 10)), userID)
+	_, err := pipeline.Exec()
+	return removed.Val() > 0, err
+}
+
+// GetFollowerCount 获取用户的粉丝数
+func GetFollowerCount(userID int64) (int64, error) {
+	return client.ZCard(getRedisKey(KeyUserFollowersZSetPF + strconv.FormatInt(userID, 10))).Result()
+}
+
+// GetFollowerIDs 获取用户的全部粉丝ID
+func GetFollowerIDs(userID int64) ([]string, error) {
+	return client.ZRange(getRedisKey(KeyUserFollowersZSetPF+strconv.FormatInt(userID, 10)), 0, -1).Result()
+}
+
+// GetFollowingIDs 获取用户关注的全部用户ID
+func GetFollowingIDs(followerID int64) ([]string, error) {
+	return client.ZRange(getRedisKey(KeyUserFollowingZSetPF+strconv.FormatInt(followerID, 10)), 0, -1).Result()
+}
+
+// FilterBigAuthors 从给定的作者中筛选出粉丝数超过阈值的作者
+func FilterBigAuthors(authorIDs []string, threshold int64) ([]string, error) {
+	if len(authorIDs) == 0 {
+		return nil, nil
+	}
+	pipeline := client.Pipeline()
+	for _, id := range authorIDs {
+		pipeline.ZCard(getRedisKey(KeyUserFollowersZSetPF + id))
+	}
+	cmders, err := pipeline.Exec()
+	if err != nil {
+		return nil, err
+	}
+	big := make([]string, 0)
+	for i, cmder := range cmders {
+		if cmder.(*redis.IntCmd).Val() > threshold {
+			big = append(big, authorIDs[i])
+		}
+	}
+	return big, nil
+}
+
+// AddUserPost 记录作者发的帖子
+func AddUserPost(authorID, postID int64, publishTime time.Time) error {
+	return client.ZAdd(getRedisKey(KeyUserPostsZSetPF+strconv.FormatInt(authorID, 10)), redis.Z{
+		Score:  float64(publishTime.Unix()),
+		Member: postID,
+	}).Err()
+}
+
+// GetUserPostIDs 按发帖时间倒序分页获取作者的帖子ID
+func GetUserPostIDs(authorID, page, size int64) ([]string, error) {
+	return getIDsFormKey(getRedisKey(KeyUserPostsZSetPF+strconv.FormatInt(authorID, 10)), page, size)
+}
+
+// PushToInboxes 把帖子推送到粉丝的收件箱，收件箱只保留最新的 inboxSize 条
+func PushToInboxes(followerIDs []string, postID int64, publishTime time.Time, inboxSize int64) error {
+	if len(followerIDs) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for _, id := range followerIDs {
+		key := getRedisKey(KeyFeedInboxZSetPF + id)
+		pipeline.ZAdd(key, redis.Z{
+			Score:  float64(publishTime.Unix()),
+			Member: postID,
+		})
+		pipeline.ZRemRangeByRank(key, 0, -(inboxSize + 1))
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// BackfillInbox 新关注某个作者时，把作者最近的帖子补进收件箱
+func BackfillInbox(followerID, authorID int64, inboxSize int64) error {
+	posts, err := client.ZRevRangeWithScores(
+		getRedisKey(KeyUserPostsZSetPF+strconv.FormatInt(authorID, 10)), 0, inboxSize-1).Result()
+	if err != nil || len(posts) == 0 {
+		return err
+	}
+	key := getRedisKey(KeyFeedInboxZSetPF + strconv.FormatInt(followerID, 10))
+	pipeline := client.Pipeline()
+	pipeline.ZAdd(key, posts...)
+	pipeline.ZRemRangeByRank(key, 0, -(inboxSize + 1))
+	_, err = pipeline.Exec()
+	return err
+}
+
+// RemoveAuthorFromInbox 取消关注时把该作者的帖子从收件箱中移除
+func RemoveAuthorFromInbox(followerID, authorID int64, inboxSize int64) error {
+	postIDs, err := client.ZRevRange(
+		getRedisKey(KeyUserPostsZSetPF+strconv.FormatInt(authorID, 10)), 0, inboxSize-1).Result()
+	if err != nil || len(postIDs) == 0 {
+		return err
+	}
+	members := make([]interface{}, len(postIDs))
+	for i, id := range postIDs {
+		members[i] = id
+	}
+	return client.ZRem(getRedisKey(KeyFeedInboxZSetPF+strconv.FormatInt(followerID, 10)), members...).Err()
+}
+
+// GetFeedPostIDs 获取关注动态的帖子ID
+// 收件箱中的帖子与需要拉取的大V帖子按发帖时间合并后分页
+func GetFeedPostIDs(userID int64, pullAuthorIDs []string, page, size int64) ([]string, error) {
+	// 取前 page*size 条就足够覆盖当前页
+	limit := page * size
+	pipeline := client.Pipeline()
+	pipeline.ZRevRangeWithScores(getRedisKey(KeyFeedInboxZSetPF+strconv.FormatInt(userID, 10)), 0, limit-1)
+	for _, id := range pullAuthorIDs {
+		pipeline.ZRevRangeWithScores(getRedisKey(KeyUserPostsZSetPF+id), 0, limit-1)
+	}
+	cmders, err := pipeline.Exec()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	merged := make([]redis.Z, 0, limit)
+	for _, cmder := range cmders {
+		for _, z := range cmder.(*redis.ZSliceCmd).Val() {
+			id := z.Member.(string)
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			merged = append(merged, z)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+
+	start := (page - 1) * size
+	if start >= int64(len(merged)) {
+		return []string{}, nil
+	}
+	end := start + size
+	if end > int64(len(merged)) {
+		end = int64(len(merged))
+	}
+	ids := make([]string, 0, end-start)
+	for _, z := range merged[start:end] {
+		ids = append(ids, z.Member.(string))
+	}
+	return ids, nil
+}
@@ -11,18 +11,83 @@ const (
 
 	KeyCommunitySetPF = "community:" // set 保存每个分区下帖子的ID
 
+	// 关注及动态相关key
+	KeyUserFollowersZSetPF = "user:followers:" // zset 粉丝及关注时间 前缀 + user_id
+	KeyUserFollowingZSetPF = "user:following:" // zset 关注的人及关注时间 前缀 + user_id
+	KeyUserPostsZSetPF     = "user:posts:"     // zset 作者发的帖子及发帖时间 前缀 + user_id
+	KeyFeedInboxZSetPF     = "feed:inbox:"     // zset 关注动态收件箱 帖子及发帖时间 前缀 + user_id
+
+	// 通知相关key
+	KeyNotifyUnreadPF    = "notify:unread:"    // string 未读通知数 前缀 + user_id
+	KeyNotifyMilestonePF = "notify:milestone:" // string 已经通知过的投票里程碑 前缀 + post_id:里程碑
+
+	// 实时推送相关key
+	KeyStreamChannelPF = "stream:"        // pub/sub 频道 前缀 + topic（post:<id> / community:<id> / user:<id>）
+	KeyStreamTicketPF  = "stream:ticket:" // string 订阅通知的一次性凭证对应的 user_id 前缀 + 凭证
+
+	// 浏览量相关key
+	KeyPostViewsZSet     = "post:views"       // zset 帖子及浏览量
+	KeyPostVisitorsPF    = "post:visitors:"   // hyperloglog 帖子的独立访客 前缀 + post_id
+	KeyPostViewsDirtySet = "post:views:dirty" // set 浏览量有变化、等待写入MySQL的帖子
+
+	// 帖子投票相关key
+	KeyPollCountHashPF = "poll:count:" // hash 每个选项的票数 前缀 + post_id
+	KeyPollVoterHashPF = "poll:voter:" // hash 用户及其选择的选项 前缀 + post_id
+	KeyPollCloseZSet   = "poll:close"  // zset 待截止的投票及截止时间
+
 	// 数据缓存相关key
-	KeyPostDetailPF    = "cache:post:"      // string 帖子详情缓存 前缀 + post_id
-	KeyUserInfoPF      = "cache:user:"      // string 用户信息缓存 前缀 + user_id
-	KeyCommunityInfoPF = "cache:community:" // string 社区信息缓存 前缀 + community_id
-	KeyPostListPF      = "cache:postlist:"  // string 帖子列表缓存 前缀 + page_size_order
+	KeyPostDetailPF    = "cache:post:"        // string 帖子详情缓存 前缀 + post_id
+	KeyUserInfoPF      = "cache:user:"        // string 用户信息缓存 前缀 + user_id
+	KeyCommunityInfoPF = "cache:community:"   // string 社区信息缓存 前缀 + community_id
+	KeyPostListPF      = "cache:postlist:"    // string 帖子列表缓存 前缀 + 范围:版本号:排序:页码:条数
+	KeyPostListGenHash = "cache:postlist:gen" // hash 帖子列表缓存的版本号 all / community:<id>，发帖、投票等操作后加一
+
+	KeyCacheInvalidateChannel = "cache:invalidate" // pub/sub 缓存失效通知，消息是被删除的缓存 key，各实例收到后删除一级缓存
 
 	// 缓存防护相关key
-	KeyBloomFilter = "bloom:filter" // 布隆过滤器
-	KeyCacheLock   = "cache:lock:"  // 缓存锁 前缀 + resource_id
+	KeyBloomFilter   = "bloom:filter" // string(bitmap) 布隆过滤器 前缀 + :post / :user / :community
+	KeyBloomMetaHash = "bloom:meta"   // hash 每个布隆过滤器构建时使用的参数 "m:k"，参数变化后需要重建
+	KeyCacheLock     = "cache:lock:"  // 缓存锁 前缀 + resource_id
+
+	// 内容过滤相关key
+	KeyPostReviewZSet = "post:review"  // zset 待人工审核的帖子及进入审核的时间
+	KeyFilterDupPF    = "filter:dup:"  // zset 作者近期发帖内容的哈希及发帖时间 前缀 + user_id
+	KeyFilterRatePF   = "filter:rate:" // string 作者在统计窗口内的发帖数 前缀 + user_id
+
+	// 刷票检测相关key
+	KeyPostWeightHashPF  = "post:weight:"  // hash 用户投票时的权重 前缀 + post_id
+	KeyUserKarmaPF       = "user:karma:"   // string 用户声望（帖子获得的加权净票数） 前缀 + user_id
+	KeyAbuseIPSetPF      = "abuse:ip:"     // set 用户近期使用过的IP 前缀 + user_id
+	KeyAbuseDeviceSetPF  = "abuse:device:" // set 用户近期使用过的设备 前缀 + user_id
+	KeyAbuseVotersZSetPF = "abuse:voters:" // zset 帖子最近的投票人及投票时间 前缀 + post_id
+	KeyAbuseCoVoteZSetPF = "abuse:covote:" // zset 和该用户共同投过票的用户及次数 前缀 + user_id
+	KeyAbuseVoteCountPF  = "abuse:votes:"  // string 用户的投票次数 前缀 + user_id
+	KeyAbusePairZSet     = "abuse:pairs"   // zset 疑似结团的账号对 "小id:大id" 及共同投票数
 )
 
 // 拼接 redis key 加上前缀
 func getRedisKey(key string) string {
 	return KeyPrefix + key
 }
+
+// getSlotKey 集群模式下在 key 中加上 hash tag，tag 相同的 key 落在同一个 slot，可以在同一个事务、ZINTERSTORE、RENAME 中使用
+// 单机和哨兵模式下不加，和已有数据的 key 保持一致
+func getSlotKey(tag, key string) string {
+	if clusterMode {
+		return KeyPrefix + "{" + tag + "}:" + key
+	}
+	return getRedisKey(key)
+}
+
+// getRankKey 排行榜和社区的帖子集合
+// 按社区排序的 ZINTERSTORE 和重建时的 RENAME 会同时用到这些 key，集群模式下放在同一个 slot
+func getRankKey(key string) string {
+	return getSlotKey("rank", key)
+}
+
+// getPostVoteKey 帖子的投票记录和投票权重，prefix 是 KeyPostVotedZSetPF 或 KeyPostWeightHashPF
+// 集群模式下按帖子打 hash tag：同一个帖子的两个 key 在同一个 slot，可以在同一个事务中修改，
+// 不同帖子的投票记录分散到各个节点，不会都挤在排行榜所在的节点上
+func getPostVoteKey(prefix, postID string) string {
+	return getSlotKey("post:"+postID, prefix+postID)
+}
@@ -0,0 +1,116 @@
+package redis
+
+import (
+	"strings"
+	"testing"
+)
+
+// hashTag 按 Redis 集群的规则取出 key 中参与计算 slot 的部分：第一个 { 和之后第一个 } 之间不为空时只用这一段
+// tag 相同的 key 一定在同一个 slot
+func hashTag(key string) string {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end]
+		}
+	}
+	return key
+}
+
+func withClusterMode(t *testing.T, enabled bool) {
+	t.Helper()
+	old := clusterMode
+	clusterMode = enabled
+	t.Cleanup(func() { clusterMode = old })
+}
+
+func TestSlotKeysStandalone(t *testing.T) {
+	withClusterMode(t, false)
+	// 单机和哨兵模式下 key 不变，和已有数据保持一致
+	tests := []struct {
+		got, want string
+	}{
+		{getRankKey(KeyPostTimeZSet), "bluebell:post:time"},
+		{getRankKey(communityKey(1)), "bluebell:community:1"},
+		{rankRebuildKey(KeyPostScoreZSet), "bluebell:post:score:rebuild"},
+		{getPostVoteKey(KeyPostVotedZSetPF, "42"), "bluebell:post:voted:42"},
+		{getPostVoteKey(KeyPostWeightHashPF, "42"), "bluebell:post:weight:42"},
+		{bloomKey("post"), "bluebell:bloom:filter:post"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("key = %q, want %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestSlotKeysCluster(t *testing.T) {
+	withClusterMode(t, true)
+
+	// 同一组的 key 会在同一个事务、ZINTERSTORE 或者 RENAME 中一起使用，必须在同一个 slot
+	orderKey := getRankKey(KeyPostScoreZSet)
+	groups := []struct {
+		name string
+		keys []string
+	}{
+		{
+			name: "community zinterstore",
+			keys: []string{orderKey, getRankKey(KeyPostTimeZSet), getRankKey(KeyPostViewsZSet),
+				getRankKey(communityKey(1)), orderKey + "1"},
+		},
+		{
+			name: "rank rebuild rename",
+			keys: []string{getRankKey(KeyPostTimeZSet), rankRebuildKey(KeyPostTimeZSet),
+				getRankKey(communityKey(7)), rankRebuildKey(communityKey(7))},
+		},
+		{
+			name: "post votes transaction",
+			keys: []string{getPostVoteKey(KeyPostVotedZSetPF, "42"), getPostVoteKey(KeyPostWeightHashPF, "42")},
+		},
+		{
+			name: "bloom rebuild rename",
+			keys: []string{bloomKey("post"), bloomRebuildKey("post")},
+		},
+	}
+	for _, g := range groups {
+		tag := hashTag(g.keys[0])
+		if tag == g.keys[0] {
+			t.Errorf("%s: %q has no hash tag", g.name, g.keys[0])
+		}
+		for _, key := range g.keys[1:] {
+			if got := hashTag(key); got != tag {
+				t.Errorf("%s: %q has tag %q, want %q", g.name, key, got, tag)
+			}
+		}
+	}
+
+	// 每个帖子的投票记录按帖子分散，不和排行榜挤在同一个 slot
+	rankTag := hashTag(getRankKey(KeyPostScoreZSet))
+	seen := map[string]bool{rankTag: true}
+	for _, id := range []string{"1", "2", "42", "1234567890123"} {
+		tag := hashTag(getPostVoteKey(KeyPostVotedZSetPF, id))
+		if seen[tag] {
+			t.Errorf("votes of post %s share tag %q with another post or the rank keys", id, tag)
+		}
+		seen[tag] = true
+	}
+	if hashTag(bloomKey("post")) == hashTag(bloomKey("user")) {
+		t.Errorf("bloom filters of different kinds should not share a slot")
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"bluebell:{rank}:post:time", "rank"},
+		{"bluebell:{post:1}:post:voted:1", "post:1"},
+		{"bluebell:post:time", "bluebell:post:time"},
+		{"bluebell:{}:x", "bluebell:{}:x"},
+		{"a{b}{c}", "b"},
+	}
+	for _, tt := range tests {
+		if got := hashTag(tt.key); got != tt.want {
+			t.Errorf("hashTag(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
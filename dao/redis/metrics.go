@@ -0,0 +1,167 @@
+package redis
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"web-app/pkg/metrics"
+
+	"github.com/go-redis/redis"
+)
+
+// opLatency 每种 Redis 命令的耗时直方图，pipeline 整体记录为 "pipeline"
+var opLatency sync.Map // string -> *metrics.Histogram
+
+func observeOp(name string, d time.Duration, err error) {
+	h, ok := opLatency.Load(name)
+	if !ok {
+		h, _ = opLatency.LoadOrStore(name, &metrics.Histogram{})
+	}
+	// 查询的 key 不存在不算出错
+	h.(*metrics.Histogram).Observe(d, err != nil && err != redis.Nil)
+}
+
+// instrument 记录经过 client 执行的每个命令和 pipeline 的耗时
+func instrument(c redis.UniversalClient) {
+	c.WrapProcess(func(old func(redis.Cmder) error) func(redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := old(cmd)
+			observeOp(strings.ToLower(cmd.Name()), time.Since(start), err)
+			return err
+		}
+	})
+	c.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := old(cmds)
+			observeOp("pipeline", time.Since(start), err)
+			return err
+		}
+	})
+}
+
+// GetOpLatencyStats 启动以来每种 Redis 命令的耗时分布
+func GetOpLatencyStats() map[string]metrics.HistogramSnapshot {
+	result := make(map[string]metrics.HistogramSnapshot)
+	opLatency.Range(func(name, h interface{}) bool {
+		result[name.(string)] = h.(*metrics.Histogram).Snapshot()
+		return true
+	})
+	return result
+}
+
+// KeyspaceStat 某一类 key 的数量和占用的内存
+type KeyspaceStat struct {
+	Prefix      string `json:"prefix"`
+	Keys        int64  `json:"keys"`
+	SampledKeys int    `json:"sampled_keys"` // 用 MEMORY USAGE 统计了内存的 key 数
+	AvgBytes    int64  `json:"avg_bytes"`
+	MemoryBytes int64  `json:"memory_bytes"` // 平均内存 × key 数的估算值
+}
+
+// Keyspace 按前缀统计的 key 数量和内存
+// 扫描的 key 数达到上限时停止，Keys 和 MemoryBytes 按 DBSIZE 等比例放大，Complete 为 false
+type Keyspace struct {
+	DBSize   int64          `json:"db_size"`
+	Scanned  int64          `json:"scanned"`
+	Complete bool           `json:"complete"`
+	Prefixes []KeyspaceStat `json:"prefixes"`
+}
+
+// keyspacePrefix key 去掉命名空间和 hash tag 后的前两段，例如 cache:post:123 -> cache:post
+func keyspacePrefix(key string) string {
+	key = strings.TrimPrefix(key, KeyPrefix)
+	if strings.HasPrefix(key, "{") {
+		if i := strings.Index(key, "}:"); i > 0 {
+			key = key[i+2:]
+		}
+	}
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) < 2 {
+		return parts[0]
+	}
+	return parts[0] + ":" + parts[1]
+}
+
+// SampleKeyspace 用 SCAN 遍历本项目的 key，最多扫描 maxKeys 个，集群模式下逐个主节点扫描
+// 每个前缀用 MEMORY USAGE 抽样统计 samples 个 key 的内存
+func SampleKeyspace(maxKeys int64, samples int) (*Keyspace, error) {
+	ks := &Keyspace{Complete: true}
+	counts := make(map[string]int64)
+	sampled := make(map[string][]string)
+	var mu sync.Mutex // 集群模式下 ForEachMaster 并发执行
+	err := forEachNode(func(c redis.Cmdable) error {
+		dbSize, err := c.DBSize().Result()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		ks.DBSize += dbSize
+		mu.Unlock()
+		var cursor uint64
+		for {
+			var keys []string
+			keys, cursor, err = c.Scan(cursor, KeyPrefix+"*", 500).Result()
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			for _, key := range keys {
+				prefix := keyspacePrefix(key)
+				counts[prefix]++
+				if len(sampled[prefix]) < samples {
+					sampled[prefix] = append(sampled[prefix], key)
+				}
+			}
+			ks.Scanned += int64(len(keys))
+			full := ks.Scanned >= maxKeys
+			if cursor != 0 && full {
+				ks.Complete = false
+			}
+			mu.Unlock()
+			if cursor == 0 || full {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	dbSize, scanned := ks.DBSize, ks.Scanned
+	// 没有扫描完时按 DBSIZE 放大，DBSIZE 包含其他项目的 key，只是一个粗略的估算
+	scale := 1.0
+	if !ks.Complete && scanned > 0 {
+		scale = float64(dbSize) / float64(scanned)
+	}
+	pipeline := client.Pipeline()
+	usage := make(map[string][]*redis.IntCmd, len(sampled))
+	for prefix, keys := range sampled {
+		for _, key := range keys {
+			usage[prefix] = append(usage[prefix], pipeline.MemoryUsage(key))
+		}
+	}
+	// 抽样期间 key 可能已经过期，单个命令出错不影响统计
+	_, _ = pipeline.Exec()
+
+	for prefix, count := range counts {
+		stat := KeyspaceStat{Prefix: prefix, Keys: int64(float64(count) * scale)}
+		var total int64
+		for _, cmd := range usage[prefix] {
+			if n, err := cmd.Result(); err == nil {
+				total += n
+				stat.SampledKeys++
+			}
+		}
+		if stat.SampledKeys > 0 {
+			stat.AvgBytes = total / int64(stat.SampledKeys)
+			stat.MemoryBytes = stat.AvgBytes * stat.Keys
+		}
+		ks.Prefixes = append(ks.Prefixes, stat)
+	}
+	sort.Slice(ks.Prefixes, func(i, j int) bool {
+		return ks.Prefixes[i].MemoryBytes > ks.Prefixes[j].MemoryBytes
+	})
+	return ks, nil
+}
@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+)
+
+// 未读数以 MySQL 为准，Redis 中的计数只是加速读取
+// key 不存在时由 logic 层从 MySQL 统计后回填
+
+// IncrUnreadCount 未读通知数加一，key 不存在时不创建，等下次读取时回填
+func IncrUnreadCount(userID int64) error {
+	key := getRedisKey(KeyNotifyUnreadPF + strconv.FormatInt(userID, 10))
+	if client.Exists(key).Val() < 1 {
+		return nil
+	}
+	return client.Incr(key).Err()
+}
+
+// DecrUnreadCount 未读通知数减一
+func DecrUnreadCount(userID int64) error {
+	key := getRedisKey(KeyNotifyUnreadPF + strconv.FormatInt(userID, 10))
+	n, err := client.Decr(key).Result()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		// 计数已经不准了，删掉等下次读取时重新统计
+		return client.Del(key).Err()
+	}
+	return nil
+}
+
+// GetUnreadCount 获取未读通知数，key 不存在时 ok 为 false
+func GetUnreadCount(userID int64) (count int64, ok bool, err error) {
+	key := getRedisKey(KeyNotifyUnreadPF + strconv.FormatInt(userID, 10))
+	count, err = client.Get(key).Int64()
+	if err == Nil {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+// SetUnreadCount 设置未读通知数
+func SetUnreadCount(userID, count int64) error {
+	key := getRedisKey(KeyNotifyUnreadPF + strconv.FormatInt(userID, 10))
+	return client.Set(key, count, 0).Err()
+}
+
+// MarkVoteMilestone 标记帖子达到了某个投票里程碑，只有第一次标记时返回 true
+// 帖子只在发布后一周内允许投票，标记保留8天即可
+func MarkVoteMilestone(postID string, milestone int64) (bool, error) {
+	key := getRedisKey(KeyNotifyMilestonePF + postID + ":" + strconv.FormatInt(milestone, 10))
+	return client.SetNX(key, 1, (oneWeekInSeconds+24*3600)*time.Second).Result()
+}
@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// pollVoteScript 原子地记录用户的选择并累加票数，用户已经投过票时返回 0
+// KEYS[1] 投票人 hash  KEYS[2] 票数 hash  ARGV[1] user_id  ARGV[2] 选择的选项  ARGV[3...] 选项序号
+var pollVoteScript = redis.NewScript(`
+if redis.call("HSETNX", KEYS[1], ARGV[1], ARGV[2]) == 0 then
+	return 0
+end
+for i = 3, #ARGV do
+	redis.call("HINCRBY", KEYS[2], ARGV[i], 1)
+end
+return 1
+`)
+
+// VotePoll 记录用户的选择，每个用户只能投一次
+func VotePoll(postID, userID string, choices []int) (bool, error) {
+	strs := make([]string, 0, len(choices))
+	args := make([]interface{}, 0, len(choices)+2)
+	for _, choice := range choices {
+		strs = append(strs, strconv.Itoa(choice))
+	}
+	args = append(args, userID, strings.Join(strs, ","))
+	for _, s := range strs {
+		args = append(args, s)
+	}
+	keys := []string{
+		getRedisKey(KeyPollVoterHashPF + postID),
+		getRedisKey(KeyPollCountHashPF + postID),
+	}
+	ok, err := pollVoteScript.Run(client, keys, args...).Int64()
+	return ok == 1, err
+}
+
+// GetPollChoices 查询用户选择的选项，没有投过票时 voted 为 false
+func GetPollChoices(postID, userID string) (choices []int, voted bool, err error) {
+	val, err := client.HGet(getRedisKey(KeyPollVoterHashPF+postID), userID).Result()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for _, s := range strings.Split(val, ",") {
+		if choice, err := strconv.Atoi(s); err == nil {
+			choices = append(choices, choice)
+		}
+	}
+	return choices, true, nil
+}
+
+// GetPollCounts 查询每个选项的票数及参与人数
+func GetPollCounts(postID string) (counts map[int]int64, voters int64, err error) {
+	pipeline := client.Pipeline()
+	countsCmd := pipeline.HGetAll(getRedisKey(KeyPollCountHashPF + postID))
+	votersCmd := pipeline.HLen(getRedisKey(KeyPollVoterHashPF + postID))
+	if _, err = pipeline.Exec(); err != nil {
+		return nil, 0, err
+	}
+	counts = make(map[int]int64)
+	for field, val := range countsCmd.Val() {
+		idx, err1 := strconv.Atoi(field)
+		count, err2 := strconv.ParseInt(val, 10, 64)
+		if err1 == nil && err2 == nil {
+			counts[idx] = count
+		}
+	}
+	return counts, votersCmd.Val(), nil
+}
+
+// AddPollClose 登记投票的截止时间
+func AddPollClose(postID int64, closeTime time.Time) error {
+	return client.ZAdd(getRedisKey(KeyPollCloseZSet), redis.Z{
+		Score:  float64(closeTime.Unix()),
+		Member: postID,
+	}).Err()
+}
+
+// ClaimDuePolls 取出已经到截止时间的投票
+// 多个实例同时执行时，ZREM 成功的实例才负责保存结果，保证每个投票只被处理一次
+func ClaimDuePolls(now time.Time, limit int64) ([]int64, error) {
+	key := getRedisKey(KeyPollCloseZSet)
+	members, err := client.ZRangeByScore(key, redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]int64, 0, len(members))
+	for _, member := range members {
+		removed, err := client.ZRem(key, member).Result()
+		if err != nil {
+			return claimed, err
+		}
+		if removed == 0 {
+			continue
+		}
+		if postID, err := strconv.ParseInt(member, 10, 64); err == nil {
+			claimed = append(claimed, postID)
+		}
+	}
+	return claimed, nil
+}
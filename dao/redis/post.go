@@ -19,9 +19,12 @@ func getIDsFormKey(key string, page, size int64) ([]string, error) {
 func GetPostIDsInOrder(p *models.ParamsPostList) ([]string, error) {
 	// 从redis 获取ID
 	// 1. 根据用户请求中携带的order参数确定要查询的redis key	
-	key := getRedisKey(KeyPostTimeZSet)
+	key := getRankKey(KeyPostTimeZSet)
 	if p.Order == models.OrderScore{
-		key = getRedisKey(KeyPostScoreZSet)
+		key = getRankKey(KeyPostScoreZSet)
+	}
+	if p.Order == models.OrderViews {
+		key = getRankKey(KeyPostViewsZSet)
 	}
 	// 2. 确定查询的索引的起始点
 	start := (p.Page - 1) * p.Size
@@ -45,7 +48,7 @@ func GetPostVoteData(ids []string) (data []int64, err error) {
 
 	pipeline := client.Pipeline()
 	for _, id := range ids {
-		key := getRedisKey(KeyPostVotedZSetPF + id)
+		key := getPostVoteKey(KeyPostVotedZSetPF, id)
 		pipeline.ZCount(key, "1", "1")
 	}
 	cmders, err := pipeline.Exec()
@@ -63,18 +66,22 @@ func GetPostVoteData(ids []string) (data []int64, err error) {
 // GetCommunityPostIDsInOrder 按社区查询ids
 func GetCommunityPostIDsInOrder(p *models.ParamsCommunityPostList) ([]string, error) {
 
-	orderKey := getRedisKey(KeyPostTimeZSet)
+	orderKey := getRankKey(KeyPostTimeZSet)
 	if p.Order == models.OrderScore {
-		orderKey = getRedisKey(KeyPostScoreZSet)
+		orderKey = getRankKey(KeyPostScoreZSet)
+	}
+	if p.Order == models.OrderViews {
+		orderKey = getRankKey(KeyPostViewsZSet)
 	}
 
 	// 使用 zinterstore 把分区的帖子set与帖子分数的 zset 生成一个新的zset
 	// 针对新的zset 按之前的逻辑取数据
 
 	// 社区的key
-	cKey := getRedisKey(KeyCommunitySetPF + strconv.Itoa(int(p.CommunityID)))
+	cKey := getRankKey(KeyCommunitySetPF + strconv.Itoa(int(p.CommunityID)))
 
 	// 利用缓存key减少zinterstore执行的次数
+	// 集群模式下 orderKey 和 cKey 都带有 {rank} hash tag，拼出来的 key 也在同一个 slot
 	key := orderKey + strconv.Itoa(int(p.CommunityID))
 	if client.Exists(key).Val() < 1 {
 		// 不存在，需要计算
@@ -92,3 +99,63 @@ func GetCommunityPostIDsInOrder(p *models.ParamsCommunityPostList) ([]string, er
 	return getIDsFormKey(key, p.Page, p.Size)
 }
 
+
+// PostCounters 帖子的各项计数
+type PostCounters struct {
+	UpVotes        int64
+	DownVotes      int64
+	Views          int64
+	UniqueVisitors int64
+}
+
+// GetPostCounters 一次 pipeline 批量查询帖子的赞成票数、反对票数、浏览量和独立访客数
+func GetPostCounters(ids []string) ([]PostCounters, error) {
+	pipeline := client.Pipeline()
+	type counterCmds struct {
+		up, down *redis.IntCmd
+		views    *redis.FloatCmd
+		visitors *redis.IntCmd
+	}
+	cmds := make([]counterCmds, 0, len(ids))
+	for _, id := range ids {
+		key := getPostVoteKey(KeyPostVotedZSetPF, id)
+		cmds = append(cmds, counterCmds{
+			up:       pipeline.ZCount(key, "1", "1"),
+			down:     pipeline.ZCount(key, "-1", "-1"),
+			views:    pipeline.ZScore(getRankKey(KeyPostViewsZSet), id),
+			visitors: pipeline.PFCount(getRedisKey(KeyPostVisitorsPF + id)),
+		})
+	}
+	// 没有浏览记录的帖子 ZSCORE 返回 nil
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	data := make([]PostCounters, 0, len(ids))
+	for _, c := range cmds {
+		data = append(data, PostCounters{
+			UpVotes:        c.up.Val(),
+			DownVotes:      c.down.Val(),
+			Views:          int64(c.views.Val()),
+			UniqueVisitors: c.visitors.Val(),
+		})
+	}
+	return data, nil
+}
+
+// GetUserVotes 批量查询用户对帖子的投票 1:赞成 -1:反对 0:未投票
+func GetUserVotes(userID string, ids []string) (data []int8, err error) {
+	pipeline := client.Pipeline()
+	for _, id := range ids {
+		pipeline.ZScore(getPostVoteKey(KeyPostVotedZSetPF, id), userID)
+	}
+	cmders, err := pipeline.Exec()
+	// 没投过票的帖子 ZSCORE 返回 nil
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	data = make([]int8, 0, len(ids))
+	for _, cmder := range cmders {
+		data = append(data, int8(cmder.(*redis.FloatCmd).Val()))
+	}
+	return data, nil
+}
@@ -0,0 +1,66 @@
+package redis
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	postListGenAll = "all"
+)
+
+// postListScope 帖子列表的范围：全部帖子或者某个社区的帖子
+func postListScope(communityID int64) string {
+	if communityID == 0 {
+		return postListGenAll
+	}
+	return "community:" + strconv.FormatInt(communityID, 10)
+}
+
+// GetPostListGen 帖子列表缓存当前的版本号，communityID 为 0 时是全部帖子列表的版本号
+func GetPostListGen(communityID int64) (int64, error) {
+	gen, err := client.HGet(getRedisKey(KeyPostListGenHash), postListScope(communityID)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return gen, err
+}
+
+// IncrPostListGen 社区的帖子有变化，社区和全部帖子列表的版本号都加一，旧版本的缓存不再被读取，过期后自动删除
+func IncrPostListGen(communityID int64) error {
+	key := getRedisKey(KeyPostListGenHash)
+	pipeline := client.Pipeline()
+	pipeline.HIncrBy(key, postListGenAll, 1)
+	if communityID != 0 {
+		pipeline.HIncrBy(key, postListScope(communityID), 1)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// PostListCacheKey 某个版本的帖子列表分页缓存的 key
+func PostListCacheKey(communityID, gen int64, order string, page, size int64) string {
+	return getRedisKey(KeyPostListPF + fmt.Sprintf("%s:%d:%s:%d:%d", postListScope(communityID), gen, order, page, size))
+}
+
+// postListStaleKey 最近一次生成的分页，不带版本号，用于 stale-while-revalidate
+func postListStaleKey(communityID int64, order string, page, size int64) string {
+	return getRedisKey(KeyPostListPF + fmt.Sprintf("stale:%s:%s:%d:%d", postListScope(communityID), order, page, size))
+}
+
+// GetPostListStale 最近一次生成的分页，不存在时返回 nil
+func GetPostListStale(communityID int64, order string, page, size int64) ([]byte, error) {
+	value, err := client.Get(postListStaleKey(communityID, order, page, size)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return value, err
+}
+
+// SetPostListStale 保存最近一次生成的分页
+func SetPostListStale(communityID int64, order string, page, size int64, value []byte, ttl time.Duration) error {
+	return client.Set(postListStaleKey(communityID, order, page, size), value, ttl).Err()
+}
@@ -0,0 +1,221 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RankEntry 重建排行榜时一篇已发布的帖子
+type RankEntry struct {
+	PostID      int64
+	AuthorID    int64
+	CommunityID int64
+	PublishTime time.Time
+	NetVotes    float64 // 按权重计算的净票数
+	Views       int64
+}
+
+// PostVoteEntry 重建时一个用户对帖子的投票
+type PostVoteEntry struct {
+	UserID    int64
+	Direction int8
+	Weight    float64
+}
+
+// 重建时先写入临时 key，全部写完后 RENAME 替换，重建期间不影响正在使用的排行榜
+// 临时 key 和正式的 key 使用同一个 hash tag，集群模式下可以 RENAME
+var rankRebuildKeys = []string{KeyPostTimeZSet, KeyPostScoreZSet, KeyPostViewsZSet}
+
+func rankRebuildKey(key string) string {
+	return getRankKey(key + ":rebuild")
+}
+
+func communityKey(communityID int64) string {
+	return KeyCommunitySetPF + strconv.FormatInt(communityID, 10)
+}
+
+// PostScore 帖子的分数：发帖时间加上按权重计算的净票数 × 每票的分数
+func PostScore(publishTime time.Time, netVotes float64) float64 {
+	return float64(publishTime.Unix()) + netVotes*scorePerVote
+}
+
+// RankRebuildStart 删除上次没有完成的重建留下的临时 key
+func RankRebuildStart(communityIDs []int64) error {
+	keys := make([]string, 0, len(rankRebuildKeys)+len(communityIDs))
+	for _, key := range rankRebuildKeys {
+		keys = append(keys, rankRebuildKey(key))
+	}
+	for _, id := range communityIDs {
+		keys = append(keys, rankRebuildKey(communityKey(id)))
+	}
+	return client.Del(keys...).Err()
+}
+
+// RankRebuildAdd 把一批帖子写入临时的排行榜和社区集合
+func RankRebuildAdd(entries []RankEntry) error {
+	return addRankEntries(entries, rankRebuildKey)
+}
+
+// RankAdd 把一批帖子直接写入正在使用的排行榜，用于补上重建期间新发的帖子
+func RankAdd(entries []RankEntry) error {
+	return addRankEntries(entries, getRankKey)
+}
+
+func addRankEntries(entries []RankEntry, key func(string) string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for _, e := range entries {
+		pipeline.ZAdd(key(KeyPostTimeZSet), redis.Z{Score: float64(e.PublishTime.Unix()), Member: e.PostID})
+		pipeline.ZAdd(key(KeyPostScoreZSet), redis.Z{Score: PostScore(e.PublishTime, e.NetVotes), Member: e.PostID})
+		pipeline.ZAdd(key(KeyPostViewsZSet), redis.Z{Score: float64(e.Views), Member: e.PostID})
+		pipeline.SAdd(key(communityKey(e.CommunityID)), e.PostID)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// RankRebuildFinish 用临时 key 替换排行榜和社区集合，没有帖子的社区删除正式的集合
+func RankRebuildFinish(withPosts, withoutPosts []int64) error {
+	pipeline := client.TxPipeline()
+	for _, key := range rankRebuildKeys {
+		if len(withPosts) > 0 {
+			pipeline.Rename(rankRebuildKey(key), getRankKey(key))
+		} else {
+			// 没有任何帖子时临时 key 不存在
+			pipeline.Del(getRankKey(key))
+		}
+	}
+	for _, id := range withPosts {
+		pipeline.Rename(rankRebuildKey(communityKey(id)), getRankKey(communityKey(id)))
+	}
+	for _, id := range withoutPosts {
+		pipeline.Del(getRankKey(communityKey(id)))
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// SetPostVotes 覆盖帖子的投票记录和投票权重，votes 为空时删除
+func SetPostVotes(votes map[int64][]PostVoteEntry, postIDs []int64) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for _, postID := range postIDs {
+		pid := strconv.FormatInt(postID, 10)
+		votedKey, weightKey := getPostVoteKey(KeyPostVotedZSetPF, pid), getPostVoteKey(KeyPostWeightHashPF, pid)
+		pipeline.Del(votedKey, weightKey)
+		list := votes[postID]
+		if len(list) == 0 {
+			continue
+		}
+		members := make([]redis.Z, 0, len(list))
+		weights := make(map[string]interface{}, len(list))
+		for _, v := range list {
+			uid := strconv.FormatInt(v.UserID, 10)
+			members = append(members, redis.Z{Score: float64(v.Direction), Member: uid})
+			weights[uid] = v.Weight
+		}
+		pipeline.ZAdd(votedKey, members...)
+		pipeline.HMSet(weightKey, weights)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetPostVotes 批量读取帖子的投票记录和投票权重，没有记录权重的旧投票按 1 计算
+func GetPostVotes(postIDs []int64) (map[int64][]PostVoteEntry, error) {
+	type voteCmds struct {
+		voted   *redis.ZSliceCmd
+		weights *redis.StringStringMapCmd
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]voteCmds, 0, len(postIDs))
+	for _, postID := range postIDs {
+		pid := strconv.FormatInt(postID, 10)
+		cmds = append(cmds, voteCmds{
+			voted:   pipeline.ZRangeWithScores(getPostVoteKey(KeyPostVotedZSetPF, pid), 0, -1),
+			weights: pipeline.HGetAll(getPostVoteKey(KeyPostWeightHashPF, pid)),
+		})
+	}
+	if len(cmds) > 0 {
+		if _, err := pipeline.Exec(); err != nil {
+			return nil, err
+		}
+	}
+	votes := make(map[int64][]PostVoteEntry, len(postIDs))
+	for i, c := range cmds {
+		weights := c.weights.Val()
+		for _, z := range c.voted.Val() {
+			uid, ok := z.Member.(string)
+			if !ok {
+				continue
+			}
+			userID, err := strconv.ParseInt(uid, 10, 64)
+			if err != nil {
+				continue
+			}
+			weight := 1.0
+			if w, err := strconv.ParseFloat(weights[uid], 64); err == nil {
+				weight = w
+			}
+			votes[postIDs[i]] = append(votes[postIDs[i]], PostVoteEntry{UserID: userID, Direction: int8(z.Score), Weight: weight})
+		}
+	}
+	return votes, nil
+}
+
+// AddUserPosts 批量把帖子加入作者的帖子列表
+func AddUserPosts(entries []RankEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for _, e := range entries {
+		pipeline.ZAdd(getRedisKey(KeyUserPostsZSetPF+strconv.FormatInt(e.AuthorID, 10)), redis.Z{
+			Score:  float64(e.PublishTime.Unix()),
+			Member: e.PostID,
+		})
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// AddPostsToReview 批量把待审核的帖子加入审核队列，已经在队列中的不修改进入时间
+func AddPostsToReview(posts map[int64]time.Time) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, 0, len(posts))
+	for postID, t := range posts {
+		members = append(members, redis.Z{Score: float64(t.Unix()), Member: postID})
+	}
+	return client.ZAddNX(getRedisKey(KeyPostReviewZSet), members...).Err()
+}
+
+// SetKarma 覆盖用户的声望
+func SetKarma(karma map[int64]float64) error {
+	if len(karma) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for userID, value := range karma {
+		pipeline.Set(getRedisKey(KeyUserKarmaPF+strconv.FormatInt(userID, 10)), strconv.FormatFloat(value, 'f', -1, 64), 0)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// RankRebuildLock 同一时间只允许一个重建任务
+func RankRebuildLock(ttl time.Duration) (bool, error) {
+	return client.SetNX(getRedisKey(KeyCacheLock+"rebuild:rank"), "1", ttl).Result()
+}
+
+// RankRebuildUnlock 释放重建锁
+func RankRebuildUnlock() error {
+	return client.Del(getRedisKey(KeyCacheLock + "rebuild:rank")).Err()
+}
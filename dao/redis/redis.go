@@ -2,25 +2,68 @@ package redis
 
 import (
 	"fmt"
+	"time"
 	"web-app/settings"
 
 	"github.com/go-redis/redis"
 
 )
 var (
-	client *redis.Client
+	client redis.UniversalClient
 	Nil    = redis.Nil
+
+	// clusterMode 集群模式下多个 key 的操作需要落在同一个 slot，见 getSlotKey
+	clusterMode bool
+)
+
+// 连接模式
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
 )
 
-// Init 初始化连接
+// Init 初始化连接，按 mode 连接单机、哨兵或者集群
 func Init(cfg *settings.RedisConfig) (err error) {
-	client = redis.NewClient(&redis.Options{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Password:     cfg.Password, // no password set
-		DB:           cfg.DB,       // use default DB
-		PoolSize:     cfg.PoolSize,
-		MinIdleConns: cfg.MinIdleConns,
-	})
+	initBreaker(cfg.BreakerFailures, time.Duration(cfg.BreakerTimeout)*time.Second)
+	switch cfg.Mode {
+	case "", ModeStandalone:
+		client = redis.NewClient(&redis.Options{
+			Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			Password:     cfg.Password, // no password set
+			DB:           cfg.DB,       // use default DB
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+		}).SetLimiter(limiter{})
+	case ModeSentinel:
+		// 主从切换后自动连接新的主节点
+		client = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: cfg.SentinelAddrs,
+			Password:      cfg.Password,
+			DB:            cfg.DB,
+			PoolSize:      cfg.PoolSize,
+			MinIdleConns:  cfg.MinIdleConns,
+		}).SetLimiter(limiter{})
+	case ModeCluster:
+		// 集群只有 0 号库，PoolSize 是每个节点的连接数
+		if cfg.DB != 0 {
+			return fmt.Errorf("redis cluster does not support db %d", cfg.DB)
+		}
+		client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.ClusterAddrs,
+			Password:     cfg.Password,
+			PoolSize:     cfg.PoolSize,
+			MinIdleConns: cfg.MinIdleConns,
+			OnNewNode: func(c *redis.Client) {
+				c.SetLimiter(limiter{})
+			},
+		})
+	default:
+		return fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+	clusterMode = cfg.Mode == ModeCluster
+	instrument(client)
 
 	_, err = client.Ping().Result()
 	if err != nil {
@@ -32,3 +75,48 @@ func Init(cfg *settings.RedisConfig) (err error) {
 func Close() {
 	_ = client.Close()
 }
+
+// forEachNode 对每个主节点执行 fn，SCAN、DBSIZE 这类只作用于单个节点的命令在集群模式下需要逐个节点执行
+func forEachNode(fn func(c redis.Cmdable) error) error {
+	if cc, ok := client.(*redis.ClusterClient); ok {
+		return cc.ForEachMaster(func(c *redis.Client) error {
+			return fn(c)
+		})
+	}
+	return fn(client)
+}
+
+// mget 集群模式下 MGET 的 key 不在同一个 slot 时会报错，改为用 pipeline 逐个 GET
+func mget(keys []string) ([]interface{}, error) {
+	if !clusterMode {
+		return client.MGet(keys...).Result()
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipeline.Get(key)
+	}
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		if v, err := cmd.Result(); err == nil {
+			values[i] = v
+		}
+	}
+	return values, nil
+}
+
+// del 集群模式下逐个删除，原因同 mget
+func del(keys ...string) error {
+	if !clusterMode || len(keys) <= 1 {
+		return client.Del(keys...).Err()
+	}
+	pipeline := client.Pipeline()
+	for _, key := range keys {
+		pipeline.Del(key)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
@@ -0,0 +1,186 @@
+package redis
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"web-app/models"
+	"web-app/pkg/bloom"
+	"web-app/pkg/cache"
+	"web-app/settings"
+
+	"github.com/go-redis/redis"
+)
+
+// 集群和哨兵模式的测试需要真实的 Redis，通过环境变量指定，没有设置时跳过：
+//
+//	BLUEBELL_TEST_REDIS_CLUSTER=127.0.0.1:7000,127.0.0.1:7001,127.0.0.1:7002
+//	BLUEBELL_TEST_REDIS_SENTINEL=127.0.0.1:26379 BLUEBELL_TEST_REDIS_MASTER=mymaster
+//
+// 测试会清空 Redis 中的数据，只能连接专门用于测试的 Redis
+
+func TestInitInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  settings.RedisConfig
+	}{
+		{name: "cluster with db", cfg: settings.RedisConfig{Mode: ModeCluster, DB: 1, ClusterAddrs: []string{"127.0.0.1:7000"}}},
+		{name: "unknown mode", cfg: settings.RedisConfig{Mode: "replica"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(&tt.cfg); err == nil {
+				t.Error("Init() should fail")
+			}
+		})
+	}
+}
+
+func TestCluster(t *testing.T) {
+	addrs := os.Getenv("BLUEBELL_TEST_REDIS_CLUSTER")
+	if addrs == "" {
+		t.Skip("BLUEBELL_TEST_REDIS_CLUSTER not set")
+	}
+	initTestRedis(t, &settings.RedisConfig{
+		Mode:         ModeCluster,
+		ClusterAddrs: strings.Split(addrs, ","),
+	})
+	if !clusterMode {
+		t.Fatal("clusterMode should be set")
+	}
+	testRedisOperations(t)
+}
+
+func TestSentinel(t *testing.T) {
+	addrs := os.Getenv("BLUEBELL_TEST_REDIS_SENTINEL")
+	if addrs == "" {
+		t.Skip("BLUEBELL_TEST_REDIS_SENTINEL not set")
+	}
+	master := os.Getenv("BLUEBELL_TEST_REDIS_MASTER")
+	if master == "" {
+		master = "mymaster"
+	}
+	initTestRedis(t, &settings.RedisConfig{
+		Mode:          ModeSentinel,
+		MasterName:    master,
+		SentinelAddrs: strings.Split(addrs, ","),
+	})
+	testRedisOperations(t)
+}
+
+func initTestRedis(t *testing.T, cfg *settings.RedisConfig) {
+	t.Helper()
+	if err := Init(cfg); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	t.Cleanup(func() {
+		Close()
+		clusterMode = false
+	})
+	if err := forEachNode(func(c redis.Cmdable) error { return c.FlushDB().Err() }); err != nil {
+		t.Fatalf("FlushDB() error = %v", err)
+	}
+}
+
+// testRedisOperations 执行会同时用到多个 key 的操作：事务、ZINTERSTORE、RENAME 和批量读写
+// 集群模式下 key 不在同一个 slot 时这些操作会报 CROSSSLOT 错误
+func testRedisOperations(t *testing.T) {
+	t.Helper()
+	now := time.Now()
+	must := func(name string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s error = %v", name, err)
+		}
+	}
+
+	// 发帖和投票
+	must("CreatePostAt", CreatePostAt(1, 10, now))
+	must("CreatePostAt", CreatePostAt(2, 10, now.Add(-time.Minute)))
+	must("CreatePostAt", CreatePostAt(3, 20, now))
+	_, delta, err := VoteForPost("100", "2", 1, 1)
+	must("VoteForPost", err)
+	if delta != 1 {
+		t.Errorf("VoteForPost() delta = %v, want 1", delta)
+	}
+	if _, _, err := VoteForPost("100", "2", 1, 1); err != ErrorVoteRepeated {
+		t.Errorf("repeated VoteForPost() error = %v, want ErrorVoteRepeated", err)
+	}
+	_, _, err = VoteForPost("101", "1", -1, 0.5)
+	must("VoteForPost", err)
+
+	counters, err := GetPostCounters([]string{"1", "2", "3"})
+	must("GetPostCounters", err)
+	if counters[0].DownVotes != 1 || counters[1].UpVotes != 1 || counters[2].UpVotes != 0 {
+		t.Errorf("GetPostCounters() = %+v", counters)
+	}
+	votes, err := GetUserVotes("100", []string{"1", "2"})
+	must("GetUserVotes", err)
+	if !reflect.DeepEqual(votes, []int8{0, 1}) {
+		t.Errorf("GetUserVotes() = %v, want [0 1]", votes)
+	}
+
+	// 按社区排序
+	ids, err := GetCommunityPostIDsInOrder(&models.ParamsCommunityPostList{ParamsPostList: &models.ParamsPostList{
+		CommunityID: 10, Page: 1, Size: 10, Order: models.OrderScore,
+	}})
+	must("GetCommunityPostIDsInOrder", err)
+	if !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Errorf("GetCommunityPostIDsInOrder() = %v, want [2 1]", ids)
+	}
+
+	// 重建排行榜：临时 key 替换正式的 key，没有帖子的社区删除
+	must("RankRebuildStart", RankRebuildStart([]int64{10, 20}))
+	must("RankRebuildAdd", RankRebuildAdd([]RankEntry{{PostID: 1, CommunityID: 10, PublishTime: now, NetVotes: 1}}))
+	must("RankRebuildFinish", RankRebuildFinish([]int64{10}, []int64{20}))
+	ids, err = GetPostIDsInOrder(&models.ParamsPostList{Page: 1, Size: 10, Order: models.OrderTime})
+	must("GetPostIDsInOrder", err)
+	if !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("GetPostIDsInOrder() after rebuild = %v, want [1]", ids)
+	}
+	if n, err := CommunitySetSize(20); err != nil || n != 0 {
+		t.Errorf("community 20 after rebuild = %d, %v, want removed", n, err)
+	}
+
+	// 覆盖投票记录
+	must("SetPostVotes", SetPostVotes(map[int64][]PostVoteEntry{
+		1: {{UserID: 100, Direction: 1, Weight: 1}},
+	}, []int64{1, 2}))
+	votes, err = GetUserVotes("100", []string{"1", "2"})
+	must("GetUserVotes", err)
+	if !reflect.DeepEqual(votes, []int8{1, 0}) {
+		t.Errorf("GetUserVotes() after SetPostVotes() = %v, want [1 0]", votes)
+	}
+	exported, err := GetPostVotes([]int64{1, 2})
+	must("GetPostVotes", err)
+	if want := map[int64][]PostVoteEntry{1: {{UserID: 100, Direction: 1, Weight: 1}}}; !reflect.DeepEqual(exported, want) {
+		t.Errorf("GetPostVotes() = %v, want %v", exported, want)
+	}
+
+	// 重建布隆过滤器
+	p := bloom.Optimal(100, 0.01)
+	must("BloomRebuildStart", BloomRebuildStart("post"))
+	must("BloomRebuildAdd", BloomRebuildAdd("post", p, []int64{1, 2}))
+	must("BloomRebuildFinish", BloomRebuildFinish("post", p))
+	if ready, err := BloomReady("post", p); err != nil || !ready {
+		t.Errorf("BloomReady() = %v, %v, want ready", ready, err)
+	}
+	if exists, err := BloomExists("post", p, 1); err != nil || !exists {
+		t.Errorf("BloomExists(1) = %v, %v, want true", exists, err)
+	}
+
+	// 缓存的批量读写
+	store := CacheStore()
+	must("MSet", store.MSet([]cache.Item{
+		{Key: getRedisKey("a"), Value: []byte("1"), TTL: time.Minute},
+		{Key: getRedisKey("b"), Value: []byte("2"), TTL: time.Minute},
+	}))
+	values, err := store.MGet([]string{getRedisKey("a"), getRedisKey("c"), getRedisKey("b")})
+	must("MGet", err)
+	if string(values[0]) != "1" || values[1] != nil || string(values[2]) != "2" {
+		t.Errorf("MGet() = %q", values)
+	}
+	must("Del", store.Del(getRedisKey("a"), getRedisKey("b")))
+}
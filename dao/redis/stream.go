@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// PublishEvent 发布实时事件，所有实例都会收到
+func PublishEvent(topic string, payload []byte) error {
+	return client.Publish(getRedisKey(KeyStreamChannelPF+topic), payload).Err()
+}
+
+// SubscribeEvents 订阅所有实时事件频道，阻塞直到 ctx 被取消
+// 断线重连由 go-redis 的 PubSub 负责
+func SubscribeEvents(ctx context.Context, handler func(topic string, payload []byte)) error {
+	prefix := getRedisKey(KeyStreamChannelPF)
+	pubsub := client.PSubscribe(prefix + "*")
+	defer pubsub.Close()
+
+	// 确认订阅成功
+	if _, err := pubsub.Receive(); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			handler(strings.TrimPrefix(msg.Channel, prefix), []byte(msg.Payload))
+		}
+	}
+}
+
+// SetStreamTicket 保存订阅通知的一次性凭证，过期后自动删除
+func SetStreamTicket(ticket string, userID int64, ttl time.Duration) error {
+	return client.Set(getRedisKey(KeyStreamTicketPF+ticket), userID, ttl).Err()
+}
+
+// TakeStreamTicket 取出凭证对应的用户并删除凭证，凭证不存在、已过期或者已经使用过时返回 redis.Nil
+func TakeStreamTicket(ticket string) (int64, error) {
+	key := getRedisKey(KeyStreamTicketPF + ticket)
+	pipeline := client.TxPipeline()
+	get := pipeline.Get(key)
+	pipeline.Del(key)
+	if _, err := pipeline.Exec(); err != nil {
+		return 0, err
+	}
+	return get.Int64()
+}
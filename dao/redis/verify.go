@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis"
+)
+
+// PostIndexState 一篇帖子在排行榜和社区集合中的状态
+type PostIndexState struct {
+	InTime      bool
+	Time        float64
+	InScore     bool
+	Score       float64
+	InCommunity bool
+	NetVotes    float64 // 按 post:voted 和 post:weight 计算的净票数
+}
+
+// ExpectedScore 按发帖时间和投票记录计算的分数
+func (s PostIndexState) ExpectedScore() float64 {
+	return s.Time + s.NetVotes*scorePerVote
+}
+
+// GetPostIndexStates 批量查询帖子是否在 post:time、post:score 和所属社区的集合中，以及按投票记录计算的净票数
+func GetPostIndexStates(entries []RankEntry) ([]PostIndexState, error) {
+	type stateCmds struct {
+		time, score *redis.FloatCmd
+		member      *redis.BoolCmd
+		voted       *redis.ZSliceCmd
+		weights     *redis.StringStringMapCmd
+	}
+	pipeline := client.Pipeline()
+	cmds := make([]stateCmds, 0, len(entries))
+	for _, e := range entries {
+		pid := strconv.FormatInt(e.PostID, 10)
+		cmds = append(cmds, stateCmds{
+			time:    pipeline.ZScore(getRankKey(KeyPostTimeZSet), pid),
+			score:   pipeline.ZScore(getRankKey(KeyPostScoreZSet), pid),
+			member:  pipeline.SIsMember(getRankKey(communityKey(e.CommunityID)), pid),
+			voted:   pipeline.ZRangeWithScores(getPostVoteKey(KeyPostVotedZSetPF, pid), 0, -1),
+			weights: pipeline.HGetAll(getPostVoteKey(KeyPostWeightHashPF, pid)),
+		})
+	}
+	// 不在排行榜中的帖子 ZSCORE 返回 nil
+	if _, err := pipeline.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	states := make([]PostIndexState, 0, len(entries))
+	for _, c := range cmds {
+		var s PostIndexState
+		if v, err := c.time.Result(); err == nil {
+			s.InTime, s.Time = true, v
+		}
+		if v, err := c.score.Result(); err == nil {
+			s.InScore, s.Score = true, v
+		}
+		s.InCommunity = c.member.Val()
+		weights := c.weights.Val()
+		for _, z := range c.voted.Val() {
+			// 没有记录权重的旧投票按 1 计算，和投票时一致
+			weight := 1.0
+			if w, err := strconv.ParseFloat(weights[z.Member.(string)], 64); err == nil {
+				weight = w
+			}
+			s.NetVotes += z.Score * weight
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// PostIndexRepair 需要补上或者修正的排行榜数据，为 nil 的字段不修改
+type PostIndexRepair struct {
+	PostID      int64
+	CommunityID int64
+	Time        *float64
+	Score       *float64
+	Views       int64 // 补上 post:time 时浏览量不存在则写入
+	Community   bool  // 加入所属社区的集合
+}
+
+// RepairPostIndexes 批量修正排行榜和社区集合
+func RepairPostIndexes(repairs []PostIndexRepair) error {
+	if len(repairs) == 0 {
+		return nil
+	}
+	pipeline := client.Pipeline()
+	for _, r := range repairs {
+		if r.Time != nil {
+			pipeline.ZAdd(getRankKey(KeyPostTimeZSet), redis.Z{Score: *r.Time, Member: r.PostID})
+			pipeline.ZAddNX(getRankKey(KeyPostViewsZSet), redis.Z{Score: float64(r.Views), Member: r.PostID})
+		}
+		if r.Score != nil {
+			pipeline.ZAdd(getRankKey(KeyPostScoreZSet), redis.Z{Score: *r.Score, Member: r.PostID})
+		}
+		if r.Community {
+			pipeline.SAdd(getRankKey(communityKey(r.CommunityID)), r.PostID)
+		}
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// ScanRankZSet 用 ZSCAN 分批遍历 post:time、post:score 或 post:views 中的帖子id
+func ScanRankZSet(key string, cursor uint64, count int64) (ids []string, next uint64, err error) {
+	values, next, err := client.ZScan(getRankKey(key), cursor, "", count).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	// ZSCAN 返回的是 成员、分数 交替的列表
+	ids = make([]string, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		ids = append(ids, values[i])
+	}
+	return ids, next, nil
+}
+
+// RemoveFromRank 把帖子从发帖时间、分数和浏览量排行榜中删除
+func RemoveFromRank(postIDs []string) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(postIDs))
+	for _, id := range postIDs {
+		members = append(members, id)
+	}
+	pipeline := client.Pipeline()
+	for _, key := range rankRebuildKeys {
+		pipeline.ZRem(getRankKey(key), members...)
+	}
+	_, err := pipeline.Exec()
+	return err
+}
+
+// GetCommunitySetIDs 用 SCAN 找出所有社区帖子集合的社区id，包括 MySQL 中不存在的社区
+func GetCommunitySetIDs() ([]int64, error) {
+	prefix := getRankKey(KeyCommunitySetPF)
+	var (
+		mu  sync.Mutex
+		ids []int64
+	)
+	err := forEachNode(func(c redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := c.Scan(cursor, prefix+"*", 500).Result()
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			for _, key := range keys {
+				// 重建用的临时 key 等不是纯数字的 key 跳过
+				if id, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64); err == nil {
+					ids = append(ids, id)
+				}
+			}
+			mu.Unlock()
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	})
+	return ids, err
+}
+
+// ScanCommunitySet 用 SSCAN 分批遍历社区集合中的帖子id
+func ScanCommunitySet(communityID int64, cursor uint64, count int64) ([]string, uint64, error) {
+	return client.SScan(getRankKey(communityKey(communityID)), cursor, "", count).Result()
+}
+
+// RemoveFromCommunity 把帖子从社区集合中删除
+func RemoveFromCommunity(communityID int64, postIDs []string) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(postIDs))
+	for _, id := range postIDs {
+		members = append(members, id)
+	}
+	return client.SRem(getRankKey(communityKey(communityID)), members...).Err()
+}
+
+// DeleteCommunitySet 删除不存在的社区的帖子集合
+func DeleteCommunitySet(communityID int64) error {
+	return client.Del(getRankKey(communityKey(communityID))).Err()
+}
+
+// CommunitySetSize 社区集合中的帖子数
+func CommunitySetSize(communityID int64) (int64, error) {
+	return client.SCard(getRankKey(communityKey(communityID))).Result()
+}
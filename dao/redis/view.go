@@ -0,0 +1,79 @@
+package redis
+
+import "github.com/go-redis/redis"
+
+// AddPostViews 批量累加帖子的浏览量和独立访客，并标记为等待写入 MySQL
+// counts 帖子id -> 新增的浏览次数  visitors 帖子id -> 新增的访客
+// 管道不是事务，集群模式下的命令还会发到不同的节点，出错时可能只有一部分写入成功
+// 出错时返回没有写入成功的部分，调用方只重试这部分，已经累加的浏览量不会再累加一次
+// 浏览量写入成功、只是标记失败的帖子返回的浏览次数为 0，重试时只重新标记
+func AddPostViews(counts map[string]int64, visitors map[string][]string) (failedCounts map[string]int64, failedVisitors map[string][]string, err error) {
+	if len(counts) == 0 {
+		return nil, nil, nil
+	}
+	pipeline := client.Pipeline()
+	incrCmds := make(map[string]*redis.FloatCmd, len(counts))
+	pfCmds := make(map[string]*redis.IntCmd, len(visitors))
+	dirty := make([]interface{}, 0, len(counts))
+	for postID, count := range counts {
+		incrCmds[postID] = pipeline.ZIncrBy(getRankKey(KeyPostViewsZSet), float64(count), postID)
+		if vs := visitors[postID]; len(vs) > 0 {
+			els := make([]interface{}, 0, len(vs))
+			for _, v := range vs {
+				els = append(els, v)
+			}
+			pfCmds[postID] = pipeline.PFAdd(getRedisKey(KeyPostVisitorsPF+postID), els...)
+		}
+		dirty = append(dirty, postID)
+	}
+	dirtyCmd := pipeline.SAdd(getRedisKey(KeyPostViewsDirtySet), dirty...)
+	if _, err = pipeline.Exec(); err == nil {
+		return nil, nil, nil
+	}
+
+	failedCounts = make(map[string]int64)
+	failedVisitors = make(map[string][]string)
+	for postID, cmd := range incrCmds {
+		if cmd.Err() != nil {
+			failedCounts[postID] = counts[postID]
+		} else if dirtyCmd.Err() != nil {
+			failedCounts[postID] = 0
+		}
+	}
+	// HyperLogLog 重复添加同一个访客不影响结果
+	for postID, cmd := range pfCmds {
+		if cmd.Err() != nil {
+			failedVisitors[postID] = visitors[postID]
+		}
+	}
+	return failedCounts, failedVisitors, err
+}
+
+// PopDirtyViewPosts 取出一批浏览量有变化的帖子
+func PopDirtyViewPosts(count int64) ([]string, error) {
+	return client.SPopN(getRedisKey(KeyPostViewsDirtySet), count).Result()
+}
+
+// MarkViewPostsDirty 写入 MySQL 失败时重新标记，等下次重试
+func MarkViewPostsDirty(postIDs []string) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(postIDs))
+	for _, id := range postIDs {
+		members = append(members, id)
+	}
+	return client.SAdd(getRedisKey(KeyPostViewsDirtySet), members...).Err()
+}
+
+// AddPostViewsNX 把帖子按 MySQL 中的浏览量加入 post:views，已经存在的帖子不修改
+func AddPostViewsNX(entries []RankEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, 0, len(entries))
+	for _, e := range entries {
+		members = append(members, redis.Z{Score: float64(e.Views), Member: e.PostID})
+	}
+	return client.ZAddNX(getRankKey(KeyPostViewsZSet), members...).Err()
+}
@@ -10,6 +10,7 @@ import (
 
 // 本项目使用简化版的投票分数
 // 投一票就加432分   86400/200 = 432    200张赞成票可以给你的帖子续一天
+// 开启刷票检测后每票乘以权重，可疑账号的投票会降权甚至不计分
 
 /* 投票的几种情况
 direction = 1 时 有两种情况：
@@ -30,7 +31,7 @@ direction = -1 时 有两种情况：
 
 const (
 	oneWeekInSeconds = 7 * 24 * 3600
-	scorePerVote     = 432 // 每票的分数
+	scorePerVote     = 432 // 每票的分数（权重为 1 时）
 )
 
 var (
@@ -39,21 +40,31 @@ var (
 )
 
 func CreatePost(postID, communityID int64) error {
+	return CreatePostAt(postID, communityID, time.Now())
+}
+
+// CreatePostAt 把帖子加入排行榜，发帖时间记为 publishTime（定时发布的帖子按发布时间计算）
+func CreatePostAt(postID, communityID int64, publishTime time.Time) error {
 	
 	pipeline := client.TxPipeline()      // 使用事务 要么一起成功 要么一起失败
 	// 帖子发帖时间
-	pipeline.ZAdd(getRedisKey(KeyPostTimeZSet), redis.Z{
-		Score: float64(time.Now().Unix()),
+	pipeline.ZAdd(getRankKey(KeyPostTimeZSet), redis.Z{
+		Score: float64(publishTime.Unix()),
 		Member: postID,
 	})
 
 	// 帖子分数
-	pipeline.ZAdd(getRedisKey(KeyPostScoreZSet), redis.Z{
-		Score: float64(time.Now().Unix()),
+	pipeline.ZAdd(getRankKey(KeyPostScoreZSet), redis.Z{
+		Score: float64(publishTime.Unix()),
+		Member: postID,
+	})
+	// 帖子浏览量，没有浏览过的帖子也要出现在按浏览量排序的列表中
+	pipeline.ZAddNX(getRankKey(KeyPostViewsZSet), redis.Z{
+		Score:  0,
 		Member: postID,
 	})
 	// 把帖子id加到社区的set中
-	cKey := getRedisKey(KeyCommunitySetPF + strconv.Itoa(int(postID)))
+	cKey := getRankKey(KeyCommunitySetPF + strconv.FormatInt(communityID, 10))
 	pipeline.SAdd(cKey, postID)
 	
 	_, err := pipeline.Exec()
@@ -61,55 +72,73 @@ func CreatePost(postID, communityID int64) error {
 }
 
 
-func VoteForPost(userID, postID string, value float64) error {
+// VoteForPost 为帖子投票
+// weight 是本次投票的权重，由刷票检测根据账号可信度计算，每票的分数为 weight * scorePerVote
+// 返回投票前的状态，以及按权重计算的净票数变化
+func VoteForPost(userID, postID string, value, weight float64) (oldValue, delta float64, err error) {
 	// 1. 判断投票限制
 	// 去redis取帖子发帖时间
-	postTime := client.ZScore(getRedisKey(KeyPostTimeZSet), postID).Val()
+	// 不存在的帖子按发帖时间为 0 处理，Redis 不可用时返回错误，不能当作投票过期
+	postTime, err := client.ZScore(getRankKey(KeyPostTimeZSet), postID).Result()
+	if err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
 	if time.Now().Unix()-int64(postTime) > oneWeekInSeconds {
-		return ErrorVoteTimeExpire
+		return 0, 0, ErrorVoteTimeExpire
 	}
 
 	// 2. 更新帖子分数
 	// 先查当前用户给当前帖子的投票记录
-	oldValue := client.ZScore(getRedisKey(KeyPostVotedZSetPF+postID), userID).Val()
+	oldValue, err = client.ZScore(getPostVoteKey(KeyPostVotedZSetPF, postID), userID).Result()
+	if err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
 
 	// 如果和之前的投票一样，则不需要更新
 	if value == oldValue {
-		return ErrorVoteRepeated
+		return oldValue, 0, ErrorVoteRepeated
 	}
 
-	// 计算分数变化值
-	var diff float64
+	// 之前的投票按当时的权重撤销，没有记录权重的旧投票按 1 计算
+	oldWeight := 1.0
+	if w, err := client.HGet(getPostVoteKey(KeyPostWeightHashPF, postID), userID).Float64(); err == nil {
+		oldWeight = w
+	}
 	if value == 0 {
-		// 取消投票
-		diff = -oldValue * scorePerVote
-	} else if oldValue == 0 {
-		// 新投票
-		diff = value * scorePerVote
-	} else {
-		// 改投票
-		diff = (value - oldValue) * scorePerVote
+		weight = 0
 	}
 
+	// 计算分数变化值：取消投票、新投票、改投票都可以统一成 新的加权票 - 旧的加权票
+	delta = value*weight - oldValue*oldWeight
+	diff := delta * scorePerVote
+
 	// 3. 使用Pipeline确保原子性操作
+	// 集群模式下分数和投票记录不在同一个 slot，go-redis 按 slot 拆成两个事务执行，投票记录和权重仍然在同一个事务中
 	pipeline := client.TxPipeline()
 
 	// 更新帖子分数
-	pipeline.ZIncrBy(getRedisKey(KeyPostScoreZSet), diff, postID)
+	pipeline.ZIncrBy(getRankKey(KeyPostScoreZSet), diff, postID)
 
 	// 4. 记录用户为该帖子投票的数据
 	if value == 0 {
 		// 取消投票，删除投票记录
-		pipeline.ZRem(getRedisKey(KeyPostVotedZSetPF+postID), userID)
+		pipeline.ZRem(getPostVoteKey(KeyPostVotedZSetPF, postID), userID)
+		pipeline.HDel(getPostVoteKey(KeyPostWeightHashPF, postID), userID)
 	} else {
 		// 添加或更新投票记录
-		pipeline.ZAdd(getRedisKey(KeyPostVotedZSetPF+postID), redis.Z{
+		pipeline.ZAdd(getPostVoteKey(KeyPostVotedZSetPF, postID), redis.Z{
 			Score:  value,
 			Member: userID,
 		})
+		pipeline.HSet(getPostVoteKey(KeyPostWeightHashPF, postID), userID, weight)
 	}
 
 	// 执行所有操作
-	_, err := pipeline.Exec()
-	return err
+	_, err = pipeline.Exec()
+	return oldValue, delta, err
+}
+
+// GetPostScore 查询帖子当前的分数
+func GetPostScore(postID string) (float64, error) {
+	return client.ZScore(getRankKey(KeyPostScoreZSet), postID).Result()
 }
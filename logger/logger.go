@@ -10,6 +10,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
 	"runtime/debug"
 	"strings"
@@ -80,7 +81,7 @@ func GinLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactQuery(c.Request.URL.RawQuery)
 		c.Next()
 
 		cost := time.Since(start)
@@ -113,7 +114,7 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 					}
 				}
 
-				httpRequest, _ := httputil.DumpRequest(c.Request, false)
+				httpRequest, _ := httputil.DumpRequest(redactRequest(c.Request), false)
 				if brokenPipe {
 					zap.L().Error(c.Request.URL.Path,
 						zap.Any("error", err),
@@ -143,3 +144,46 @@ func GinRecovery(stack bool) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// sensitiveParams 日志中需要隐藏的 URL 参数和请求头
+var (
+	sensitiveParams  = []string{"token", "ticket", "access_token", "refresh_token", "password"}
+	sensitiveHeaders = []string{"Authorization", "Cookie"}
+)
+
+// redactQuery 隐藏 URL 参数中的凭证，无法解析的参数整个隐藏
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "REDACTED"
+	}
+	redacted := false
+	for _, name := range sensitiveParams {
+		if _, ok := values[name]; ok {
+			values.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// redactRequest 复制一份隐藏了凭证的请求，用于 panic 时打印请求
+func redactRequest(r *http.Request) *http.Request {
+	req := r.Clone(r.Context())
+	u := *r.URL
+	u.RawQuery = redactQuery(r.URL.RawQuery)
+	req.URL = &u
+	req.RequestURI = u.RequestURI()
+	for _, name := range sensitiveHeaders {
+		if req.Header.Get(name) != "" {
+			req.Header.Set(name, "REDACTED")
+		}
+	}
+	return req
+}
@@ -0,0 +1,265 @@
+package logic
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+// 各项风险因素对投票权重的折扣
+const (
+	ipOverlapFactor  = 0.5 // 和作者用过同一个IP，公司、学校和运营商 NAT 下很常见，只作为降权的因素之一
+	newAccountFactor = 0.2 // 新注册账号
+	lowKarmaFactor   = 0.5 // 新注册账号的声望过低，声望上线之前的老账号都是 0，只和注册时间一起判断
+	ringFactor       = 0.2 // 和最近的投票人结团
+	maxAbusePairs    = 1000
+)
+
+var defaultAbuseConfig = settings.AbuseConfig{
+	NewAccountDays: 3,
+	MinKarma:       5,
+	RingMinCoVotes: 10,
+	RingRatio:      0.8,
+	RecentVoters:   50,
+}
+
+// abuseSignals 计算投票权重用到的查询，测试时替换
+var abuseSignals = struct {
+	clientOverlap func(userID int64, ip, deviceID string) (ipHit, deviceHit bool, err error)
+	createTime    func(userID int64) (time.Time, error)
+	karma         func(userID int64) (float64, error)
+	coVoteStats   func(userID, postID string) ([]redis.CoVoteStat, int64, error)
+}{
+	clientOverlap: redis.CheckClientOverlap,
+	createTime:    mysql.GetUserCreateTime,
+	karma:         redis.GetKarma,
+	coVoteStats:   redis.GetCoVoteStats,
+}
+
+// abuseConfig 读取刷票检测配置，没有配置时不启用
+func abuseConfig() (settings.AbuseConfig, bool) {
+	cfg := settings.Conf.AbuseConfig
+	if cfg == nil || !cfg.Enable {
+		return defaultAbuseConfig, false
+	}
+	c := *cfg
+	if c.NewAccountDays <= 0 {
+		c.NewAccountDays = defaultAbuseConfig.NewAccountDays
+	}
+	if c.RingMinCoVotes <= 0 {
+		c.RingMinCoVotes = defaultAbuseConfig.RingMinCoVotes
+	}
+	if c.RingRatio <= 0 {
+		c.RingRatio = defaultAbuseConfig.RingRatio
+	}
+	if c.RecentVoters <= 0 {
+		c.RecentVoters = defaultAbuseConfig.RecentVoters
+	}
+	return c, true
+}
+
+// RecordUserClient 记录用户使用过的IP和设备，登录和发帖时调用
+func RecordUserClient(userID int64, info *models.ClientInfo) {
+	if info == nil {
+		return
+	}
+	if _, ok := abuseConfig(); !ok {
+		return
+	}
+	if err := redis.RecordUserClient(userID, info.IP, info.DeviceID); err != nil {
+		zap.L().Error("redis.RecordUserClient() failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+}
+
+// voteWeight 根据账号可信度计算投票的权重，返回权重和命中的风险因素
+// 各项风险因素只降低权重，只有和作者使用同一个设备的投票不计分，权重和命中的因素记录在投票记录中供管理员查看
+// 检测本身出错时不影响投票，按已经算出的权重处理
+func voteWeight(userID, authorID int64, postID string, info *models.ClientInfo) (weight float64, reasons []string) {
+	cfg, ok := abuseConfig()
+	if !ok {
+		return 1, nil
+	}
+	weight = 1
+
+	// 1. 设备和作者重合，基本可以认定是小号给自己投票；只是IP重合时降权
+	if info != nil && userID != authorID {
+		ipHit, deviceHit, err := abuseSignals.clientOverlap(authorID, info.IP, info.DeviceID)
+		if err != nil {
+			zap.L().Error("redis.CheckClientOverlap() failed", zap.Int64("author_id", authorID), zap.Error(err))
+		}
+		if ipHit {
+			weight *= ipOverlapFactor
+			reasons = append(reasons, "ip_overlap")
+		}
+		if deviceHit {
+			weight, reasons = 0, append(reasons, "device_overlap")
+		}
+	}
+
+	// 2. 账号注册时间，新账号的声望也过低时再降权
+	// 声望是上线之后才开始累计的，老账号都是 0，不能单独作为降权的因素
+	createTime, err := abuseSignals.createTime(userID)
+	if err != nil {
+		zap.L().Error("mysql.GetUserCreateTime() failed", zap.Int64("user_id", userID), zap.Error(err))
+	} else if time.Since(createTime) < time.Duration(cfg.NewAccountDays)*24*time.Hour {
+		weight *= newAccountFactor
+		reasons = append(reasons, "new_account")
+
+		karma, err := abuseSignals.karma(userID)
+		if err != nil {
+			zap.L().Error("redis.GetKarma() failed", zap.Int64("user_id", userID), zap.Error(err))
+		} else if karma < cfg.MinKarma {
+			weight *= lowKarmaFactor
+			reasons = append(reasons, "low_karma")
+		}
+	}
+
+	// 3. 和帖子最近的投票人总是一起投票
+	stats, totalVotes, err := abuseSignals.coVoteStats(strconv.FormatInt(userID, 10), postID)
+	if err != nil {
+		zap.L().Error("redis.GetCoVoteStats() failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	for _, stat := range stats {
+		if isVoteRing(cfg, stat.CoVotes, totalVotes, stat.TotalVotes) {
+			weight *= ringFactor
+			reasons = append(reasons, "vote_ring")
+			break
+		}
+	}
+	return weight, reasons
+}
+
+// isVoteRing 两个账号的共同投票数足够多，且都占了各自投票的大部分
+func isVoteRing(cfg settings.AbuseConfig, coVotes, votesA, votesB int64) bool {
+	if coVotes < cfg.RingMinCoVotes || votesA == 0 || votesB == 0 {
+		return false
+	}
+	return float64(coVotes)/float64(votesA) >= cfg.RingRatio &&
+		float64(coVotes)/float64(votesB) >= cfg.RingRatio
+}
+
+// recordVoteForAbuse 投票成功后更新共同投票统计，发现结团的账号对时记录下来供管理员查看
+func recordVoteForAbuse(userID int64, postID string) {
+	cfg, ok := abuseConfig()
+	if !ok {
+		return
+	}
+	uid := strconv.FormatInt(userID, 10)
+	if err := redis.RecordCoVotes(uid, postID, cfg.RecentVoters); err != nil {
+		zap.L().Error("redis.RecordCoVotes() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return
+	}
+	stats, totalVotes, err := redis.GetCoVoteStats(uid, postID)
+	if err != nil {
+		zap.L().Error("redis.GetCoVoteStats() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return
+	}
+	for _, stat := range stats {
+		if !isVoteRing(cfg, stat.CoVotes, totalVotes, stat.TotalVotes) {
+			continue
+		}
+		other, _ := strconv.ParseInt(stat.UserID, 10, 64)
+		if err := redis.MarkSuspiciousPair(userID, other, stat.CoVotes); err != nil {
+			zap.L().Error("redis.MarkSuspiciousPair() failed", zap.Int64("user_id", userID), zap.Error(err))
+		}
+	}
+}
+
+// GetAbuseClusters 把疑似结团的账号对合并成团伙，按团伙人数倒序
+func GetAbuseClusters() ([]*models.AbuseCluster, error) {
+	pairs, err := redis.GetSuspiciousPairs(maxAbusePairs)
+	if err != nil {
+		zap.L().Error("redis.GetSuspiciousPairs() failed", zap.Error(err))
+		return nil, err
+	}
+
+	// 并查集
+	parent := make(map[int64]int64)
+	var find func(int64) int64
+	find = func(x int64) int64 {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	type pair struct {
+		a, b    int64
+		coVotes int64
+	}
+	edges := make([]pair, 0, len(pairs))
+	for _, z := range pairs {
+		member, _ := z.Member.(string)
+		ids := strings.SplitN(member, ":", 2)
+		if len(ids) != 2 {
+			continue
+		}
+		a, errA := strconv.ParseInt(ids[0], 10, 64)
+		b, errB := strconv.ParseInt(ids[1], 10, 64)
+		if errA != nil || errB != nil {
+			continue
+		}
+		for _, id := range []int64{a, b} {
+			if _, ok := parent[id]; !ok {
+				parent[id] = id
+			}
+		}
+		parent[find(a)] = find(b)
+		edges = append(edges, pair{a, b, int64(z.Score)})
+	}
+
+	groups := make(map[int64]*models.AbuseCluster)
+	members := make(map[int64][]int64)
+	for id := range parent {
+		root := find(id)
+		members[root] = append(members[root], id)
+	}
+	for _, e := range edges {
+		root := find(e.a)
+		cluster, ok := groups[root]
+		if !ok {
+			cluster = new(models.AbuseCluster)
+			groups[root] = cluster
+		}
+		cluster.Pairs++
+		if e.coVotes > cluster.MaxCoVotes {
+			cluster.MaxCoVotes = e.coVotes
+		}
+	}
+
+	userIDs := make([]int64, 0, len(parent))
+	for id := range parent {
+		userIDs = append(userIDs, id)
+	}
+	users, err := mysql.BatchGetUsersByIDs(userIDs)
+	if err != nil {
+		zap.L().Error("mysql.BatchGetUsersByIDs() failed", zap.Error(err))
+		return nil, err
+	}
+
+	clusters := make([]*models.AbuseCluster, 0, len(groups))
+	for root, cluster := range groups {
+		ids := members[root]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		for _, id := range ids {
+			cluster.UserIDs = append(cluster.UserIDs, strconv.FormatInt(id, 10))
+			if user, ok := users[id]; ok {
+				cluster.Usernames = append(cluster.Usernames, user.Username)
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].UserIDs) != len(clusters[j].UserIDs) {
+			return len(clusters[i].UserIDs) > len(clusters[j].UserIDs)
+		}
+		return clusters[i].MaxCoVotes > clusters[j].MaxCoVotes
+	})
+	return clusters, nil
+}
@@ -0,0 +1,77 @@
+package logic
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+)
+
+func TestVoteWeight(t *testing.T) {
+	const day = 24 * time.Hour
+	errLookup := errors.New("lookup failed")
+	tests := []struct {
+		name      string
+		disabled  bool
+		ipHit     bool
+		deviceHit bool
+		age       time.Duration // 账号注册了多久
+		ageErr    error
+		karma     float64
+		karmaErr  error
+		ring      bool // 和最近的投票人结团
+
+		wantWeight  float64
+		wantReasons []string
+	}{
+		{name: "disabled", disabled: true, deviceHit: true, age: time.Hour, wantWeight: 1},
+		// 声望上线之前注册的老账号声望都是 0，不降权
+		{name: "old account without karma", age: 365 * day, wantWeight: 1},
+		{name: "new account with karma", age: time.Hour, karma: 5, wantWeight: newAccountFactor, wantReasons: []string{"new_account"}},
+		{name: "new account low karma", age: time.Hour, karma: 4, wantWeight: newAccountFactor * lowKarmaFactor,
+			wantReasons: []string{"new_account", "low_karma"}},
+		{name: "new account karma lookup failed", age: time.Hour, karmaErr: errLookup, wantWeight: newAccountFactor,
+			wantReasons: []string{"new_account"}},
+		{name: "create time lookup failed", ageErr: errLookup, wantWeight: 1},
+		{name: "ip overlap", ipHit: true, age: 365 * day, wantWeight: ipOverlapFactor, wantReasons: []string{"ip_overlap"}},
+		{name: "device overlap", deviceHit: true, age: 365 * day, wantWeight: 0, wantReasons: []string{"device_overlap"}},
+		{name: "vote ring", ring: true, age: 365 * day, wantWeight: ringFactor, wantReasons: []string{"vote_ring"}},
+		{name: "all factors", ipHit: true, age: time.Hour, ring: true,
+			wantWeight:  ipOverlapFactor * newAccountFactor * lowKarmaFactor * ringFactor,
+			wantReasons: []string{"ip_overlap", "new_account", "low_karma", "vote_ring"}},
+	}
+
+	oldSignals, oldCfg := abuseSignals, settings.Conf.AbuseConfig
+	t.Cleanup(func() { abuseSignals, settings.Conf.AbuseConfig = oldSignals, oldCfg })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultAbuseConfig
+			cfg.Enable = !tt.disabled
+			settings.Conf.AbuseConfig = &cfg
+			abuseSignals.clientOverlap = func(int64, string, string) (bool, bool, error) {
+				return tt.ipHit, tt.deviceHit, nil
+			}
+			abuseSignals.createTime = func(int64) (time.Time, error) {
+				return time.Now().Add(-tt.age), tt.ageErr
+			}
+			abuseSignals.karma = func(int64) (float64, error) {
+				return tt.karma, tt.karmaErr
+			}
+			abuseSignals.coVoteStats = func(string, string) ([]redis.CoVoteStat, int64, error) {
+				if !tt.ring {
+					return []redis.CoVoteStat{{UserID: "3", CoVotes: 2, TotalVotes: 20}}, 20, nil
+				}
+				return []redis.CoVoteStat{{UserID: "3", CoVotes: 10, TotalVotes: 12}}, 11, nil
+			}
+
+			weight, reasons := voteWeight(1, 2, "100", &models.ClientInfo{IP: "10.0.0.1", DeviceID: "d1"})
+			if math.Abs(weight-tt.wantWeight) > 1e-9 || !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("voteWeight() = %v, %v, want %v, %v", weight, reasons, tt.wantWeight, tt.wantReasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSiteTitle = "bluebell"
+	defaultFeedSize  = 20
+	maxFeedSize      = 100
+	// 订阅源 id 使用 tag URI（RFC 4151），日期取雪花算法的起始时间，一经发布不能再修改
+	defaultTagDate = "2020-07-01"
+)
+
+var ErrorSiteBaseURL = errors.New("site.base_url 必须配置为 http(s)://域名[:端口] 形式的站点地址")
+
+// siteBaseURL 启动时检查过的站点地址，不以 / 结尾
+var siteBaseURL string
+
+// InitSite 检查并保存站点地址
+// 订阅源、站点地图中的链接和 tag URI 都由它生成，不能使用请求中客户端可以伪造的 Host
+func InitSite(cfg *settings.SiteConfig) error {
+	if cfg == nil || cfg.BaseURL == "" {
+		return ErrorSiteBaseURL
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" ||
+		u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return ErrorSiteBaseURL
+	}
+	siteBaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return nil
+}
+
+// SiteBaseURL 配置的站点地址
+func SiteBaseURL() string {
+	return siteBaseURL
+}
+
+// FeedMaxAge 订阅源、站点地图允许客户端缓存的时间
+func FeedMaxAge() time.Duration {
+	if cfg := settings.Conf.SiteConfig; cfg != nil && cfg.FeedMaxAge > 0 {
+		return time.Duration(cfg.FeedMaxAge) * time.Second
+	}
+	return 5 * time.Minute
+}
+
+func siteTitle() string {
+	if cfg := settings.Conf.SiteConfig; cfg != nil && cfg.Title != "" {
+		return cfg.Title
+	}
+	return defaultSiteTitle
+}
+
+func feedSize() int64 {
+	cfg := settings.Conf.SiteConfig
+	if cfg == nil || cfg.FeedSize <= 0 {
+		return defaultFeedSize
+	}
+	if cfg.FeedSize > maxFeedSize {
+		return maxFeedSize
+	}
+	return cfg.FeedSize
+}
+
+// tagDate 返回 tag URI 中的日期，以及订阅源没有帖子时使用的更新时间
+func tagDate() (string, time.Time) {
+	if t, err := time.ParseInLocation("2006-01-02", settings.Conf.StartTime, time.UTC); err == nil {
+		return settings.Conf.StartTime, t
+	}
+	t, _ := time.ParseInLocation("2006-01-02", defaultTagDate, time.UTC)
+	return defaultTagDate, t
+}
+
+// tagURI 生成 tag:<域名>,<日期>:<specific> 形式的 id，域名取配置的站点地址
+// 帖子的 specific 部分是雪花算法生成的帖子id，全局唯一且不会变化
+func tagURI(specific string) string {
+	host := "localhost"
+	if u, err := url.Parse(siteBaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	date, _ := tagDate()
+	return fmt.Sprintf("tag:%s,%s:%s", host, date, specific)
+}
+
+// GetSiteAtomFeed 全站最新帖子的订阅源
+func GetSiteAtomFeed() (*models.AtomFeed, error) {
+	data, err := GetPostListNew(&models.ParamsPostList{
+		Page:  1,
+		Size:  feedSize(),
+		Order: models.OrderTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newAtomFeed(siteBaseURL, "feed:all", siteTitle(), "/feeds/all.atom", data), nil
+}
+
+// GetCommunityAtomFeed 社区最新帖子的订阅源
+func GetCommunityAtomFeed(communityID int64) (*models.AtomFeed, error) {
+	community, err := getCommunityDetailByID(communityID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := GetCommunityPostList(&models.ParamsCommunityPostList{
+		ParamsPostList: &models.ParamsPostList{
+			CommunityID: communityID,
+			Page:        1,
+			Size:        feedSize(),
+			Order:       models.OrderTime,
+		},
+	})
+	if err != nil {
+		zap.L().Error("logic.GetCommunityPostList() failed", zap.Int64("community_id", communityID), zap.Error(err))
+		return nil, err
+	}
+	id := strconv.FormatInt(communityID, 10)
+	return newAtomFeed(siteBaseURL, "feed:community:"+id,
+		fmt.Sprintf("%s - %s", community.Name, siteTitle()), "/feeds/community/"+id+".atom", data), nil
+}
+
+// GetUserAtomFeed 作者最新帖子的订阅源
+func GetUserAtomFeed(userID int64) (*models.AtomFeed, error) {
+	user, err := getUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := GetUserPostList(userID, 1, feedSize())
+	if err != nil {
+		return nil, err
+	}
+	id := strconv.FormatInt(userID, 10)
+	return newAtomFeed(siteBaseURL, "feed:user:"+id,
+		fmt.Sprintf("%s - %s", user.Username, siteTitle()), "/feeds/user/"+id+".atom", data), nil
+}
+
+// newAtomFeed 把帖子列表转换成订阅源，订阅源的更新时间取最近更新的帖子
+func newAtomFeed(baseURL, feedID, title, selfPath string, data []*models.ApiPostDetail) *models.AtomFeed {
+	_, updated := tagDate()
+	feed := &models.AtomFeed{
+		ID:    tagURI(feedID),
+		Title: title,
+		Links: []models.AtomLink{
+			{Href: baseURL + selfPath, Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL + "/", Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]*models.AtomEntry, 0, len(data)),
+	}
+	// 补充转发的原帖，订阅源里转发的帖子展示原帖的内容
+	data = FillViewerState(0, data)
+	for _, detail := range data {
+		entry := newAtomEntry(baseURL, detail)
+		if entry.Updated.After(updated) {
+			updated = entry.Updated
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	feed.Updated = updated
+	return feed
+}
+
+func newAtomEntry(baseURL string, detail *models.ApiPostDetail) *models.AtomEntry {
+	post := detail.Post
+	// 定时发布的帖子以发布时间为准
+	published := post.CreateTime
+	if post.PublishTime != nil && post.PublishTime.After(published) {
+		published = *post.PublishTime
+	}
+	updated := published
+	if post.UpdateTime != nil && post.UpdateTime.After(updated) {
+		updated = *post.UpdateTime
+	}
+
+	entry := &models.AtomEntry{
+		ID:        tagURI("post:" + strconv.FormatInt(post.ID, 10)),
+		Title:     post.Title,
+		Published: published.UTC().Truncate(time.Second),
+		Updated:   updated.UTC().Truncate(time.Second),
+		Author: models.AtomPerson{
+			Name: detail.AuthorName,
+			URI:  baseURL + "/feeds/user/" + strconv.FormatInt(post.AuthorID, 10) + ".atom",
+		},
+		Links:   []models.AtomLink{{Href: baseURL + PostPath(post.ID, post.Title), Rel: "alternate", Type: "text/html"}},
+		Content: &models.AtomText{Type: "text", Body: post.Content},
+	}
+	if post.URL != "" {
+		entry.Links = append(entry.Links, models.AtomLink{Href: post.URL, Rel: "related"})
+	}
+	if detail.CommunityDetail != nil {
+		entry.Category = &models.AtomCategory{
+			Term:  strconv.FormatInt(detail.CommunityDetail.ID, 10),
+			Label: detail.CommunityDetail.Name,
+		}
+	}
+	return entry
+}
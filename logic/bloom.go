@@ -0,0 +1,281 @@
+package logic
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/pkg/bloom"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	BloomPost      = "post"
+	BloomUser      = "user"
+	BloomCommunity = "community"
+
+	bloomBatchSize       = 1000
+	bloomRebuildLockTTL  = 10 * time.Minute
+	bloomReadyCheckDelay = 30 * time.Second
+)
+
+var (
+	ErrorBloomDisabled   = errors.New("布隆过滤器没有开启")
+	ErrorBloomRebuilding = errors.New("其他实例正在重建布隆过滤器")
+)
+
+// bloomFilter 一类id的布隆过滤器，位数组保存在 Redis 中，所有实例共用
+type bloomFilter struct {
+	kind   string
+	params bloom.Params
+	scan   func(afterID int64, limit int) ([]int64, error)
+
+	ready      atomic.Bool
+	lastCheck  atomic.Int64 // 上次检查是否构建完成的时间，没有构建完成时不做拦截
+	rebuilding atomic.Bool  // 本实例是否正在后台重建
+	missing    atomic.Int64 // 写入失败、过滤器中缺少的新id的个数，重建成功之前不做拦截
+
+	checks         atomic.Int64 // 查询次数
+	rejected       atomic.Int64 // 判定一定不存在、直接拦截的次数
+	falsePositives atomic.Int64 // 判定可能存在但实际不存在的次数
+}
+
+var bloomFilters map[string]*bloomFilter
+
+// newBloomFilters 按配置计算每个布隆过滤器的参数，没有开启时返回 nil
+func newBloomFilters(cfg *settings.BloomConfig) map[string]*bloomFilter {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	expected := func(n, def uint64) uint64 {
+		if n == 0 {
+			return def
+		}
+		return n
+	}
+	return map[string]*bloomFilter{
+		BloomPost: {
+			kind:   BloomPost,
+			params: bloom.Optimal(expected(cfg.ExpectedPosts, 1000000), cfg.FalsePositiveRate),
+			scan:   mysql.GetPostIDsAfter,
+		},
+		BloomUser: {
+			kind:   BloomUser,
+			params: bloom.Optimal(expected(cfg.ExpectedUsers, 1000000), cfg.FalsePositiveRate),
+			scan:   mysql.GetUserIDsAfter,
+		},
+		BloomCommunity: {
+			kind:   BloomCommunity,
+			params: bloom.Optimal(expected(cfg.ExpectedCommunities, 10000), cfg.FalsePositiveRate),
+			scan:   mysql.GetCommunityIDsAfter,
+		},
+	}
+}
+
+// InitBloomFilter 计算布隆过滤器的参数，Redis 中没有按当前参数构建的过滤器时在后台重建
+// 重建完成之前不做拦截，所有请求照常查询
+func InitBloomFilter(cfg *settings.BloomConfig) {
+	bloomFilters = newBloomFilters(cfg)
+	for _, f := range bloomFilters {
+		if !f.checkReady() {
+			f.rebuildAsync()
+		}
+	}
+}
+
+// checkReady 检查 Redis 中的过滤器是否已经按当前参数构建完成，最多每 30 秒检查一次
+// 构建完成之后也要定期检查：其他实例换了参数重建、key 被删除或者 Redis 数据丢失时停止拦截
+func (f *bloomFilter) checkReady() bool {
+	ready := f.ready.Load()
+	now := time.Now().UnixNano()
+	last := f.lastCheck.Load()
+	if now-last < int64(bloomReadyCheckDelay) || !f.lastCheck.CompareAndSwap(last, now) {
+		return ready
+	}
+	ready, err := redis.BloomReady(f.kind, f.params)
+	if err != nil {
+		zap.L().Error("redis.BloomReady() failed", zap.String("kind", f.kind), zap.Error(err))
+		return f.ready.Load()
+	}
+	// 有新id没有写进去时，即使其他实例已经构建完成也要等本实例重建之后才能拦截
+	if ready && f.missing.Load() == 0 {
+		f.ready.Store(true)
+		return true
+	}
+	f.markNotReady()
+	return false
+}
+
+// markNotReady 过滤器已经不可用，停止拦截并在后台重建
+func (f *bloomFilter) markNotReady() {
+	if f.ready.Swap(false) {
+		zap.L().Warn("bloom filter lost, rebuilding", zap.String("kind", f.kind))
+	}
+	f.rebuildAsync()
+}
+
+// rebuildAsync 在后台重建，同一个实例同时只有一个重建任务
+// 其他实例正在重建时跳过，完成后由 checkReady 发现
+func (f *bloomFilter) rebuildAsync() {
+	if !f.rebuilding.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer f.rebuilding.Store(false)
+		if err := f.rebuildWithLock(); err != nil && !errors.Is(err, ErrorBloomRebuilding) {
+			zap.L().Error("rebuild bloom filter failed", zap.String("kind", f.kind), zap.Error(err))
+		}
+	}()
+}
+
+// rebuildWithLock 多个实例之间只允许一个实例重建
+func (f *bloomFilter) rebuildWithLock() error {
+	locked, err := redis.BloomRebuildLock(f.kind, bloomRebuildLockTTL)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return ErrorBloomRebuilding
+	}
+	defer func() {
+		if err := redis.BloomRebuildUnlock(f.kind); err != nil {
+			zap.L().Error("redis.BloomRebuildUnlock() failed", zap.String("kind", f.kind), zap.Error(err))
+		}
+	}()
+	return f.rebuild()
+}
+
+// rebuild 从 MySQL 分批读取所有id写入新的过滤器，完成后替换旧的
+func (f *bloomFilter) rebuild() error {
+	start := time.Now()
+	zap.L().Info("rebuild bloom filter started", zap.String("kind", f.kind),
+		zap.Uint64("m", f.params.M), zap.Uint64("k", f.params.K))
+	if err := redis.BloomRebuildStart(f.kind); err != nil {
+		return err
+	}
+	// 开始之前写入失败的id都已经提交到 MySQL，重建时会扫描到
+	missing := f.missing.Load()
+	var lastID, total int64
+	for {
+		ids, err := f.scan(lastID, bloomBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := redis.BloomRebuildAdd(f.kind, f.params, ids); err != nil {
+			return err
+		}
+		lastID = ids[len(ids)-1]
+		total += int64(len(ids))
+	}
+	if err := redis.BloomRebuildFinish(f.kind, f.params); err != nil {
+		return err
+	}
+	// 重建期间新增的id写入的是旧的过滤器，替换之后补上
+	for {
+		ids, err := f.scan(lastID, bloomBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := redis.BloomAdd(f.kind, f.params, ids...); err != nil {
+			return err
+		}
+		lastID = ids[len(ids)-1]
+		total += int64(len(ids))
+	}
+	// 重建期间又有写入失败的id时保持不拦截，下次 checkReady 再重建
+	f.ready.Store(f.missing.CompareAndSwap(missing, 0))
+	zap.L().Info("rebuild bloom filter completed", zap.String("kind", f.kind),
+		zap.Int64("count", total), zap.Duration("cost", time.Since(start)))
+	return nil
+}
+
+// RebuildBloomFilters 按当前配置重建所有布隆过滤器，由 rebuild-bloom 子命令调用
+func RebuildBloomFilters() error {
+	filters := newBloomFilters(settings.Conf.BloomConfig)
+	if filters == nil {
+		return ErrorBloomDisabled
+	}
+	for _, kind := range []string{BloomPost, BloomUser, BloomCommunity} {
+		if err := filters[kind].rebuildWithLock(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bloomMayContain id 是否可能存在，返回 false 时一定不存在
+// 没有开启、还没有构建完成、已经不可用或者 Redis 出错时都返回 true，不影响正常查询
+func bloomMayContain(kind string, id int64) bool {
+	f, ok := bloomFilters[kind]
+	// Redis 熔断时不检查，直接查缓存和数据库
+	if !ok || !f.checkReady() || !redis.Available() {
+		return true
+	}
+	f.checks.Add(1)
+	exists, err := redis.BloomExists(kind, f.params, id)
+	if err == redis.Nil {
+		f.markNotReady()
+		return true
+	}
+	if err != nil {
+		zap.L().Error("redis.BloomExists() failed", zap.String("kind", kind), zap.Error(err))
+		return true
+	}
+	if !exists {
+		f.rejected.Add(1)
+	}
+	return exists
+}
+
+// bloomFalsePositive 布隆过滤器判定可能存在，但实际查询结果不存在
+func bloomFalsePositive(kind string) {
+	if f, ok := bloomFilters[kind]; ok && f.ready.Load() {
+		f.falsePositives.Add(1)
+	}
+}
+
+// bloomAdd 新建数据后加入布隆过滤器
+// 写入失败时过滤器中缺少这个id，会把已经存在的数据判定为不存在：停止拦截并在后台重建
+func bloomAdd(kind string, id int64) {
+	f, ok := bloomFilters[kind]
+	if !ok {
+		return
+	}
+	if err := redis.BloomAdd(kind, f.params, id); err != nil {
+		zap.L().Error("redis.BloomAdd() failed", zap.String("kind", kind), zap.Int64("id", id), zap.Error(err))
+		f.missing.Add(1)
+		f.markNotReady()
+	}
+}
+
+// bloomStats 布隆过滤器的拦截情况和误判率
+// false_positive_rate 是实际不存在的id中没有被拦截的比例，estimated_false_positive_rate 是根据置位比例估算的理论值
+func bloomStats() map[string]interface{} {
+	result := make(map[string]interface{}, len(bloomFilters))
+	for kind, f := range bloomFilters {
+		rejected, falsePositives := f.rejected.Load(), f.falsePositives.Load()
+		stat := map[string]interface{}{
+			"ready":               f.ready.Load(),
+			"m":                   f.params.M,
+			"k":                   f.params.K,
+			"checks":              f.checks.Load(),
+			"rejected":            rejected,
+			"false_positives":     falsePositives,
+			"false_positive_rate": hitRate(falsePositives, rejected+falsePositives),
+		}
+		if bits, err := redis.BloomBitCount(kind); err == nil {
+			stat["estimated_false_positive_rate"] = f.params.EstimateFalsePositive(uint64(bits))
+		}
+		result[kind] = stat
+	}
+	return result
+}
@@ -0,0 +1,229 @@
+package logic
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/pkg/cache"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+// 帖子详情、用户、社区的旁路缓存
+// 缓存在第一次使用时才访问 Redis，可以在 redis.Init 之前创建
+var (
+	postDetailCache = cache.New(cache.Options[int64, *models.ApiPostDetail]{
+		Name:        "post",
+		Store:       redis.CacheStore(),
+		Key:         redis.PostDetailCacheKey,
+		Load:        queryPostDetailFromDB,
+		TTL:         redis.PostDetailCacheExpire,
+		Jitter:      redis.CacheExpireJitter,
+		NotFound:    mysql.ErrorInvalidID,
+		NegativeTTL: redis.NegativeCacheExpire,
+		// 热门帖子的缓存过期时多个实例会同时回源，用分布式锁合并
+		Lock:     true,
+		LockTTL:  redis.CacheLockExpire,
+		LockWait: redis.CacheLockRetry,
+		Recorder: redis.PostCacheStats,
+		// Redis 不可用时直接查询数据库
+		Bypass: redisUnavailable,
+	})
+
+	userCache = cache.New(cache.Options[int64, *models.User]{
+		Name:        "user",
+		Store:       redis.CacheStore(),
+		Key:         redis.UserCacheKey,
+		Load:        mysql.GetUserByID,
+		LoadBatch:   mysql.BatchGetUsersByIDs,
+		Encode:      withoutPassword,
+		TTL:         redis.UserInfoCacheExpire,
+		Jitter:      redis.CacheExpireJitter,
+		NotFound:    sql.ErrNoRows,
+		NegativeTTL: redis.NegativeCacheExpire,
+		Recorder:    redis.UserCacheStats,
+		Bypass:      redisUnavailable,
+	})
+
+	communityCache = cache.New(cache.Options[int64, *models.CommunityDetail]{
+		Name:        "community",
+		Store:       redis.CacheStore(),
+		Key:         redis.CommunityCacheKey,
+		Load:        mysql.GetCommunityDetailByID,
+		LoadBatch:   mysql.BatchGetCommunitiesByIDs,
+		TTL:         redis.CommunityInfoCacheExpire,
+		Jitter:      redis.CacheExpireJitter,
+		NotFound:    mysql.ErrorInvalidID,
+		NegativeTTL: redis.NegativeCacheExpire,
+		Recorder:    redis.CommunityCacheStats,
+		Bypass:      redisUnavailable,
+	})
+)
+
+var (
+	cacheCancel context.CancelFunc
+	cacheWG     sync.WaitGroup
+)
+
+func redisUnavailable() bool {
+	return !redis.Available()
+}
+
+// InitCache 开启进程内的一级缓存，并订阅其他实例的缓存失效通知
+// 一级缓存的过期时间很短，订阅断开期间错过的通知最多导致这段时间内读到旧数据
+func InitCache(cfg *settings.CacheConfig) {
+	if cfg == nil {
+		return
+	}
+	postDetailCache.EnableLocal(cfg.LocalPostSize, time.Duration(cfg.LocalPostTTL)*time.Second)
+	userCache.EnableLocal(cfg.LocalUserSize, time.Duration(cfg.LocalUserTTL)*time.Second)
+	communityCache.EnableLocal(cfg.LocalCommunitySize, time.Duration(cfg.LocalCommunityTTL)*time.Second)
+	initPostListCache(cfg)
+
+	redis.OnCacheInvalidate(func(keys []string) {
+		postDetailCache.Invalidate(keys...)
+		userCache.Invalidate(keys...)
+		communityCache.Invalidate(keys...)
+	})
+
+	var ctx context.Context
+	ctx, cacheCancel = context.WithCancel(context.Background())
+	cacheWG.Add(1)
+	go func() {
+		defer cacheWG.Done()
+		for {
+			err := redis.SubscribeCacheInvalidation(ctx, purgeLocalCache)
+			if ctx.Err() != nil {
+				return
+			}
+			zap.L().Error("redis.SubscribeCacheInvalidation() failed, retry later", zap.Error(err))
+			// 订阅断开期间收不到失效通知，清空一级缓存
+			purgeLocalCache()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+// StopCache 停止订阅缓存失效通知，返回前等待订阅的连接关闭
+func StopCache() {
+	if cacheCancel != nil {
+		cacheCancel()
+		cacheWG.Wait()
+	}
+}
+
+func purgeLocalCache() {
+	postDetailCache.PurgeLocal()
+	userCache.PurgeLocal()
+	communityCache.PurgeLocal()
+}
+
+// 缓存统计的时间窗口
+var cacheStatsWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"1h", time.Hour},
+}
+
+const (
+	keyspaceMaxKeys      = 100000          // 统计 key 分布时最多扫描的 key 数
+	keyspaceSamples      = 20              // 每个前缀用 MEMORY USAGE 抽样的 key 数
+	keyspaceStatsRefresh = 1 * time.Minute // key 分布的统计结果缓存时间，避免频繁 SCAN
+)
+
+var keyspaceStats struct {
+	sync.Mutex
+	result    *redis.Keyspace
+	updatedAt time.Time
+}
+
+// GetCacheStats 各个缓存的一级缓存（进程内）和二级缓存（Redis）命中率、Redis 命令耗时和 key 分布
+func GetCacheStats() map[string]interface{} {
+	localLen := map[string]int{
+		"post":      postDetailCache.LocalLen(),
+		"user":      userCache.LocalLen(),
+		"community": communityCache.LocalLen(),
+	}
+	result := make(map[string]interface{})
+	for cacheType, stat := range redis.GetCacheStats() {
+		s := cacheStatsMap(stat.Total())
+		s["l1_size"] = localLen[cacheType]
+		windows := make(map[string]interface{}, len(cacheStatsWindows))
+		for _, w := range cacheStatsWindows {
+			windows[w.name] = cacheStatsMap(stat.Recent(w.d))
+		}
+		s["windows"] = windows
+		result[cacheType] = s
+	}
+	if bloomFilters != nil {
+		result["bloom"] = bloomStats()
+	}
+	result["warmup"] = warmUpStatsMap()
+	result["redis_latency"] = redis.GetOpLatencyStats()
+	if ks := getKeyspaceStats(); ks != nil {
+		result["keyspace"] = ks
+	}
+	return result
+}
+
+func cacheStatsMap(stat redis.CacheStatsSnapshot) map[string]interface{} {
+	// 一级缓存未命中的请求才会读 Redis
+	requests := stat.LocalHitCount + stat.HitCount + stat.MissCount
+	l2Requests := stat.HitCount + stat.MissCount
+	return map[string]interface{}{
+		"requests":     requests,
+		"l1_hit_count": stat.LocalHitCount,
+		"l1_hit_rate":  hitRate(stat.LocalHitCount, requests),
+		"l2_hit_count": stat.HitCount,
+		"l2_hit_rate":  hitRate(stat.HitCount, l2Requests),
+		"miss_count":   stat.MissCount,
+		"error_count":  stat.ErrorCount,
+		"hit_rate":     hitRate(stat.LocalHitCount+stat.HitCount, requests),
+	}
+}
+
+// getKeyspaceStats 按前缀统计的 key 数量和内存，结果缓存 1 分钟，出错时返回上一次的结果
+func getKeyspaceStats() *redis.Keyspace {
+	keyspaceStats.Lock()
+	defer keyspaceStats.Unlock()
+	if keyspaceStats.result != nil && time.Since(keyspaceStats.updatedAt) < keyspaceStatsRefresh {
+		return keyspaceStats.result
+	}
+	ks, err := redis.SampleKeyspace(keyspaceMaxKeys, keyspaceSamples)
+	if err != nil {
+		zap.L().Error("redis.SampleKeyspace() failed", zap.Error(err))
+		return keyspaceStats.result
+	}
+	keyspaceStats.result, keyspaceStats.updatedAt = ks, time.Now()
+	return ks
+}
+
+func hitRate(hit, total int64) string {
+	if total == 0 {
+		return "0.00%"
+	}
+	return fmt.Sprintf("%.2f%%", float64(hit)/float64(total)*100)
+}
+
+// withoutPassword 敏感信息脱敏：不缓存密码
+func withoutPassword(user *models.User) *models.User {
+	if user == nil || user.Password == "" {
+		return user
+	}
+	u := *user
+	u.Password = ""
+	return &u
+}
@@ -1,10 +1,25 @@
 package logic
 
 import (
+	"errors"
+	"fmt"
+	"time"
 	"web-app/dao/mysql"
+	"web-app/dao/redis"
 	"web-app/models"
+
+	"go.uber.org/zap"
 )
 
+// CommunityRuleError 不符合社区的发帖规则
+type CommunityRuleError struct {
+	Reason string
+}
+
+func (e *CommunityRuleError) Error() string {
+	return e.Reason
+}
+
 func GetCommunityList() ([]*models.Community, error) {
 	// 查询数据库 查找到所有的community 并返回
 
@@ -13,6 +28,53 @@ func GetCommunityList() ([]*models.Community, error) {
 }
 
 func GetCommunityDetail(id int64) (*models.CommunityDetail, error) {
-	// 查询数据库
-	return mysql.GetCommunityDetailByID(id)
+	return getCommunityDetailByID(id)
+}
+
+// getCommunityDetailByID 根据社区id查询社区，布隆过滤器判定一定不存在的社区直接返回，不查数据库
+func getCommunityDetailByID(id int64) (*models.CommunityDetail, error) {
+	if !bloomMayContain(BloomCommunity, id) {
+		return nil, mysql.ErrorInvalidID
+	}
+	community, err := mysql.GetCommunityDetailByID(id)
+	if errors.Is(err, mysql.ErrorInvalidID) {
+		bloomFalsePositive(BloomCommunity)
+	}
+	return community, err
+}
+
+// checkCommunityRules 检查帖子是否符合所在社区的发帖规则，发帖和转发都要检查
+func checkCommunityRules(p *models.Post) error {
+	rule, err := mysql.GetCommunityRule(p.CommunityID)
+	if err != nil {
+		zap.L().Error("mysql.GetCommunityRule() failed", zap.Int64("community_id", p.CommunityID), zap.Error(err))
+		return err
+	}
+	if p.CrosspostOf != 0 && !rule.AllowCrosspost {
+		return &CommunityRuleError{Reason: "该社区不接受转发的帖子"}
+	}
+	if p.URL != "" && !rule.AllowLink {
+		return &CommunityRuleError{Reason: "该社区不允许发布链接帖子"}
+	}
+	if rule.MinAccountDays > 0 {
+		createTime, err := mysql.GetUserCreateTime(p.AuthorID)
+		if err != nil {
+			zap.L().Error("mysql.GetUserCreateTime() failed", zap.Int64("user_id", p.AuthorID), zap.Error(err))
+			return err
+		}
+		if time.Since(createTime) < time.Duration(rule.MinAccountDays)*24*time.Hour {
+			return &CommunityRuleError{Reason: fmt.Sprintf("该社区要求注册满 %d 天才能发帖", rule.MinAccountDays)}
+		}
+	}
+	if rule.MinKarma > 0 {
+		karma, err := redis.GetKarma(p.AuthorID)
+		if err != nil {
+			zap.L().Error("redis.GetKarma() failed", zap.Int64("user_id", p.AuthorID), zap.Error(err))
+			return err
+		}
+		if karma < float64(rule.MinKarma) {
+			return &CommunityRuleError{Reason: fmt.Sprintf("该社区要求声望达到 %d 才能发帖", rule.MinKarma)}
+		}
+	}
+	return nil
 }
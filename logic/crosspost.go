@@ -0,0 +1,123 @@
+package logic
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/pkg/snowflake"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorCrosspostSource        = errors.New("只能转发已发布的帖子")
+	ErrorCrosspostSameCommunity = errors.New("不能转发到原帖所在的社区")
+	ErrorCrosspostExists        = errors.New("该帖子已经转发到这个社区")
+)
+
+// Crosspost 把帖子转发到另一个社区
+// 转发是一篇新帖子，在目标社区有自己的投票和排名，内容和作者展示原帖的
+// 转发的转发指向最初的原帖
+func Crosspost(userID, postID int64, p *models.ParamsCrosspost) (post *models.Post, err error) {
+	original, err := mysql.GetPostByID(postID)
+	if err != nil {
+		zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if original.CrosspostOf != 0 {
+		if original, err = mysql.GetPostByID(original.CrosspostOf); err != nil {
+			zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+			return nil, err
+		}
+	}
+	if original.Status != models.PostStatusNormal {
+		return nil, ErrorCrosspostSource
+	}
+	if original.CommunityID == p.CommunityID {
+		return nil, ErrorCrosspostSameCommunity
+	}
+	if _, err = getCommunityDetailByID(p.CommunityID); err != nil {
+		return nil, err
+	}
+	existID, err := mysql.GetCrosspostID(original.ID, p.CommunityID)
+	if err != nil {
+		zap.L().Error("mysql.GetCrosspostID() failed", zap.Int64("post_id", original.ID), zap.Error(err))
+		return nil, err
+	}
+	if existID != 0 {
+		return nil, ErrorCrosspostExists
+	}
+
+	post = &models.Post{
+		AuthorID:    userID,
+		CommunityID: p.CommunityID,
+		Title:       strings.TrimSpace(p.Title),
+		URL:         original.URL,
+		URLHash:     original.URLHash,
+		CrosspostOf: original.ID,
+	}
+	if post.Title == "" {
+		post.Title = original.Title
+	}
+	// 和发帖一样检查目标社区的规则、重复链接、敏感词和发帖频率
+	if err = checkCommunityRules(post); err != nil {
+		return nil, err
+	}
+	if err = checkDuplicateLink(post); err != nil {
+		return nil, err
+	}
+	if post.Status, err = filterPost(post); err != nil {
+		return nil, err
+	}
+	if err = checkSpam(post); err != nil {
+		return nil, err
+	}
+
+	post.ID = snowflake.GenID()
+	if err = mysql.CreatePost(post, nil); err != nil {
+		zap.L().Error("mysql.CreatePost() failed", zap.Error(err))
+		return nil, err
+	}
+	bloomAdd(BloomPost, post.ID)
+	if post.Status == models.PostStatusPending {
+		return post, redis.AddPostToReview(post.ID)
+	}
+	return post, publishPost(post, time.Now())
+}
+
+// fillCrossposts 补充转发帖子的原帖，原帖不可见时不返回
+func fillCrossposts(data []*models.ApiPostDetail) {
+	ids := make([]string, 0)
+	seen := make(map[int64]bool)
+	for _, d := range data {
+		if d != nil && d.Post != nil && d.Post.CrosspostOf != 0 && !seen[d.Post.CrosspostOf] {
+			seen[d.Post.CrosspostOf] = true
+			ids = append(ids, strconv.FormatInt(d.Post.CrosspostOf, 10))
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	posts, err := mysql.GetPostListByIDs(ids)
+	if err != nil {
+		zap.L().Error("mysql.GetPostListByIDs() failed", zap.Error(err))
+		return
+	}
+	parents, err := buildPostDetails(posts)
+	if err != nil {
+		return
+	}
+	parentMap := make(map[int64]*models.ApiPostDetail, len(parents))
+	for _, parent := range parents {
+		parentMap[parent.Post.ID] = parent
+	}
+	for _, d := range data {
+		if d != nil && d.Post != nil && d.Post.CrosspostOf != 0 {
+			d.CrosspostParent = parentMap[d.Post.CrosspostOf]
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package logic
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/pkg/filter"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorContentRejected  = errors.New("内容包含违禁词")
+	ErrorDuplicateContent = errors.New("请勿重复发布相同内容")
+	ErrorPostTooFrequent  = errors.New("发帖过于频繁")
+)
+
+// filterPost 对帖子标题和内容做敏感词过滤
+// mask 类的词直接在 p 上打码，返回帖子应处的状态（正常/待审核）
+func filterPost(p *models.Post) (status int32, err error) {
+	status = models.PostStatusNormal
+	for _, text := range []*string{&p.Title, &p.Content} {
+		res := filter.Check(*text)
+		switch res.Action {
+		case filter.ActionReject:
+			zap.L().Info("post rejected by word filter",
+				zap.Int64("author_id", p.AuthorID),
+				zap.Strings("hits", res.Hits))
+			return status, ErrorContentRejected
+		case filter.ActionReview:
+			status = models.PostStatusPending
+		}
+		*text = res.Text
+	}
+
+	// 链接过多的帖子交给人工审核
+	cfg := settings.Conf.FilterConfig
+	if cfg != nil && cfg.MaxLinks > 0 && filter.CountLinks(p.Content) > cfg.MaxLinks {
+		status = models.PostStatusPending
+	}
+	return status, nil
+}
+
+// checkSpam 发帖的反垃圾检查：发帖频率以及近期重复内容
+// 只在发帖时检查，编辑帖子时不检查
+func checkSpam(p *models.Post) error {
+	cfg := settings.Conf.FilterConfig
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.VelocityWindow > 0 && cfg.VelocityLimit > 0 {
+		count, err := redis.IncrPostVelocity(p.AuthorID, time.Duration(cfg.VelocityWindow)*time.Second)
+		if err != nil {
+			// 反垃圾检查失败不影响正常发帖
+			zap.L().Error("redis.IncrPostVelocity() failed", zap.Error(err))
+		} else if count > int64(cfg.VelocityLimit) {
+			return ErrorPostTooFrequent
+		}
+	}
+
+	if cfg.DuplicateWindow > 0 {
+		dup, err := redis.CheckDuplicateContent(p.AuthorID, contentHash(duplicateText(p)),
+			time.Duration(cfg.DuplicateWindow)*time.Minute)
+		if err != nil {
+			zap.L().Error("redis.CheckDuplicateContent() failed", zap.Error(err))
+		} else if dup {
+			return ErrorDuplicateContent
+		}
+	}
+	return nil
+}
+
+// duplicateText 参与重复检测的文本，链接帖子和转发的帖子内容可以为空，需要带上链接和转发的目标
+func duplicateText(p *models.Post) string {
+	text := p.Content
+	if p.URL != "" {
+		text = p.URL + "\n" + text
+	}
+	if p.CrosspostOf != 0 {
+		text = fmt.Sprintf("crosspost:%d:%d\n%s", p.CrosspostOf, p.CommunityID, text)
+	}
+	return text
+}
+
+// contentHash 计算去掉空白后的内容哈希，避免加几个空格就绕过重复检测
+func contentHash(content string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), "")
+	sum := sha1.Sum([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,221 @@
+package logic
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+var ErrorFollowSelf = errors.New("不能关注自己")
+
+const (
+	defaultFanoutThreshold = 1000
+	defaultInboxSize       = 500
+)
+
+// feedConfig 读取关注动态配置，未配置时使用默认值
+func feedConfig() (fanoutThreshold, inboxSize int64) {
+	fanoutThreshold, inboxSize = defaultFanoutThreshold, defaultInboxSize
+	if cfg := settings.Conf.FeedConfig; cfg != nil {
+		if cfg.FanoutThreshold > 0 {
+			fanoutThreshold = cfg.FanoutThreshold
+		}
+		if cfg.InboxSize > 0 {
+			inboxSize = cfg.InboxSize
+		}
+	}
+	return
+}
+
+// Follow followerID 关注 userID
+func Follow(userID, followerID int64) error {
+	if userID == followerID {
+		return ErrorFollowSelf
+	}
+	if _, err := getUserByID(userID); err != nil {
+		return err
+	}
+
+	if _, err := mysql.InsertFollow(userID, followerID); err != nil {
+		zap.L().Error("mysql.InsertFollow() failed", zap.Error(err))
+		return err
+	}
+	// MySQL 中已经关注过时也要写 Redis：上次写 Redis 失败后用户重试，Redis 才能和 MySQL 一致
+	added, err := redis.Follow(userID, followerID)
+	if err != nil {
+		zap.L().Error("redis.Follow() failed", zap.Error(err))
+		return err
+	}
+	if !added {
+		// Redis 中也已经关注过了
+		return nil
+	}
+
+	// 普通作者的帖子是推送到收件箱的，新关注时补上作者最近的帖子
+	// 大V的帖子在读取动态时实时拉取，不需要补
+	fanoutThreshold, inboxSize := feedConfig()
+	count, err := redis.GetFollowerCount(userID)
+	if err == nil && count <= fanoutThreshold {
+		err = redis.BackfillInbox(followerID, userID, inboxSize)
+	}
+	if err != nil {
+		zap.L().Error("backfill feed inbox failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("follower_id", followerID),
+			zap.Error(err))
+	}
+	return nil
+}
+
+// Unfollow followerID 取消关注 userID
+func Unfollow(userID, followerID int64) error {
+	if _, err := mysql.DeleteFollow(userID, followerID); err != nil {
+		zap.L().Error("mysql.DeleteFollow() failed", zap.Error(err))
+		return err
+	}
+	// 和 Follow 一样，MySQL 中已经删除时也要删除 Redis 中的关系
+	removed, err := redis.Unfollow(userID, followerID)
+	if err != nil {
+		zap.L().Error("redis.Unfollow() failed", zap.Error(err))
+		return err
+	}
+	if !removed {
+		return nil
+	}
+	_, inboxSize := feedConfig()
+	if err := redis.RemoveAuthorFromInbox(followerID, userID, inboxSize); err != nil {
+		zap.L().Error("redis.RemoveAuthorFromInbox() failed", zap.Error(err))
+	}
+	return nil
+}
+
+// GetFollowers 获取粉丝列表及粉丝数
+func GetFollowers(userID, page, size int64) (data *models.ApiFollowList, err error) {
+	data = new(models.ApiFollowList)
+	if data.Total, err = mysql.CountFollowers(userID); err != nil {
+		zap.L().Error("mysql.CountFollowers() failed", zap.Error(err))
+		return nil, err
+	}
+	if data.List, err = mysql.GetFollowers(userID, page, size); err != nil {
+		zap.L().Error("mysql.GetFollowers() failed", zap.Error(err))
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetFollowing 获取关注列表及关注数
+func GetFollowing(userID, page, size int64) (data *models.ApiFollowList, err error) {
+	data = new(models.ApiFollowList)
+	if data.Total, err = mysql.CountFollowing(userID); err != nil {
+		zap.L().Error("mysql.CountFollowing() failed", zap.Error(err))
+		return nil, err
+	}
+	if data.List, err = mysql.GetFollowing(userID, page, size); err != nil {
+		zap.L().Error("mysql.GetFollowing() failed", zap.Error(err))
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetFollowingFeed 获取关注的作者最近发的帖子
+func GetFollowingFeed(userID, page, size int64) (data []*models.ApiPostDetail, err error) {
+	if page < 1 || size < 1 {
+		return make([]*models.ApiPostDetail, 0), nil
+	}
+	followingIDs, err := redis.GetFollowingIDs(userID)
+	if err != nil {
+		zap.L().Error("redis.GetFollowingIDs() failed", zap.Error(err))
+		return nil, err
+	}
+	if len(followingIDs) == 0 {
+		return make([]*models.ApiPostDetail, 0), nil
+	}
+
+	// 关注的大V需要读取时拉取
+	fanoutThreshold, _ := feedConfig()
+	pullAuthorIDs, err := redis.FilterBigAuthors(followingIDs, fanoutThreshold)
+	if err != nil {
+		zap.L().Error("redis.FilterBigAuthors() failed", zap.Error(err))
+		return nil, err
+	}
+
+	ids, err := redis.GetFeedPostIDs(userID, pullAuthorIDs, page, size)
+	if err != nil {
+		zap.L().Error("redis.GetFeedPostIDs() failed", zap.Error(err))
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return make([]*models.ApiPostDetail, 0), nil
+	}
+
+	posts, err := mysql.GetPostListByIDs(ids)
+	if err != nil {
+		zap.L().Error("mysql.GetPostListByIDs() failed", zap.Error(err))
+		return nil, err
+	}
+	data, err = buildPostDetails(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 补充投票数
+	voteData, err := redis.GetPostVoteData(ids)
+	if err != nil {
+		zap.L().Error("redis.GetPostVoteData() failed", zap.Error(err))
+		return data, nil
+	}
+	voteMap := make(map[int64]int64, len(ids))
+	for idx, id := range ids {
+		pid, _ := strconv.ParseInt(id, 10, 64)
+		voteMap[pid] = voteData[idx]
+	}
+	for _, detail := range data {
+		detail.VoteNum = voteMap[detail.Post.ID]
+	}
+	return data, nil
+}
+
+// fanoutPost 记录作者发帖，并推送到粉丝的收件箱
+// 粉丝数超过阈值的作者只记录发帖列表，由粉丝读取时拉取
+func fanoutPost(p *models.Post, publishTime time.Time) {
+	if err := redis.AddUserPost(p.AuthorID, p.ID, publishTime); err != nil {
+		zap.L().Error("redis.AddUserPost() failed", zap.Int64("post_id", p.ID), zap.Error(err))
+		return
+	}
+
+	fanoutThreshold, inboxSize := feedConfig()
+	count, err := redis.GetFollowerCount(p.AuthorID)
+	if err != nil {
+		zap.L().Error("redis.GetFollowerCount() failed", zap.Int64("author_id", p.AuthorID), zap.Error(err))
+		return
+	}
+	if count == 0 || count > fanoutThreshold {
+		return
+	}
+
+	followerIDs, err := redis.GetFollowerIDs(p.AuthorID)
+	if err != nil {
+		zap.L().Error("redis.GetFollowerIDs() failed", zap.Int64("author_id", p.AuthorID), zap.Error(err))
+		return
+	}
+	if err := redis.PushToInboxes(followerIDs, p.ID, publishTime, inboxSize); err != nil {
+		zap.L().Error("redis.PushToInboxes() failed", zap.Int64("post_id", p.ID), zap.Error(err))
+		return
+	}
+	zap.L().Debug("post fanout completed",
+		zap.Int64("post_id", p.ID),
+		zap.Int("followers", len(followerIDs)))
+}
+
+// BackfillUserPosts 按 MySQL 中已发布的帖子补上作者的帖子列表，返回处理的帖子数，由 backfill-user-posts 子命令调用
+// 关注动态上线之前发的帖子不在 user:posts 中，关注这些作者时收件箱补不到帖子，大V的帖子也拉取不到
+// 帖子按发布时间写入，已经存在的会被覆盖成相同的值，可以重复执行
+func BackfillUserPosts(batchSize int) (int64, error) {
+	return forEachPublishedPost(batchSize, redis.AddUserPosts)
+}
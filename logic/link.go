@@ -0,0 +1,290 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/models"
+	"web-app/pkg/unfurl"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+var ErrorInvalidLink = errors.New("链接格式不正确，只支持 http/https 链接")
+
+const (
+	maxLinkLength      = 2048
+	linkCheckLimit     = 20
+	linkSweepBatch     = 100
+	defaultLinkWorkers = 4
+)
+
+// DuplicateLinkError 链接已经在同一个社区发布过
+type DuplicateLinkError struct {
+	PostID        int64
+	CommunityName string
+}
+
+func (e *DuplicateLinkError) Error() string {
+	return fmt.Sprintf("该链接已经在社区「%s」发布过", e.CommunityName)
+}
+
+type linkJob struct {
+	urlHash string
+	url     string
+}
+
+var (
+	linkFetcher *unfurl.Fetcher
+	linkQueue   chan linkJob
+	linkCancel  context.CancelFunc
+	linkWG      sync.WaitGroup
+)
+
+// linkConfig 返回补齐默认值之后的抓取配置
+func linkConfig() settings.LinkConfig {
+	cfg := settings.LinkConfig{}
+	if settings.Conf.LinkConfig != nil {
+		cfg = *settings.Conf.LinkConfig
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultLinkWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.RefreshHours <= 0 {
+		cfg.RefreshHours = 24
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	if cfg.SweepInterval <= 0 {
+		cfg.SweepInterval = 60
+	}
+	return cfg
+}
+
+func hashLink(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeLink 规范化帖子的链接并计算哈希，没有链接时清空
+func normalizeLink(p *models.Post) error {
+	if strings.TrimSpace(p.URL) == "" {
+		p.URL, p.URLHash = "", ""
+		return nil
+	}
+	url, err := unfurl.Normalize(p.URL)
+	if err != nil || len(url) > maxLinkLength {
+		return ErrorInvalidLink
+	}
+	p.URL, p.URLHash = url, hashLink(url)
+	return nil
+}
+
+// checkDuplicateLink 同一个链接在同一个社区只能发布一次，其他社区可以再发
+func checkDuplicateLink(p *models.Post) error {
+	if p.URLHash == "" {
+		return nil
+	}
+	posts, err := mysql.GetLinkPosts(p.URLHash, p.CommunityID, 1)
+	if err != nil {
+		zap.L().Error("mysql.GetLinkPosts() failed", zap.String("url", p.URL), zap.Error(err))
+		return err
+	}
+	if len(posts) > 0 && posts[0].PostID != p.ID {
+		return &DuplicateLinkError{PostID: posts[0].PostID, CommunityName: posts[0].CommunityName}
+	}
+	return nil
+}
+
+// CheckLink 发帖前检查链接在哪些社区发布过
+func CheckLink(rawURL string) (*models.ApiLinkCheck, error) {
+	p := &models.Post{URL: rawURL}
+	if err := normalizeLink(p); err != nil || p.URL == "" {
+		return nil, ErrorInvalidLink
+	}
+	posts, err := mysql.GetLinkPosts(p.URLHash, 0, linkCheckLimit)
+	if err != nil {
+		zap.L().Error("mysql.GetLinkPosts() failed", zap.String("url", p.URL), zap.Error(err))
+		return nil, err
+	}
+	data := &models.ApiLinkCheck{URL: p.URL, Posts: posts}
+	preview, err := mysql.GetLinkPreview(p.URLHash)
+	if err == nil && preview.Status == models.LinkStatusSuccess {
+		data.Link = preview
+	}
+	return data, nil
+}
+
+// requestLinkPreview 帖子保存后登记链接，没有抓取过或者预览已经过期时加入抓取队列
+func requestLinkPreview(urlHash, url string) {
+	created, err := mysql.InsertLinkPreview(urlHash, url)
+	if err != nil {
+		zap.L().Error("mysql.InsertLinkPreview() failed", zap.String("url", url), zap.Error(err))
+		return
+	}
+	if !created {
+		preview, err := mysql.GetLinkPreview(urlHash)
+		if err != nil {
+			zap.L().Error("mysql.GetLinkPreview() failed", zap.String("url", url), zap.Error(err))
+			return
+		}
+		cfg := linkConfig()
+		switch preview.Status {
+		case models.LinkStatusSuccess:
+			refresh := time.Duration(cfg.RefreshHours) * time.Hour
+			if preview.FetchTime != nil && time.Since(*preview.FetchTime) < refresh {
+				return
+			}
+		case models.LinkStatusFailed:
+			if preview.FailCount >= cfg.MaxFailures {
+				return
+			}
+		}
+	}
+	enqueueLink(linkJob{urlHash: urlHash, url: url})
+}
+
+// enqueueLink 加入抓取队列，队列满了或者抓取没有启动时由定时任务补抓
+func enqueueLink(job linkJob) {
+	if linkQueue == nil {
+		return
+	}
+	select {
+	case linkQueue <- job:
+	default:
+		zap.L().Warn("link fetch queue is full", zap.String("url", job.url))
+	}
+}
+
+// InitLinkFetcher 启动抓取链接预览的后台任务
+func InitLinkFetcher() {
+	cfg := linkConfig()
+	linkFetcher = unfurl.New(unfurl.Options{
+		Timeout:      time.Duration(cfg.Timeout) * time.Second,
+		MaxBytes:     cfg.MaxBytes,
+		AllowPrivate: cfg.AllowPrivate,
+	})
+	linkQueue = make(chan linkJob, cfg.QueueSize)
+
+	var ctx context.Context
+	ctx, linkCancel = context.WithCancel(context.Background())
+	for i := 0; i < cfg.Workers; i++ {
+		linkWG.Add(1)
+		go func() {
+			defer linkWG.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-linkQueue:
+					fetchLinkPreview(ctx, job)
+				}
+			}
+		}()
+	}
+
+	// 进程重启丢失的任务、队列满时丢弃的任务以及失败的任务，由定时任务从数据库捞出来重新抓取
+	linkWG.Add(1)
+	go func() {
+		defer linkWG.Done()
+		interval := time.Duration(cfg.SweepInterval) * time.Second
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				sweepLinkPreviews(interval)
+			}
+		}
+	}()
+}
+
+// StopLinkFetcher 停止抓取，正在进行的请求会被取消
+func StopLinkFetcher() {
+	if linkCancel != nil {
+		linkCancel()
+		linkWG.Wait()
+	}
+}
+
+func sweepLinkPreviews(interval time.Duration) {
+	list, err := mysql.GetLinkPreviewsToFetch(time.Now().Add(-interval), linkConfig().MaxFailures, linkSweepBatch)
+	if err != nil {
+		zap.L().Error("mysql.GetLinkPreviewsToFetch() failed", zap.Error(err))
+		return
+	}
+	for _, p := range list {
+		enqueueLink(linkJob{urlHash: p.URLHash, url: p.URL})
+	}
+}
+
+func fetchLinkPreview(ctx context.Context, job linkJob) {
+	meta, err := linkFetcher.Fetch(ctx, job.url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return // 停机时取消的请求不算失败
+		}
+		zap.L().Warn("fetch link preview failed", zap.String("url", job.url), zap.Error(err))
+		if err := mysql.MarkLinkPreviewFailed(job.urlHash); err != nil {
+			zap.L().Error("mysql.MarkLinkPreviewFailed() failed", zap.String("url", job.url), zap.Error(err))
+		}
+		return
+	}
+	err = mysql.UpdateLinkPreview(&models.LinkPreview{
+		URLHash:     job.urlHash,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Image:       meta.Image,
+		SiteName:    meta.SiteName,
+	})
+	if err != nil {
+		zap.L().Error("mysql.UpdateLinkPreview() failed", zap.String("url", job.url), zap.Error(err))
+	}
+}
+
+// postLinkHash 帖子链接的哈希，缓存中的帖子没有 url_hash 字段时根据链接重新计算
+func postLinkHash(p *models.Post) string {
+	if p.URLHash == "" && p.URL != "" {
+		return hashLink(p.URL)
+	}
+	return p.URLHash
+}
+
+// fillLinkPreviews 补充链接帖子的预览信息，只返回抓取成功的
+func fillLinkPreviews(data []*models.ApiPostDetail) {
+	hashes := make([]string, 0)
+	for _, d := range data {
+		if d != nil && d.Post != nil && d.Post.URL != "" {
+			hashes = append(hashes, postLinkHash(d.Post))
+		}
+	}
+	if len(hashes) == 0 {
+		return
+	}
+	previews, err := mysql.GetLinkPreviews(hashes)
+	if err != nil {
+		zap.L().Error("mysql.GetLinkPreviews() failed", zap.Error(err))
+		return
+	}
+	for _, d := range data {
+		if d == nil || d.Post == nil || d.Post.URL == "" {
+			continue
+		}
+		if p, ok := previews[postLinkHash(d.Post)]; ok && p.Status == models.LinkStatusSuccess {
+			d.Link = p
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/pkg/snowflake"
+
+	"go.uber.org/zap"
+)
+
+var ErrorNotificationNotExist = errors.New("通知不存在或已读")
+
+const maxMentionsPerPost = 20 // 单个帖子最多通知的 @ 人数，防止刷屏
+
+var (
+	// voteMilestones 帖子赞成票达到这些数量时通知作者
+	voteMilestones = []int64{10, 100, 1000, 10000}
+	mentionRe      = regexp.MustCompile(`@([\p{L}\p{N}_\-]{1,64})`)
+)
+
+// notify 按用户的通知偏好生成一条通知
+func notify(n *models.Notification) {
+	setting, err := mysql.GetNotificationSetting(n.UserID)
+	if err != nil {
+		zap.L().Error("mysql.GetNotificationSetting() failed", zap.Int64("user_id", n.UserID), zap.Error(err))
+		return
+	}
+	switch n.Type {
+	case models.NotifyTypeMention:
+		if !setting.Mention {
+			return
+		}
+	case models.NotifyTypeVote:
+		if !setting.Vote {
+			return
+		}
+	}
+
+	n.ID = snowflake.GenID()
+	if err := mysql.InsertNotification(n); err != nil {
+		zap.L().Error("mysql.InsertNotification() failed", zap.Int64("user_id", n.UserID), zap.Error(err))
+		return
+	}
+	if err := redis.IncrUnreadCount(n.UserID); err != nil {
+		zap.L().Error("redis.IncrUnreadCount() failed", zap.Int64("user_id", n.UserID), zap.Error(err))
+	}
+	publishEvent(TopicUser(n.UserID), models.StreamEventNotification, n)
+}
+
+// notifyMentions 通知帖子内容中 @ 到的用户
+func notifyMentions(p *models.Post) {
+	matches := mentionRe.FindAllStringSubmatch(p.Content, -1)
+	if len(matches) == 0 {
+		return
+	}
+	seen := make(map[string]bool)
+	usernames := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		usernames = append(usernames, m[1])
+		if len(usernames) >= maxMentionsPerPost {
+			break
+		}
+	}
+
+	users, err := mysql.GetUsersByUsernames(usernames)
+	if err != nil {
+		zap.L().Error("mysql.GetUsersByUsernames() failed", zap.Error(err))
+		return
+	}
+	for _, user := range users {
+		if user.UserID == p.AuthorID {
+			continue
+		}
+		notify(&models.Notification{
+			UserID:  user.UserID,
+			ActorID: p.AuthorID,
+			Type:    models.NotifyTypeMention,
+			PostID:  p.ID,
+			Content: truncateRunes(p.Title, 100),
+		})
+	}
+}
+
+// checkVoteMilestone 帖子的赞成票达到里程碑时通知作者，每个里程碑只通知一次
+func checkVoteMilestone(postID string) {
+	voteData, err := redis.GetPostVoteData([]string{postID})
+	if err != nil || len(voteData) == 0 {
+		zap.L().Error("redis.GetPostVoteData() failed", zap.String("post_id", postID), zap.Error(err))
+		return
+	}
+	upVotes := voteData[0]
+
+	for _, milestone := range voteMilestones {
+		if upVotes < milestone {
+			break
+		}
+		first, err := redis.MarkVoteMilestone(postID, milestone)
+		if err != nil {
+			zap.L().Error("redis.MarkVoteMilestone() failed", zap.String("post_id", postID), zap.Error(err))
+			return
+		}
+		if !first {
+			continue
+		}
+		pid, _ := strconv.ParseInt(postID, 10, 64)
+		post, err := mysql.GetPostByID(pid)
+		if err != nil {
+			zap.L().Error("mysql.GetPostByID() failed", zap.String("post_id", postID), zap.Error(err))
+			return
+		}
+		notify(&models.Notification{
+			UserID:  post.AuthorID,
+			Type:    models.NotifyTypeVote,
+			PostID:  pid,
+			Content: fmt.Sprintf("你的帖子《%s》获得了%d个赞", truncateRunes(post.Title, 50), milestone),
+		})
+	}
+}
+
+// GetNotifications 按游标分页获取通知
+func GetNotifications(userID int64, p *models.ParamsNotificationList) (data *models.ApiNotificationList, err error) {
+	data = new(models.ApiNotificationList)
+	data.List, err = mysql.GetNotifications(userID, p.Cursor, p.Size)
+	if err != nil {
+		zap.L().Error("mysql.GetNotifications() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if int64(len(data.List)) == p.Size {
+		data.NextCursor = strconv.FormatInt(data.List[len(data.List)-1].ID, 10)
+	}
+	data.Unread, err = GetUnreadCount(userID)
+	return data, err
+}
+
+// GetUnreadCount 获取未读通知数，Redis 中没有时从 MySQL 统计并回填
+func GetUnreadCount(userID int64) (int64, error) {
+	count, ok, err := redis.GetUnreadCount(userID)
+	if err != nil {
+		zap.L().Error("redis.GetUnreadCount() failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	if ok {
+		return count, nil
+	}
+	count, err = mysql.CountUnreadNotifications(userID)
+	if err != nil {
+		zap.L().Error("mysql.CountUnreadNotifications() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return 0, err
+	}
+	if err := redis.SetUnreadCount(userID, count); err != nil {
+		zap.L().Error("redis.SetUnreadCount() failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	return count, nil
+}
+
+// MarkNotificationRead 标记一条通知为已读
+func MarkNotificationRead(userID, notificationID int64) error {
+	updated, err := mysql.MarkNotificationRead(userID, notificationID)
+	if err != nil {
+		zap.L().Error("mysql.MarkNotificationRead() failed", zap.Int64("notification_id", notificationID), zap.Error(err))
+		return err
+	}
+	if !updated {
+		return ErrorNotificationNotExist
+	}
+	if err := redis.DecrUnreadCount(userID); err != nil {
+		zap.L().Error("redis.DecrUnreadCount() failed", zap.Int64("user_id", userID), zap.Error(err))
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead 标记全部通知为已读
+func MarkAllNotificationsRead(userID int64) error {
+	if err := mysql.MarkAllNotificationsRead(userID); err != nil {
+		zap.L().Error("mysql.MarkAllNotificationsRead() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return err
+	}
+	return redis.SetUnreadCount(userID, 0)
+}
+
+// GetNotificationSetting 获取通知偏好
+func GetNotificationSetting(userID int64) (*models.NotificationSetting, error) {
+	return mysql.GetNotificationSetting(userID)
+}
+
+// UpdateNotificationSetting 更新通知偏好
+func UpdateNotificationSetting(userID int64, setting *models.NotificationSetting) error {
+	return mysql.SaveNotificationSetting(userID, setting)
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
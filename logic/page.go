@@ -0,0 +1,138 @@
+package logic
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"web-app/dao/mysql"
+	"web-app/models"
+	"web-app/pkg/slug"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSitemapSize = 10000
+	maxSitemapSize     = 50000 // sitemaps.org 规定每个文件最多 50000 个地址
+	descriptionRunes   = 120
+)
+
+var ErrorSitemapNotExist = errors.New("站点地图不存在")
+
+// PostPath 帖子页面的规范路径 /p/:id/:slug，slug 由标题生成并做 URL 转义
+// 标题里没有可用字符时省略 slug
+func PostPath(postID int64, title string) string {
+	path := "/p/" + strconv.FormatInt(postID, 10)
+	if s := slug.Make(title); s != "" {
+		path += "/" + url.PathEscape(s)
+	}
+	return path
+}
+
+func sitemapSize() int64 {
+	cfg := settings.Conf.SiteConfig
+	if cfg == nil || cfg.SitemapSize <= 0 {
+		return defaultSitemapSize
+	}
+	if cfg.SitemapSize > maxSitemapSize {
+		return maxSitemapSize
+	}
+	return cfg.SitemapSize
+}
+
+// excerpt 取内容开头的一段作为摘要，连续的空白合并成一个空格
+func excerpt(content string, n int) string {
+	text := strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n]) + "…"
+}
+
+// GetPostPage 获取服务端渲染帖子页面需要的数据，只有已发布的帖子可以访问
+func GetPostPage(postID int64) (*models.PostPage, error) {
+	detail, err := GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	if detail.Post.Status != models.PostStatusNormal {
+		return nil, mysql.ErrorInvalidID
+	}
+	detail = FillViewerState(0, []*models.ApiPostDetail{detail})[0]
+
+	entry := newAtomEntry(siteBaseURL, detail)
+	path := PostPath(detail.Post.ID, detail.Post.Title)
+	description := excerpt(detail.Post.Content, descriptionRunes)
+	if description == "" && detail.CrosspostParent != nil {
+		// 转发的帖子使用原帖的内容
+		description = excerpt(detail.CrosspostParent.Post.Content, descriptionRunes)
+	}
+	if description == "" && detail.Link != nil {
+		// 链接帖子可以没有内容，使用网页的描述
+		description = excerpt(detail.Link.Description, descriptionRunes)
+	}
+	return &models.PostPage{
+		Detail:      detail,
+		SiteName:    siteTitle(),
+		Path:        path,
+		URL:         siteBaseURL + path,
+		Description: description,
+		FeedURL:     siteBaseURL + "/feeds/user/" + strconv.FormatInt(detail.Post.AuthorID, 10) + ".atom",
+		Published:   entry.Published,
+		Updated:     entry.Updated,
+	}, nil
+}
+
+// GetSitemapIndex 站点地图索引，每页包含 sitemap_size 篇帖子
+func GetSitemapIndex() (*models.SitemapIndex, error) {
+	count, err := mysql.CountPublishedPosts()
+	if err != nil {
+		zap.L().Error("mysql.CountPublishedPosts() failed", zap.Error(err))
+		return nil, err
+	}
+	size := sitemapSize()
+	pages := (count + size - 1) / size
+	if pages == 0 {
+		pages = 1 // 没有帖子时也返回一页空的站点地图
+	}
+	index := &models.SitemapIndex{Sitemaps: make([]models.SitemapEntry, 0, pages)}
+	for page := int64(1); page <= pages; page++ {
+		index.Sitemaps = append(index.Sitemaps, models.SitemapEntry{
+			Loc: siteBaseURL + "/sitemaps/posts/" + strconv.FormatInt(page, 10) + ".xml",
+		})
+	}
+	return index, nil
+}
+
+// GetSitemap 一页站点地图，返回内容和其中最近的更新时间
+func GetSitemap(page int64) (*models.URLSet, time.Time, error) {
+	var lastMod time.Time
+	if page < 1 {
+		return nil, lastMod, ErrorSitemapNotExist
+	}
+	posts, err := mysql.GetSitemapPosts(page, sitemapSize())
+	if err != nil {
+		zap.L().Error("mysql.GetSitemapPosts() failed", zap.Int64("page", page), zap.Error(err))
+		return nil, lastMod, err
+	}
+	if len(posts) == 0 && page > 1 {
+		return nil, lastMod, ErrorSitemapNotExist
+	}
+	set := &models.URLSet{URLs: make([]models.SitemapURL, 0, len(posts))}
+	for _, post := range posts {
+		updated := post.UpdateTime.UTC().Truncate(time.Second)
+		if updated.After(lastMod) {
+			lastMod = updated
+		}
+		set.URLs = append(set.URLs, models.SitemapURL{
+			Loc:     siteBaseURL + PostPath(post.ID, post.Title),
+			LastMod: updated,
+		})
+	}
+	return set, lastMod, nil
+}
@@ -0,0 +1,226 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrorInvalidPoll   = errors.New("投票选项不能为空或重复，截止时间必须晚于当前时间")
+	ErrorPostNotExist  = errors.New("帖子不存在")
+	ErrorPollNotExist  = errors.New("帖子没有投票")
+	ErrorPollClosed    = errors.New("投票已截止")
+	ErrorPollVoted     = errors.New("已经投过票了")
+	ErrorInvalidChoice = errors.New("无效的选项")
+)
+
+const (
+	pollCloseInterval = 30 * time.Second // 检查投票截止的间隔
+	pollCloseBatch    = 100
+)
+
+var (
+	pollCloserCancel context.CancelFunc
+	pollCloserWG     sync.WaitGroup
+)
+
+// validatePoll 校验发帖时附带的投票，选项会去掉首尾空白
+func validatePoll(p *models.ParamsPoll) error {
+	seen := make(map[string]bool, len(p.Options))
+	for i, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[option] {
+			return ErrorInvalidPoll
+		}
+		seen[option] = true
+		p.Options[i] = option
+	}
+	if p.CloseTime != nil && !p.CloseTime.After(time.Now()) {
+		return ErrorInvalidPoll
+	}
+	return nil
+}
+
+// newPoll 根据发帖参数生成帖子附带的投票，p 为空时返回 nil
+func newPoll(postID int64, p *models.ParamsPoll) *models.Poll {
+	if p == nil {
+		return nil
+	}
+	poll := &models.Poll{
+		PostID:    postID,
+		Multiple:  p.Multiple,
+		CloseTime: p.CloseTime,
+		Options:   make([]*models.PollOption, 0, len(p.Options)),
+	}
+	for i, option := range p.Options {
+		poll.Options = append(poll.Options, &models.PollOption{Index: i, Text: option})
+	}
+	return poll
+}
+
+// registerPollClose 有截止时间的投票登记到待截止列表，发帖成功后调用
+// 登记失败时投票仍然按截止时间停止，只是最终票数不会写入 MySQL，只记录日志
+func registerPollClose(poll *models.Poll) {
+	if poll == nil || poll.CloseTime == nil {
+		return
+	}
+	if err := redis.AddPollClose(poll.PostID, *poll.CloseTime); err != nil {
+		zap.L().Error("redis.AddPollClose() failed", zap.Int64("post_id", poll.PostID), zap.Error(err))
+	}
+}
+
+// getVisiblePoll 查询帖子的投票，草稿、定时发布和待审核的帖子对当前用户不可见时和帖子详情一样按帖子不存在处理
+func getVisiblePoll(postID, userID int64) (*models.Poll, error) {
+	post, err := mysql.GetPostByID(postID)
+	if errors.Is(err, mysql.ErrorInvalidID) {
+		return nil, ErrorPostNotExist
+	}
+	if err != nil {
+		zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if !CanViewPost(post, userID) {
+		return nil, ErrorPostNotExist
+	}
+	poll, err := mysql.GetPoll(postID)
+	if err != nil {
+		zap.L().Error("mysql.GetPoll() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if poll == nil {
+		return nil, ErrorPollNotExist
+	}
+	return poll, nil
+}
+
+// GetPoll 获取帖子的投票，userID 为 0 表示未登录
+// 投过票或者投票截止后才返回各选项的票数
+func GetPoll(postID, userID int64) (*models.Poll, error) {
+	poll, err := getVisiblePoll(postID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pid := strconv.FormatInt(postID, 10)
+	voted := false
+	if userID != 0 {
+		poll.MyChoices, voted, err = redis.GetPollChoices(pid, strconv.FormatInt(userID, 10))
+		if err != nil {
+			zap.L().Error("redis.GetPollChoices() failed", zap.Int64("post_id", postID), zap.Error(err))
+			return nil, err
+		}
+	}
+	poll.ResultsVisible = voted || poll.IsClosed(time.Now())
+	if !poll.ResultsVisible {
+		for _, option := range poll.Options {
+			option.Votes = nil
+		}
+		return poll, nil
+	}
+
+	counts, voters, err := redis.GetPollCounts(pid)
+	if err != nil {
+		zap.L().Error("redis.GetPollCounts() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	poll.TotalVoters = &voters
+	// 已截止的投票以 MySQL 中保存的最终结果为准
+	if !poll.Closed {
+		for _, option := range poll.Options {
+			votes := counts[option.Index]
+			option.Votes = &votes
+		}
+	}
+	return poll, nil
+}
+
+// VotePoll 参与投票，返回投票后的结果
+func VotePoll(userID, postID int64, p *models.ParamsPollVote) (*models.Poll, error) {
+	poll, err := getVisiblePoll(postID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.IsClosed(time.Now()) {
+		return nil, ErrorPollClosed
+	}
+	if !poll.Multiple && len(p.Options) != 1 {
+		return nil, ErrorInvalidChoice
+	}
+	seen := make(map[int]bool, len(p.Options))
+	for _, choice := range p.Options {
+		if choice < 0 || choice >= len(poll.Options) || seen[choice] {
+			return nil, ErrorInvalidChoice
+		}
+		seen[choice] = true
+	}
+
+	ok, err := redis.VotePoll(strconv.FormatInt(postID, 10), strconv.FormatInt(userID, 10), p.Options)
+	if err != nil {
+		zap.L().Error("redis.VotePoll() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorPollVoted
+	}
+	return GetPoll(postID, userID)
+}
+
+// InitPollCloser 定时把到截止时间的投票结果保存到 MySQL
+func InitPollCloser() {
+	var ctx context.Context
+	ctx, pollCloserCancel = context.WithCancel(context.Background())
+	pollCloserWG.Add(1)
+	go func() {
+		defer pollCloserWG.Done()
+		ticker := time.NewTicker(pollCloseInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				closeDuePolls()
+			}
+		}
+	}()
+}
+
+// StopPollCloser 停止定时任务并等待正在进行的保存结束，在 srv.Shutdown 之后调用
+func StopPollCloser() {
+	if pollCloserCancel != nil {
+		pollCloserCancel()
+		pollCloserWG.Wait()
+	}
+}
+
+// closeDuePolls 保存到截止时间的投票的最终结果，失败的重新登记等下次重试
+func closeDuePolls() {
+	now := time.Now()
+	postIDs, err := redis.ClaimDuePolls(now, pollCloseBatch)
+	if err != nil {
+		zap.L().Error("redis.ClaimDuePolls() failed", zap.Error(err))
+	}
+	for _, postID := range postIDs {
+		counts, _, err := redis.GetPollCounts(strconv.FormatInt(postID, 10))
+		if err == nil {
+			err = mysql.ClosePoll(postID, counts)
+		}
+		if err != nil {
+			zap.L().Error("close poll failed", zap.Int64("post_id", postID), zap.Error(err))
+			if err := redis.AddPollClose(postID, now); err != nil {
+				zap.L().Error("redis.AddPollClose() failed", zap.Int64("post_id", postID), zap.Error(err))
+			}
+			continue
+		}
+		zap.L().Info("poll closed", zap.Int64("post_id", postID))
+	}
+}
@@ -1,7 +1,9 @@
 package logic
 
 import (
-	"fmt"
+	"errors"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"web-app/dao/mysql"
@@ -12,17 +14,235 @@ import (
 	"go.uber.org/zap"
 )
 
-func CreatePost(p *models.Post) (err error) {
-	// 1.生成PostID
+var (
+	ErrorPermissionDenied   = errors.New("无权操作")
+	ErrorEmptyPost          = errors.New("标题和内容不能为空，链接帖子可以没有内容")
+	ErrorNotDraft           = errors.New("只有草稿可以发布")
+	ErrorInvalidPublishTime = errors.New("定时发布的时间必须晚于当前时间")
+)
+
+// CreatePost 发帖，params.Poll 是发帖时附带的投票，可以为空
+func CreatePost(params *models.ParamsCreatePost) (err error) {
+	p, pollParams := &params.Post, params.Poll
+	if pollParams != nil {
+		if err = validatePoll(pollParams); err != nil {
+			return err
+		}
+	}
+	if err = normalizeLink(p); err != nil {
+		return err
+	}
+	// 转发只能通过 Crosspost 创建
+	p.CrosspostOf = 0
+	if !params.Draft && isEmptyPost(p) {
+		return ErrorEmptyPost
+	}
+	now := time.Now()
+	if params.Draft || (p.PublishTime != nil && !p.PublishTime.After(now)) {
+		// 草稿不定时；发布时间已经过了的当作立即发布
+		p.PublishTime = nil
+	}
+
+	if params.Draft {
+		// 草稿只有作者自己可见，发布时再做内容过滤
+		p.Status = models.PostStatusDraft
+	} else {
+		if err = checkCommunityRules(p); err != nil {
+			return err
+		}
+		if err = checkDuplicateLink(p); err != nil {
+			return err
+		}
+		// 1. 内容过滤及反垃圾检查
+		p.Status, err = filterPost(p)
+		if err != nil {
+			return err
+		}
+		if err = checkSpam(p); err != nil {
+			return err
+		}
+		if p.Status == models.PostStatusNormal && p.PublishTime != nil {
+			p.Status = models.PostStatusScheduled
+		}
+	}
+	// 2.生成PostID
 	p.ID = snowflake.GenID()
-	// 2. 保存到数据库
-	err = mysql.CreatePost(p)
+	// 3. 帖子和附带的投票在同一个事务中保存到数据库
+	poll := newPoll(p.ID, pollParams)
+	err = mysql.CreatePost(p, poll)
 	if err != nil {
 		zap.L().Error("mysql.CreatePost() failed", zap.Error(err))
 		return err
 	}
-	err = redis.CreatePost(p.ID, p.CommunityID)
-	return
+	bloomAdd(BloomPost, p.ID)
+	registerPollClose(poll)
+	if p.URL != "" {
+		go requestLinkPreview(p.URLHash, p.URL)
+	}
+	switch p.Status {
+	case models.PostStatusPending:
+		// 待审核的帖子先不进入排行榜，审核通过后再加入
+		return redis.AddPostToReview(p.ID)
+	case models.PostStatusNormal:
+		return publishPost(p, now)
+	}
+	// 草稿和定时发布的帖子由作者发布或者定时任务发布时再加入排行榜
+	return nil
+}
+
+// publishPost 帖子正式发布：按发布时间加入排行榜，推送到粉丝的关注动态，通知被 @ 的用户
+func publishPost(p *models.Post, publishTime time.Time) error {
+	if err := redis.CreatePostAt(p.ID, p.CommunityID, publishTime); err != nil {
+		return err
+	}
+	invalidatePostLists(p.CommunityID)
+	go fanoutPost(p, publishTime)
+	go notifyMentions(p)
+	go publishPostEvent(p)
+	return nil
+}
+
+// UpdatePost 作者编辑帖子，编辑后的内容同样需要经过内容过滤
+// 草稿的编辑就是自动保存，不做内容过滤，标题和内容也可以为空
+func UpdatePost(userID, postID int64, p *models.ParamsUpdatePost) (post *models.Post, err error) {
+	post, err = mysql.GetPostByID(postID)
+	if err != nil {
+		zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if post.AuthorID != userID {
+		return nil, ErrorPermissionDenied
+	}
+
+	post.Title = p.Title
+	if post.CrosspostOf == 0 {
+		// 转发的帖子展示原帖的内容，只能修改标题
+		post.Content = p.Content
+	}
+	if post.Status == models.PostStatusDraft {
+		if err = mysql.UpdatePost(post); err != nil {
+			zap.L().Error("mysql.UpdatePost() failed", zap.Int64("post_id", postID), zap.Error(err))
+			return nil, err
+		}
+		if err := redis.DeletePostCache(postID); err != nil {
+			zap.L().Error("redis.DeletePostCache() failed", zap.Int64("post_id", postID), zap.Error(err))
+		}
+		return post, nil
+	}
+
+	if isEmptyPost(post) {
+		return nil, ErrorEmptyPost
+	}
+	if post.Status == models.PostStatusScheduled && p.PublishAt != nil {
+		if !p.PublishAt.After(time.Now()) {
+			return nil, ErrorInvalidPublishTime
+		}
+		post.PublishTime = p.PublishAt
+	}
+	status, err := filterPost(post)
+	if err != nil {
+		return nil, err
+	}
+	// 已经在审核中的帖子编辑后仍然保持待审核，定时发布的帖子没有命中过滤规则时仍然定时发布
+	// 审核未通过的帖子编辑后重新进入审核
+	if post.Status == models.PostStatusRejected {
+		status = models.PostStatusPending
+	}
+	if post.Status != models.PostStatusPending &&
+		!(post.Status == models.PostStatusScheduled && status == models.PostStatusNormal) {
+		post.Status = status
+	}
+
+	if err = mysql.UpdatePost(post); err != nil {
+		zap.L().Error("mysql.UpdatePost() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if err := redis.DeletePostCache(postID); err != nil {
+		zap.L().Error("redis.DeletePostCache() failed", zap.Int64("post_id", postID), zap.Error(err))
+	}
+	// 已发布的帖子编辑后标题和内容变了，列表中的也要更新
+	invalidatePostLists(post.CommunityID)
+	if status == models.PostStatusPending {
+		err = redis.AddPostToReview(postID)
+	}
+	return post, err
+}
+
+// PublishDraft 发布草稿，publishAt 晚于当前时间时改为定时发布
+func PublishDraft(userID, postID int64, p *models.ParamsPublishPost) (post *models.Post, err error) {
+	post, err = mysql.GetPostByID(postID)
+	if err != nil {
+		zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if post.AuthorID != userID {
+		return nil, ErrorPermissionDenied
+	}
+	if post.Status != models.PostStatusDraft {
+		return nil, ErrorNotDraft
+	}
+	if isEmptyPost(post) {
+		return nil, ErrorEmptyPost
+	}
+	if err = checkCommunityRules(post); err != nil {
+		return nil, err
+	}
+	if err = checkDuplicateLink(post); err != nil {
+		return nil, err
+	}
+
+	if post.Status, err = filterPost(post); err != nil {
+		return nil, err
+	}
+	if err = checkSpam(post); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	post.PublishTime = nil
+	if post.Status == models.PostStatusNormal && p.PublishAt != nil && p.PublishAt.After(now) {
+		post.Status = models.PostStatusScheduled
+		post.PublishTime = p.PublishAt
+	}
+	if err = mysql.UpdatePost(post); err != nil {
+		zap.L().Error("mysql.UpdatePost() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	if err := redis.DeletePostCache(postID); err != nil {
+		zap.L().Error("redis.DeletePostCache() failed", zap.Int64("post_id", postID), zap.Error(err))
+	}
+
+	switch post.Status {
+	case models.PostStatusPending:
+		err = redis.AddPostToReview(postID)
+	case models.PostStatusNormal:
+		err = publishPost(post, now)
+	}
+	return post, err
+}
+
+// isEmptyPost 标题不能为空，内容只有链接帖子和转发的帖子可以为空
+func isEmptyPost(p *models.Post) bool {
+	return strings.TrimSpace(p.Title) == "" || (strings.TrimSpace(p.Content) == "" && p.URL == "" && p.CrosspostOf == 0)
+}
+
+// GetMyDrafts 分页获取作者的草稿和定时发布的帖子
+func GetMyDrafts(userID, page, size int64) ([]*models.Post, error) {
+	return mysql.GetUserDrafts(userID, page, size)
+}
+
+// CanViewPost 草稿和定时发布的帖子只有作者自己可见，待审核和审核未通过的帖子只有作者和管理员可见
+// viewerID 为 0 表示未登录
+func CanViewPost(post *models.Post, viewerID int64) bool {
+	if post == nil {
+		return false
+	}
+	switch post.Status {
+	case models.PostStatusDraft, models.PostStatusScheduled:
+		return post.AuthorID == viewerID
+	case models.PostStatusPending, models.PostStatusRejected:
+		return post.AuthorID == viewerID || IsAdmin(viewerID)
+	}
+	return true
 }
 
 // GetPostByID 根据帖子id获取帖子详情
@@ -120,9 +340,15 @@ func GetPostList2(p *models.ParamsPostList) (data []*models.ApiPostDetail, err e
 	if err != nil {
 		return
 	}
+	// 待审核的帖子会被MySQL过滤掉，posts 和 ids 不一定一一对应，按id取投票数
+	voteMap := make(map[int64]int64, len(ids))
+	for idx, id := range ids {
+		pid, _ := strconv.ParseInt(id, 10, 64)
+		voteMap[pid] = voteData[idx]
+	}
 
 	// 将帖子的作者及分区信息查询出来填充到帖子中
-	for idx, post := range posts {
+	for _, post := range posts {
 		// 根据作者ID查询作者信息
 		user, err := mysql.GetUserByID(post.AuthorID)
 		if err != nil {
@@ -142,7 +368,7 @@ func GetPostList2(p *models.ParamsPostList) (data []*models.ApiPostDetail, err e
 		}
 		postdetail := &models.ApiPostDetail{
 			AuthorName:      user.Username,
-			VoteNum:         voteData[idx], // 按顺序一一对应
+			VoteNum:         voteMap[post.ID],
 			Post:            post,
 			CommunityDetail: communityDetail,
 		}
@@ -153,6 +379,10 @@ func GetPostList2(p *models.ParamsPostList) (data []*models.ApiPostDetail, err e
 }
 
 func GetCommunityPostList(p *models.ParamsCommunityPostList) (data []*models.ApiPostDetail, err error) {
+	// 布隆过滤器判定一定不存在的社区没有帖子，和不存在的社区查 Redis 的结果一样返回空列表
+	if !bloomMayContain(BloomCommunity, p.CommunityID) {
+		return
+	}
 
 	// 去redis查询Id列表
 	ids, err := redis.GetCommunityPostIDsInOrder(p)
@@ -175,9 +405,15 @@ func GetCommunityPostList(p *models.ParamsCommunityPostList) (data []*models.Api
 	if err != nil {
 		return
 	}
+	// 待审核的帖子会被MySQL过滤掉，posts 和 ids 不一定一一对应，按id取投票数
+	voteMap := make(map[int64]int64, len(ids))
+	for idx, id := range ids {
+		pid, _ := strconv.ParseInt(id, 10, 64)
+		voteMap[pid] = voteData[idx]
+	}
 
 	// 将帖子的作者及分区信息查询出来填充到帖子中
-	for idx, post := range posts {
+	for _, post := range posts {
 		// 根据作者ID查询作者信息
 		user, err := mysql.GetUserByID(post.AuthorID)
 		if err != nil {
@@ -197,7 +433,7 @@ func GetCommunityPostList(p *models.ParamsCommunityPostList) (data []*models.Api
 		}
 		postdetail := &models.ApiPostDetail{
 			AuthorName:      user.Username,
-			VoteNum:         voteData[idx], // 按顺序一一对应
+			VoteNum:         voteMap[post.ID],
 			Post:            post,
 			CommunityDetail: communityDetail,
 		}
@@ -210,6 +446,10 @@ func GetCommunityPostList(p *models.ParamsCommunityPostList) (data []*models.Api
 // GetPostListNew 将两个查询帖子列表逻辑合二为一的接口
 // GetPostListNew 将两个查询帖子列表逻辑合二为一的接口
 func GetPostListNew(p *models.ParamsPostList) (data []*models.ApiPostDetail, err error) {
+	// Redis 熔断时排行榜不可用，直接从 MySQL 查询
+	if !redis.Available() {
+		return getPostListFromMySQL(p)
+	}
 	// 根据请求参数的不同 执行不同的逻辑
 	if p.CommunityID == 0 {
 		// 查所有
@@ -220,6 +460,9 @@ func GetPostListNew(p *models.ParamsPostList) (data []*models.ApiPostDetail, err
 	}
 
 	if err != nil {
+		if redis.IsUnavailable(err) {
+			return getPostListFromMySQL(p)
+		}
 		zap.L().Error("logic.GetPostListNew() failed", zap.Error(err))
 		return nil, err
 	}
@@ -227,6 +470,55 @@ func GetPostListNew(p *models.ParamsPostList) (data []*models.ApiPostDetail, err
 
 }
 
+// getPostListFromMySQL Redis 不可用时直接从 MySQL 查询帖子列表
+// MySQL 中没有实时的帖子分数，按分数排序时退化为按发帖时间排序
+func getPostListFromMySQL(p *models.ParamsPostList) (data []*models.ApiPostDetail, err error) {
+	posts, err := mysql.GetPostListOrdered(p.CommunityID, p.Order, p.Page, p.Size)
+	if err != nil {
+		zap.L().Error("mysql.GetPostListOrdered() failed", zap.Int64("community_id", p.CommunityID), zap.Error(err))
+		return nil, err
+	}
+	zap.L().Debug("redis unavailable, post list served from mysql", zap.Int64("community_id", p.CommunityID), zap.String("order", p.Order))
+	return buildPostDetailsWithCache(posts)
+}
+
+// GetUserPostList 按发帖时间倒序获取作者发的帖子
+func GetUserPostList(userID, page, size int64) (data []*models.ApiPostDetail, err error) {
+	ids, err := redis.GetUserPostIDs(userID, page, size)
+	if err != nil {
+		zap.L().Error("redis.GetUserPostIDs() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return make([]*models.ApiPostDetail, 0), nil
+	}
+	posts, err := mysql.GetPostListByIDs(ids)
+	if err != nil {
+		zap.L().Error("mysql.GetPostListByIDs() failed", zap.Error(err))
+		return nil, err
+	}
+	data, err = buildPostDetails(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 补充投票数
+	voteData, err := redis.GetPostVoteData(ids)
+	if err != nil {
+		zap.L().Error("redis.GetPostVoteData() failed", zap.Error(err))
+		return data, nil
+	}
+	voteMap := make(map[int64]int64, len(ids))
+	for idx, id := range ids {
+		pid, _ := strconv.ParseInt(id, 10, 64)
+		voteMap[pid] = voteData[idx]
+	}
+	for _, detail := range data {
+		detail.VoteNum = voteMap[detail.Post.ID]
+	}
+	return data, nil
+}
+
 // GetPostByIDConcurrent 并发版本的帖子详情获取
 // 性能优化：将用户信息和社区信息查询改为并发执行，减少总响应时间
 func GetPostByIDConcurrent(postID int64) (data *models.ApiPostDetail, err error) {
@@ -302,11 +594,18 @@ func GetPostListOptimized(page, size int64) (data []*models.ApiPostDetail, err e
 		return nil, err
 	}
 
+	// 2. 批量查询作者和社区并组装（第2、3次查询）
+	return buildPostDetails(posts)
+}
+
+// buildPostDetails 批量查询帖子的作者及社区信息并组装成 ApiPostDetail
+// 返回结果保持 posts 的顺序，作者或社区不存在的帖子会被跳过
+func buildPostDetails(posts []*models.Post) (data []*models.ApiPostDetail, err error) {
 	if len(posts) == 0 {
 		return make([]*models.ApiPostDetail, 0), nil
 	}
 
-	// 2. 提取所有需要查询的用户ID和社区ID
+	// 1. 提取所有需要查询的用户ID和社区ID
 	userIDs := make([]int64, 0, len(posts))
 	communityIDs := make([]int64, 0, len(posts))
 
@@ -315,21 +614,21 @@ func GetPostListOptimized(page, size int64) (data []*models.ApiPostDetail, err e
 		communityIDs = append(communityIDs, post.CommunityID)
 	}
 
-	// 3. 批量查询用户信息（第2次查询）
+	// 2. 批量查询用户信息
 	userMap, err := mysql.BatchGetUsersByIDs(userIDs)
 	if err != nil {
 		zap.L().Error("mysql.BatchGetUsersByIDs() failed", zap.Error(err))
 		return nil, err
 	}
 
-	// 4. 批量查询社区信息（第3次查询）
+	// 3. 批量查询社区信息
 	communityMap, err := mysql.BatchGetCommunitiesByIDs(communityIDs)
 	if err != nil {
 		zap.L().Error("mysql.BatchGetCommunitiesByIDs() failed", zap.Error(err))
 		return nil, err
 	}
 
-	// 5. 组装数据
+	// 4. 组装数据
 	data = make([]*models.ApiPostDetail, 0, len(posts))
 	for _, post := range posts {
 		// 从map中获取用户信息
@@ -359,7 +658,7 @@ func GetPostListOptimized(page, size int64) (data []*models.ApiPostDetail, err e
 	}
 
 	// 记录性能优化信息
-	zap.L().Info("GetPostListOptimized completed",
+	zap.L().Info("buildPostDetails completed",
 		zap.Int("posts_count", len(posts)),
 		zap.Int("users_queried", len(userMap)),
 		zap.Int("communities_queried", len(communityMap)),
@@ -369,88 +668,24 @@ func GetPostListOptimized(page, size int64) (data []*models.ApiPostDetail, err e
 }
 
 // GetPostByIDWithCache 根据帖子id获取帖子详情（带缓存）
+// 布隆过滤器判定一定不存在的帖子直接返回，不查缓存和数据库
+// 并发回源的合并、分布式锁、不存在的帖子的空值缓存都由 postDetailCache 处理
 func GetPostByIDWithCache(postID int64) (data *models.ApiPostDetail, err error) {
 	start := time.Now()
-
-	// 第一步：尝试从缓存获取完整的帖子详情
-	cachedPost, err := redis.GetPostDetailFromCache(postID)
-	if err != nil {
-		zap.L().Error("redis.GetPostDetailFromCache() failed", zap.Error(err), zap.Int64("post_id", postID))
-		// 缓存错误不影响业务，继续从数据库查询
-	}
-
-	if cachedPost != nil {
-		// 缓存命中，直接返回
-		zap.L().Info("GetPostByIDWithCache cache hit",
-			zap.Int64("post_id", postID),
-			zap.Duration("cost", time.Since(start)))
-		return cachedPost, nil
+	if !bloomMayContain(BloomPost, postID) {
+		return nil, mysql.ErrorInvalidID
 	}
-
-	// 第二步：缓存未命中，尝试获取分布式锁防止缓存击穿
-	lockKey := fmt.Sprintf("post:%d", postID)
-	locked, err := redis.TryLock(lockKey)
+	data, err = postDetailCache.Get(postID)
 	if err != nil {
-		zap.L().Error("redis.TryLock() failed", zap.Error(err), zap.String("lock_key", lockKey))
-	}
-
-	if locked {
-		// 获取到锁，负责查询数据库并更新缓存
-		defer func() {
-			if unlockErr := redis.ReleaseLock(lockKey); unlockErr != nil {
-				zap.L().Error("redis.ReleaseLock() failed", zap.Error(unlockErr), zap.String("lock_key", lockKey))
-			}
-		}()
-
-		// 双重检查：再次尝试从缓存获取
-		cachedPost, err = redis.GetPostDetailFromCache(postID)
-		if err == nil && cachedPost != nil {
-			zap.L().Info("GetPostByIDWithCache double check cache hit",
-				zap.Int64("post_id", postID),
-				zap.Duration("cost", time.Since(start)))
-			return cachedPost, nil
+		if errors.Is(err, mysql.ErrorInvalidID) {
+			bloomFalsePositive(BloomPost)
 		}
-
-		// 从数据库查询
-		data, err = queryPostDetailFromDB(postID)
-		if err != nil {
-			return nil, err
-		}
-
-		// 异步更新缓存
-		go func() {
-			if cacheErr := redis.SetPostDetailToCache(postID, data); cacheErr != nil {
-				zap.L().Error("redis.SetPostDetailToCache() failed",
-					zap.Error(cacheErr), zap.Int64("post_id", postID))
-			}
-		}()
-
-		zap.L().Info("GetPostByIDWithCache DB query with cache update",
-			zap.Int64("post_id", postID),
-			zap.Duration("cost", time.Since(start)))
-		return data, nil
-	} else {
-		// 未获取到锁，等待一段时间后重试从缓存获取
-		time.Sleep(50 * time.Millisecond)
-		cachedPost, err = redis.GetPostDetailFromCache(postID)
-		if err == nil && cachedPost != nil {
-			zap.L().Info("GetPostByIDWithCache retry cache hit",
-				zap.Int64("post_id", postID),
-				zap.Duration("cost", time.Since(start)))
-			return cachedPost, nil
-		}
-
-		// 缓存仍未命中，直接查询数据库（降级策略）
-		data, err = queryPostDetailFromDB(postID)
-		if err != nil {
-			return nil, err
-		}
-
-		zap.L().Info("GetPostByIDWithCache fallback to DB",
-			zap.Int64("post_id", postID),
-			zap.Duration("cost", time.Since(start)))
-		return data, nil
+		return nil, err
 	}
+	zap.L().Debug("GetPostByIDWithCache completed",
+		zap.Int64("post_id", postID),
+		zap.Duration("cost", time.Since(start)))
+	return data, nil
 }
 
 // queryPostDetailFromDB 从数据库查询帖子详情（内部函数）
@@ -501,6 +736,21 @@ func GetPostListOptimizedWithCache(page, size int64) (data []*models.ApiPostDeta
 		return nil, err
 	}
 
+	data, err = buildPostDetailsWithCache(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	zap.L().Info("GetPostListOptimizedWithCache completed",
+		zap.Int("posts_count", len(posts)),
+		zap.String("optimization", "N+1_with_cache"),
+		zap.Duration("total_cost", time.Since(start)))
+
+	return data, nil
+}
+
+// buildPostDetailsWithCache 与 buildPostDetails 相同，但作者和社区信息优先从缓存批量获取
+func buildPostDetailsWithCache(posts []*models.Post) (data []*models.ApiPostDetail, err error) {
 	if len(posts) == 0 {
 		return []*models.ApiPostDetail{}, nil
 	}
@@ -522,77 +772,21 @@ func GetPostListOptimizedWithCache(page, size int64) (data []*models.ApiPostDeta
 		}
 	}
 
-	// 第三步：批量从缓存获取用户信息
-	cachedUsers, missedUserIDs, err := redis.BatchGetUsersFromCache(userIDs)
+	// 第三步：批量获取用户信息，缓存未命中的部分一次批量查询数据库并写回缓存
+	userMap, err := userCache.GetMany(userIDs)
 	if err != nil {
-		zap.L().Error("redis.BatchGetUsersFromCache() failed", zap.Error(err))
-		// 缓存错误，降级到数据库查询
-		missedUserIDs = userIDs
-		cachedUsers = make(map[int64]*models.User)
-	}
-
-	// 第四步：从数据库查询缓存未命中的用户
-	var dbUsers map[int64]*models.User
-	if len(missedUserIDs) > 0 {
-		dbUsers, err = mysql.BatchGetUsersByIDs(missedUserIDs)
-		if err != nil {
-			zap.L().Error("mysql.BatchGetUsersByIDs() failed", zap.Error(err))
-			return nil, err
-		}
-
-		// 异步更新用户缓存
-		go func() {
-			if cacheErr := redis.BatchSetUsersToCache(dbUsers); cacheErr != nil {
-				zap.L().Error("redis.BatchSetUsersToCache() failed", zap.Error(cacheErr))
-			}
-		}()
-	}
-
-	// 合并用户数据
-	userMap := make(map[int64]*models.User)
-	for id, user := range cachedUsers {
-		userMap[id] = user
-	}
-	for id, user := range dbUsers {
-		userMap[id] = user
+		zap.L().Error("userCache.GetMany() failed", zap.Error(err))
+		return nil, err
 	}
 
-	// 第五步：批量从缓存获取社区信息
-	cachedCommunities, missedCommunityIDs, err := redis.BatchGetCommunitiesFromCache(communityIDs)
+	// 第四步：批量获取社区信息
+	communityMap, err := communityCache.GetMany(communityIDs)
 	if err != nil {
-		zap.L().Error("redis.BatchGetCommunitiesFromCache() failed", zap.Error(err))
-		// 缓存错误，降级到数据库查询
-		missedCommunityIDs = communityIDs
-		cachedCommunities = make(map[int64]*models.CommunityDetail)
-	}
-
-	// 第六步：从数据库查询缓存未命中的社区
-	var dbCommunities map[int64]*models.CommunityDetail
-	if len(missedCommunityIDs) > 0 {
-		dbCommunities, err = mysql.BatchGetCommunitiesByIDs(missedCommunityIDs)
-		if err != nil {
-			zap.L().Error("mysql.BatchGetCommunitiesByIDs() failed", zap.Error(err))
-			return nil, err
-		}
-
-		// 异步更新社区缓存
-		go func() {
-			if cacheErr := redis.BatchSetCommunitiesToCache(dbCommunities); cacheErr != nil {
-				zap.L().Error("redis.BatchSetCommunitiesToCache() failed", zap.Error(cacheErr))
-			}
-		}()
-	}
-
-	// 合并社区数据
-	communityMap := make(map[int64]*models.CommunityDetail)
-	for id, community := range cachedCommunities {
-		communityMap[id] = community
-	}
-	for id, community := range dbCommunities {
-		communityMap[id] = community
+		zap.L().Error("communityCache.GetMany() failed", zap.Error(err))
+		return nil, err
 	}
 
-	// 第七步：组装数据
+	// 第五步：组装数据
 	data = make([]*models.ApiPostDetail, 0, len(posts))
 	for _, post := range posts {
 		user, userExists := userMap[post.AuthorID]
@@ -616,14 +810,10 @@ func GetPostListOptimizedWithCache(page, size int64) (data []*models.ApiPostDeta
 	}
 
 	// 记录性能优化信息
-	zap.L().Info("GetPostListOptimizedWithCache completed",
+	zap.L().Debug("buildPostDetailsWithCache completed",
 		zap.Int("posts_count", len(posts)),
-		zap.Int("users_cached", len(cachedUsers)),
-		zap.Int("users_from_db", len(dbUsers)),
-		zap.Int("communities_cached", len(cachedCommunities)),
-		zap.Int("communities_from_db", len(dbCommunities)),
-		zap.String("optimization", "N+1_with_cache"),
-		zap.Duration("total_cost", time.Since(start)))
+		zap.Int("users", len(userMap)),
+		zap.Int("communities", len(communityMap)))
 
 	return data, nil
 }
@@ -0,0 +1,167 @@
+package logic
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/pkg/cache"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+// PostListPage 缓存的一页帖子列表，AsOf 是这一页生成的时间
+type PostListPage struct {
+	Gen   int64                   `json:"gen"`
+	AsOf  time.Time               `json:"as_of"`
+	Posts []*models.ApiPostDetail `json:"posts"`
+	Stale bool                    `json:"-"` // 列表已经有变化，返回的是旧版本，正在后台重新生成
+}
+
+// postListKey 一页帖子列表，Gen 是生成时的版本号，版本号变化后旧的缓存不再被读取
+type postListKey struct {
+	CommunityID int64
+	Gen         int64
+	Order       string
+	Page        int64
+	Size        int64
+}
+
+// 帖子列表的分页缓存，key 中带版本号，发帖、投票、编辑后版本号加一，不需要逐个删除分页
+var postListCache = cache.New(cache.Options[postListKey, *PostListPage]{
+	Name:  "postlist",
+	Store: redis.CacheStore(),
+	Key: func(k postListKey) string {
+		return redis.PostListCacheKey(k.CommunityID, k.Gen, k.Order, k.Page, k.Size)
+	},
+	Load:   loadPostListPage,
+	TTL:    redis.PostListCacheExpire,
+	Jitter: redis.CacheExpireJitter,
+	// 版本号变化后热门分页会被多个实例同时重新生成，用分布式锁合并
+	Lock:     true,
+	LockTTL:  redis.CacheLockExpire,
+	LockWait: redis.CacheLockRetry,
+	Recorder: redis.PostListCacheStats,
+})
+
+var (
+	postListSWR      atomic.Bool
+	postListStaleTTL atomic.Int64
+	// postListRefreshing 正在后台重新生成的分页，避免同一页重复生成
+	postListRefreshing sync.Map
+)
+
+const defaultPostListStaleTTL = time.Hour
+
+// initPostListCache 帖子列表缓存的 stale-while-revalidate 配置
+func initPostListCache(cfg *settings.CacheConfig) {
+	postListSWR.Store(cfg.PostListStaleWhileRevalidate)
+	ttl := time.Duration(cfg.PostListStaleTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultPostListStaleTTL
+	}
+	postListStaleTTL.Store(int64(ttl))
+}
+
+// GetPostListPage 带缓存的帖子列表
+// 开启 stale-while-revalidate 时，列表有变化后先返回上一次生成的分页，同时在后台重新生成
+func GetPostListPage(p *models.ParamsPostList) (*PostListPage, error) {
+	// Redis 熔断时版本号和缓存都读不到，直接从 MySQL 生成
+	if !redis.Available() {
+		return loadPostListPage(postListKey{CommunityID: p.CommunityID, Order: p.Order, Page: p.Page, Size: p.Size})
+	}
+	gen, err := redis.GetPostListGen(p.CommunityID)
+	if err != nil {
+		// 读不到版本号时不使用缓存
+		zap.L().Error("redis.GetPostListGen() failed", zap.Int64("community_id", p.CommunityID), zap.Error(err))
+		return loadPostListPage(postListKey{CommunityID: p.CommunityID, Order: p.Order, Page: p.Page, Size: p.Size})
+	}
+	k := postListKey{CommunityID: p.CommunityID, Gen: gen, Order: p.Order, Page: p.Page, Size: p.Size}
+	if !postListSWR.Load() {
+		return postListCache.Get(k)
+	}
+
+	if page := getStalePostListPage(k); page != nil {
+		if page.Gen >= gen {
+			return page, nil
+		}
+		refreshPostListPage(k)
+		stale := *page
+		stale.Stale = true
+		return &stale, nil
+	}
+	page, err := postListCache.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	setStalePostListPage(k, page)
+	return page, nil
+}
+
+// loadPostListPage 从 Redis 的排行榜和 MySQL 生成一页帖子列表
+func loadPostListPage(k postListKey) (*PostListPage, error) {
+	asOf := time.Now()
+	posts, err := GetPostListNew(&models.ParamsPostList{CommunityID: k.CommunityID, Order: k.Order, Page: k.Page, Size: k.Size})
+	if err != nil {
+		return nil, err
+	}
+	return &PostListPage{Gen: k.Gen, AsOf: asOf, Posts: posts}, nil
+}
+
+// getStalePostListPage 最近一次生成的分页，可能是旧版本
+func getStalePostListPage(k postListKey) *PostListPage {
+	value, err := redis.GetPostListStale(k.CommunityID, k.Order, k.Page, k.Size)
+	if err != nil {
+		zap.L().Error("redis.GetPostListStale() failed", zap.Error(err))
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+	page := new(PostListPage)
+	if err := json.Unmarshal(value, page); err != nil {
+		zap.L().Error("json.Unmarshal(PostListPage) failed", zap.Error(err))
+		return nil
+	}
+	return page
+}
+
+// setStalePostListPage 保存最近一次生成的分页，新版本的分页可能是其他实例生成的，所以每次读到新版本后都要保存
+func setStalePostListPage(k postListKey, page *PostListPage) {
+	value, err := json.Marshal(page)
+	if err != nil {
+		zap.L().Error("json.Marshal(PostListPage) failed", zap.Error(err))
+		return
+	}
+	ttl := time.Duration(postListStaleTTL.Load())
+	if err := redis.SetPostListStale(k.CommunityID, k.Order, k.Page, k.Size, value, ttl); err != nil {
+		zap.L().Error("redis.SetPostListStale() failed", zap.Error(err))
+	}
+}
+
+// refreshPostListPage 在后台生成新版本的分页
+func refreshPostListPage(k postListKey) {
+	if _, loaded := postListRefreshing.LoadOrStore(k, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer postListRefreshing.Delete(k)
+		page, err := postListCache.Get(k)
+		if err != nil {
+			zap.L().Error("refresh post list page failed", zap.Any("page", k), zap.Error(err))
+			return
+		}
+		setStalePostListPage(k, page)
+	}()
+}
+
+// invalidatePostLists 社区的帖子有变化（发帖、投票、编辑），使该社区和全部帖子的列表缓存失效
+// 失败只记录日志，旧的分页最多在 PostListCacheExpire 后过期
+func invalidatePostLists(communityID int64) {
+	if err := redis.IncrPostListGen(communityID); err != nil {
+		zap.L().Error("redis.IncrPostListGen() failed", zap.Int64("community_id", communityID), zap.Error(err))
+	}
+}
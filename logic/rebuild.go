@@ -0,0 +1,270 @@
+package logic
+
+import (
+	"errors"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRebuildBatchSize = 500
+	maxRebuildBatchSize     = 5000
+	rebuildLockTTL          = time.Hour
+)
+
+var ErrorRebuildRunning = errors.New("Redis 排行榜正在重建或修复")
+
+// RebuildRedisOptions 重建 Redis 排行榜的参数
+type RebuildRedisOptions struct {
+	BatchSize int
+	DryRun    bool                       // 只统计需要写入的数据，不修改 Redis
+	Progress  func(p RebuildRedisResult) // 每处理完一批调用一次，可以为 nil
+}
+
+// RebuildRedisResult 重建的进度和结果
+type RebuildRedisResult struct {
+	Total       int64 `json:"total"`       // 开始时统计的帖子数
+	Posts       int64 `json:"posts"`       // 已处理的帖子数
+	Published   int64 `json:"published"`   // 加入排行榜的帖子数
+	Pending     int64 `json:"pending"`     // 加入审核队列的帖子数
+	Votes       int64 `json:"votes"`       // 恢复的投票数
+	Communities int   `json:"communities"` // 有帖子的社区数
+	Authors     int   `json:"authors"`     // 恢复了声望的作者数
+	CatchUp     int64 `json:"catch_up"`    // 重建期间新发的帖子数
+}
+
+// RebuildRedis 从 MySQL 重建 Redis 中的排行榜（发帖时间、分数、浏览量）、社区的帖子集合、投票记录、
+// 作者的帖子列表、待审核队列和作者声望，由 rebuild-redis 子命令调用
+// 排行榜和社区集合先写入临时 key，全部完成后一次替换，重建期间不影响线上读取
+// 帖子的分数按 post_vote 表中的投票计算，没有保存发布时间的草稿按创建时间计算
+// 只保存在 Redis 中的旧投票需要先用 export-votes 导出，否则这些投票会丢失
+func RebuildRedis(opts RebuildRedisOptions) (result *RebuildRedisResult, err error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultRebuildBatchSize
+	}
+	if opts.BatchSize > maxRebuildBatchSize {
+		opts.BatchSize = maxRebuildBatchSize
+	}
+	result = new(RebuildRedisResult)
+	if result.Total, err = mysql.CountRankPosts(); err != nil {
+		return nil, err
+	}
+	communityIDs, err := allCommunityIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.DryRun {
+		locked, err := redis.RankRebuildLock(rebuildLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			return nil, ErrorRebuildRunning
+		}
+		defer func() {
+			if err := redis.RankRebuildUnlock(); err != nil {
+				zap.L().Error("redis.RankRebuildUnlock() failed", zap.Error(err))
+			}
+		}()
+		if err := redis.RankRebuildStart(communityIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	zap.L().Info("rebuild redis started", zap.Int64("total", result.Total), zap.Bool("dry_run", opts.DryRun))
+	karma := make(map[int64]float64)
+	seen := make(map[int64]bool)
+	lastID, err := rebuildRankBatches(0, opts, result, karma, seen, redis.RankRebuildAdd)
+	if err != nil {
+		return nil, err
+	}
+	result.Communities = len(seen)
+
+	if !opts.DryRun {
+		withPosts, withoutPosts := make([]int64, 0, len(seen)), make([]int64, 0)
+		for id := range seen {
+			withPosts = append(withPosts, id)
+		}
+		for _, id := range communityIDs {
+			if !seen[id] {
+				withoutPosts = append(withoutPosts, id)
+			}
+		}
+		if err := redis.RankRebuildFinish(withPosts, withoutPosts); err != nil {
+			return nil, err
+		}
+		// 重建期间新发的帖子写入的是旧的排行榜，替换之后补上
+		before := result.Posts
+		if _, err := rebuildRankBatches(lastID, opts, result, karma, seen, redis.RankAdd); err != nil {
+			return nil, err
+		}
+		result.CatchUp = result.Posts - before
+		if err := redis.SetKarma(karma); err != nil {
+			return nil, err
+		}
+		// 重建之后帖子列表的缓存全部失效
+		for _, id := range communityIDs {
+			invalidatePostLists(id)
+		}
+	}
+	result.Authors = len(karma)
+	zap.L().Info("rebuild redis completed", zap.Any("result", result), zap.Duration("cost", time.Since(start)))
+	return result, nil
+}
+
+// rebuildRankBatches 从 afterID 开始分批处理帖子，返回最后一个帖子的 post_id
+// add 把已发布的帖子写入临时的或者正在使用的排行榜
+func rebuildRankBatches(afterID int64, opts RebuildRedisOptions, result *RebuildRedisResult,
+	karma map[int64]float64, seen map[int64]bool, add func([]redis.RankEntry) error) (lastID int64, err error) {
+	lastID = afterID
+	for {
+		posts, err := mysql.GetPostRanksAfter(lastID, opts.BatchSize)
+		if err != nil {
+			return lastID, err
+		}
+		if len(posts) == 0 {
+			return lastID, nil
+		}
+		if err := rebuildRankBatch(posts, opts.DryRun, result, karma, seen, add); err != nil {
+			return lastID, err
+		}
+		lastID = posts[len(posts)-1].ID
+		result.Posts += int64(len(posts))
+		if opts.Progress != nil {
+			opts.Progress(*result)
+		}
+	}
+}
+
+// rebuildRankBatch 处理一批帖子：已发布的加入排行榜、社区集合和作者的帖子列表并恢复投票，待审核的加入审核队列
+func rebuildRankBatch(posts []*models.PostRank, dryRun bool, result *RebuildRedisResult,
+	karma map[int64]float64, seen map[int64]bool, add func([]redis.RankEntry) error) error {
+	published := make([]int64, 0, len(posts))
+	authors := make(map[int64]int64, len(posts))
+	pending := make(map[int64]time.Time)
+	for _, p := range posts {
+		if p.Status == models.PostStatusPending {
+			pending[p.ID] = p.CreateTime
+			continue
+		}
+		published = append(published, p.ID)
+		authors[p.ID] = p.AuthorID
+	}
+
+	list, err := mysql.GetPostVotesByPostIDs(published)
+	if err != nil {
+		return err
+	}
+	votes := make(map[int64][]redis.PostVoteEntry, len(published))
+	netVotes := make(map[int64]float64, len(published))
+	for _, v := range list {
+		votes[v.PostID] = append(votes[v.PostID], redis.PostVoteEntry{UserID: v.UserID, Direction: v.Direction, Weight: v.Weight})
+		netVotes[v.PostID] += float64(v.Direction) * v.Weight
+		// 给自己投票不计入声望，和投票时的规则一致
+		if author := authors[v.PostID]; author != v.UserID {
+			karma[author] += float64(v.Direction) * v.Weight
+		}
+	}
+
+	entries := make([]redis.RankEntry, 0, len(published))
+	for _, p := range posts {
+		if p.Status != models.PostStatusNormal {
+			continue
+		}
+		publishTime := p.CreateTime
+		if p.PublishTime != nil {
+			publishTime = *p.PublishTime
+		}
+		entries = append(entries, redis.RankEntry{
+			PostID:      p.ID,
+			AuthorID:    p.AuthorID,
+			CommunityID: p.CommunityID,
+			PublishTime: publishTime,
+			NetVotes:    netVotes[p.ID],
+			Views:       p.ViewCount,
+		})
+		seen[p.CommunityID] = true
+	}
+	result.Published += int64(len(entries))
+	result.Pending += int64(len(pending))
+	result.Votes += int64(len(list))
+	if dryRun {
+		return nil
+	}
+
+	if err := add(entries); err != nil {
+		return err
+	}
+	if err := redis.SetPostVotes(votes, published); err != nil {
+		return err
+	}
+	if err := redis.AddUserPosts(entries); err != nil {
+		return err
+	}
+	return redis.AddPostsToReview(pending)
+}
+
+// allCommunityIDs 分批查询所有社区的id
+func allCommunityIDs() ([]int64, error) {
+	ids := make([]int64, 0)
+	var lastID int64
+	for {
+		batch, err := mysql.GetCommunityIDsAfter(lastID, maxRebuildBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return ids, nil
+		}
+		ids = append(ids, batch...)
+		lastID = batch[len(batch)-1]
+	}
+}
+
+// forEachPublishedPost 分批遍历 MySQL 中已发布的帖子，返回遍历的帖子数，用于补写上线之前的帖子的 Redis 数据
+func forEachPublishedPost(batchSize int, fn func(entries []redis.RankEntry) error) (count int64, err error) {
+	if batchSize <= 0 {
+		batchSize = defaultRebuildBatchSize
+	}
+	if batchSize > maxRebuildBatchSize {
+		batchSize = maxRebuildBatchSize
+	}
+	var lastID int64
+	for {
+		posts, err := mysql.GetPostRanksAfter(lastID, batchSize)
+		if err != nil {
+			return count, err
+		}
+		if len(posts) == 0 {
+			return count, nil
+		}
+		lastID = posts[len(posts)-1].ID
+		entries := make([]redis.RankEntry, 0, len(posts))
+		for _, p := range posts {
+			if p.Status != models.PostStatusNormal {
+				continue
+			}
+			publishTime := p.CreateTime
+			if p.PublishTime != nil {
+				publishTime = *p.PublishTime
+			}
+			entries = append(entries, redis.RankEntry{
+				PostID:      p.ID,
+				AuthorID:    p.AuthorID,
+				CommunityID: p.CommunityID,
+				PublishTime: publishTime,
+				Views:       p.ViewCount,
+			})
+		}
+		if err := fn(entries); err != nil {
+			return count, err
+		}
+		count += int64(len(entries))
+	}
+}
@@ -0,0 +1,110 @@
+package logic
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+var ErrorNotPending = errors.New("帖子不在审核中")
+
+// IsAdmin 用户是否是管理员，管理员由配置文件中的 auth.admin_ids 指定
+func IsAdmin(userID int64) bool {
+	return userID != 0 && settings.Conf.AuthConfig != nil && slices.Contains(settings.Conf.AdminIDs, userID)
+}
+
+// GetReviewPosts 分页获取审核队列中的帖子，先进入审核的在前
+// 队列中已经不是待审核状态的帖子（移出队列失败时会留在队列中）不返回
+func GetReviewPosts(page, size int64) ([]*models.Post, error) {
+	ids, err := redis.GetReviewPostIDs(page, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return make([]*models.Post, 0), nil
+	}
+	return mysql.GetPendingPostsByIDs(ids)
+}
+
+// ApprovePost 审核通过：帖子改为正常状态并移出审核队列
+// 新发的帖子按审核通过的时间发布；已经发布过、编辑后重新进入审核的帖子保持原来的排名
+func ApprovePost(postID int64) (*models.Post, error) {
+	post, err := mysql.GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	// 多个管理员同时审核同一个帖子时只有一个会成功
+	ok, err := mysql.UpdatePostStatus(postID, models.PostStatusPending, models.PostStatusNormal)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorNotPending
+	}
+	post.Status = models.PostStatusNormal
+
+	ranked, err := redis.IsPostRanked(postID)
+	if err == nil && !ranked {
+		err = publishPost(post, time.Now())
+	}
+	if err != nil {
+		// 改回待审核，帖子仍然在审核队列中，可以重新审核
+		if _, err := mysql.UpdatePostStatus(postID, models.PostStatusNormal, models.PostStatusPending); err != nil {
+			zap.L().Error("mysql.UpdatePostStatus() failed", zap.Int64("post_id", postID), zap.Error(err))
+		}
+		return nil, err
+	}
+	if ranked {
+		invalidatePostLists(post.CommunityID)
+	}
+	finishReview(postID)
+	zap.L().Info("post approved", zap.Int64("post_id", postID), zap.Bool("republished", ranked))
+	return post, nil
+}
+
+// RejectPost 审核不通过：帖子改为审核未通过状态并移出审核队列，之后只有作者自己可见
+// 已经发布过、编辑后重新进入审核的帖子同时从排行榜和社区的帖子集合中删除
+func RejectPost(postID int64) (*models.Post, error) {
+	post, err := mysql.GetPostByID(postID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := mysql.UpdatePostStatus(postID, models.PostStatusPending, models.PostStatusRejected)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrorNotPending
+	}
+	post.Status = models.PostStatusRejected
+
+	pid := []string{strconv.FormatInt(postID, 10)}
+	if err := redis.RemoveFromRank(pid); err != nil {
+		zap.L().Error("redis.RemoveFromRank() failed", zap.Int64("post_id", postID), zap.Error(err))
+	}
+	if err := redis.RemoveFromCommunity(post.CommunityID, pid); err != nil {
+		zap.L().Error("redis.RemoveFromCommunity() failed", zap.Int64("post_id", postID), zap.Error(err))
+	}
+	invalidatePostLists(post.CommunityID)
+	finishReview(postID)
+	zap.L().Info("post rejected", zap.Int64("post_id", postID))
+	return post, nil
+}
+
+// finishReview 把帖子移出审核队列并删除详情缓存，失败只记录日志
+// 留在队列中的帖子 GetReviewPosts 会按状态过滤掉
+func finishReview(postID int64) {
+	if err := redis.RemovePostFromReview(postID); err != nil {
+		zap.L().Error("redis.RemovePostFromReview() failed", zap.Int64("post_id", postID), zap.Error(err))
+	}
+	if err := redis.DeletePostCache(postID); err != nil {
+		zap.L().Error("redis.DeletePostCache() failed", zap.Int64("post_id", postID), zap.Error(err))
+	}
+}
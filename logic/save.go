@@ -0,0 +1,75 @@
+package logic
+
+import (
+	"strconv"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+// SavePost 收藏帖子，重复收藏时移动到新的收藏夹
+func SavePost(userID, postID int64, collection string) error {
+	if _, err := mysql.GetPostByID(postID); err != nil {
+		zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return err
+	}
+	return mysql.SavePost(userID, postID, collection)
+}
+
+// UnsavePost 取消收藏
+func UnsavePost(userID, postID int64) error {
+	return mysql.UnsavePost(userID, postID)
+}
+
+// GetSavedPosts 分页获取收藏的帖子，按收藏时间倒序
+func GetSavedPosts(userID int64, p *models.ParamsSavedList) (data *models.ApiSavedList, err error) {
+	data = &models.ApiSavedList{List: make([]*models.ApiPostDetail, 0)}
+	data.Total, err = mysql.CountSavedPosts(userID, p.Collection)
+	if err != nil {
+		zap.L().Error("mysql.CountSavedPosts() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	ids, err := mysql.GetSavedPostIDs(userID, p.Collection, p.Page, p.Size)
+	if err != nil {
+		zap.L().Error("mysql.GetSavedPostIDs() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return data, nil
+	}
+
+	posts, err := mysql.GetPostListByIDs(ids)
+	if err != nil {
+		zap.L().Error("mysql.GetPostListByIDs() failed", zap.Error(err))
+		return nil, err
+	}
+	data.List, err = buildPostDetailsWithCache(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 补充投票数
+	voteData, err := redis.GetPostVoteData(ids)
+	if err != nil {
+		zap.L().Error("redis.GetPostVoteData() failed", zap.Error(err))
+		voteData = nil
+	}
+	voteMap := make(map[int64]int64, len(ids))
+	for idx := range voteData {
+		pid, _ := strconv.ParseInt(ids[idx], 10, 64)
+		voteMap[pid] = voteData[idx]
+	}
+	saved := true
+	for _, detail := range data.List {
+		detail.VoteNum = voteMap[detail.Post.ID]
+		detail.Saved = &saved
+	}
+	return data, nil
+}
+
+// GetSavedCollections 获取用户的收藏夹列表
+func GetSavedCollections(userID int64) ([]*models.SavedCollection, error) {
+	return mysql.GetSavedCollections(userID)
+}
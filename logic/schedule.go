@@ -0,0 +1,85 @@
+package logic
+
+import (
+	"context"
+	"sync"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	scheduleInterval = 10 * time.Second // 检查定时发布的间隔
+	scheduleBatch    = 100
+)
+
+var (
+	schedulerCancel context.CancelFunc
+	schedulerWG     sync.WaitGroup
+)
+
+// InitPostScheduler 定时发布到时间的帖子
+// 待发布的帖子只保存在 MySQL 中，每次都重新扫描，服务重启后不会丢失
+func InitPostScheduler() {
+	var ctx context.Context
+	ctx, schedulerCancel = context.WithCancel(context.Background())
+	schedulerWG.Add(1)
+	go func() {
+		defer schedulerWG.Done()
+		ticker := time.NewTicker(scheduleInterval)
+		defer ticker.Stop()
+		for {
+			// 启动时先补发停机期间到期的帖子
+			publishDuePosts()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// StopPostScheduler 停止定时发布并等待正在进行的发布结束，在 srv.Shutdown 之后调用
+func StopPostScheduler() {
+	if schedulerCancel != nil {
+		schedulerCancel()
+		schedulerWG.Wait()
+	}
+}
+
+// publishDuePosts 发布到时间的帖子，按帖子的发布时间加入排行榜
+func publishDuePosts() {
+	posts, err := mysql.GetDuePosts(time.Now(), scheduleBatch)
+	if err != nil {
+		zap.L().Error("mysql.GetDuePosts() failed", zap.Error(err))
+		return
+	}
+	for _, p := range posts {
+		// 多个实例同时扫描时只有修改状态成功的实例负责发布
+		ok, err := mysql.UpdatePostStatus(p.ID, models.PostStatusScheduled, models.PostStatusNormal)
+		if err != nil {
+			zap.L().Error("mysql.UpdatePostStatus() failed", zap.Int64("post_id", p.ID), zap.Error(err))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		p.Status = models.PostStatusNormal
+		if err := publishPost(p, *p.PublishTime); err != nil {
+			zap.L().Error("publishPost() failed, retry later", zap.Int64("post_id", p.ID), zap.Error(err))
+			// 改回定时发布状态，下次扫描时重试
+			if _, err := mysql.UpdatePostStatus(p.ID, models.PostStatusNormal, models.PostStatusScheduled); err != nil {
+				zap.L().Error("mysql.UpdatePostStatus() failed", zap.Int64("post_id", p.ID), zap.Error(err))
+			}
+			continue
+		}
+		if err := redis.DeletePostCache(p.ID); err != nil {
+			zap.L().Error("redis.DeletePostCache() failed", zap.Int64("post_id", p.ID), zap.Error(err))
+		}
+		zap.L().Info("scheduled post published", zap.Int64("post_id", p.ID))
+	}
+}
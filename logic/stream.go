@@ -0,0 +1,215 @@
+package logic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/pkg/stream"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHeartbeatInterval = 15 * time.Second
+	defaultStreamBufferSize  = 64
+	defaultStreamMaxDropped  = 32
+	defaultStreamTicketTTL   = 30 * time.Second
+)
+
+var ErrorInvalidStreamTicket = errors.New("凭证无效或已过期")
+
+var (
+	streamHub       *stream.Hub
+	streamCancel    context.CancelFunc
+	streamHeartbeat = defaultHeartbeatInterval
+	streamTicketTTL = defaultStreamTicketTTL
+)
+
+// TopicPost 单个帖子的事件
+func TopicPost(postID int64) string {
+	return "post:" + strconv.FormatInt(postID, 10)
+}
+
+// TopicCommunity 社区的事件
+func TopicCommunity(communityID int64) string {
+	return "community:" + strconv.FormatInt(communityID, 10)
+}
+
+// TopicUser 用户的私有事件（通知）
+func TopicUser(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// InitStream 创建本机的事件分发中心并开始订阅 Redis 频道
+func InitStream(cfg *settings.StreamConfig) {
+	bufferSize, maxDropped := defaultStreamBufferSize, defaultStreamMaxDropped
+	if cfg != nil {
+		if cfg.HeartbeatInterval > 0 {
+			streamHeartbeat = time.Duration(cfg.HeartbeatInterval) * time.Second
+		}
+		if cfg.BufferSize > 0 {
+			bufferSize = cfg.BufferSize
+		}
+		if cfg.MaxDropped > 0 {
+			maxDropped = cfg.MaxDropped
+		}
+		if cfg.TicketTTL > 0 {
+			streamTicketTTL = time.Duration(cfg.TicketTTL) * time.Second
+		}
+	}
+	streamHub = stream.NewHub(bufferSize, maxDropped)
+
+	var ctx context.Context
+	ctx, streamCancel = context.WithCancel(context.Background())
+	go func() {
+		// 订阅失败（比如 Redis 暂时不可用）时定时重试
+		for {
+			err := redis.SubscribeEvents(ctx, streamHub.Publish)
+			if ctx.Err() != nil {
+				return
+			}
+			zap.L().Error("redis.SubscribeEvents() failed, retry later", zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+}
+
+// StopStream 停止订阅并断开所有实时连接，注册在 srv.Shutdown 上
+func StopStream() {
+	if streamCancel != nil {
+		streamCancel()
+	}
+	if streamHub != nil {
+		streamHub.Close()
+	}
+	zap.L().Info("stream hub stopped")
+}
+
+// SubscribeStream 订阅一组 topic
+func SubscribeStream(topics []string) (*stream.Client, error) {
+	if streamHub == nil {
+		return nil, stream.ErrHubClosed
+	}
+	return streamHub.Subscribe(topics)
+}
+
+// CheckStreamPosts 订阅帖子的事件之前检查帖子是否存在、当前用户是否可见，viewerID 为 0 表示未登录
+// 不可见的帖子和帖子详情一样按帖子不存在处理，否则可以通过投票事件看到草稿和待审核帖子的动态
+func CheckStreamPosts(postIDs []int64, viewerID int64) error {
+	for _, postID := range postIDs {
+		post, err := mysql.GetPostByID(postID)
+		if errors.Is(err, mysql.ErrorInvalidID) {
+			return ErrorPostNotExist
+		}
+		if err != nil {
+			zap.L().Error("mysql.GetPostByID() failed", zap.Int64("post_id", postID), zap.Error(err))
+			return err
+		}
+		if !CanViewPost(post, viewerID) {
+			return ErrorPostNotExist
+		}
+	}
+	return nil
+}
+
+// UnsubscribeStream 连接结束时取消订阅
+func UnsubscribeStream(c *stream.Client) {
+	streamHub.Unsubscribe(c)
+}
+
+// CreateStreamTicket 生成订阅通知用的一次性凭证
+// EventSource 和浏览器的 WebSocket 不能设置请求头，用短期有效、只能使用一次的凭证代替放在 URL 中的 JWT
+func CreateStreamTicket(userID int64) (ticket string, ttl time.Duration, err error) {
+	b := make([]byte, 24)
+	if _, err = rand.Read(b); err != nil {
+		return "", 0, err
+	}
+	ticket = hex.EncodeToString(b)
+	if err = redis.SetStreamTicket(ticket, userID, streamTicketTTL); err != nil {
+		return "", 0, err
+	}
+	return ticket, streamTicketTTL, nil
+}
+
+// UseStreamTicket 使用凭证，返回凭证对应的用户，凭证使用后立即失效
+func UseStreamTicket(ticket string) (int64, error) {
+	userID, err := redis.TakeStreamTicket(ticket)
+	if err == redis.Nil {
+		return 0, ErrorInvalidStreamTicket
+	}
+	return userID, err
+}
+
+// StreamHeartbeat 心跳间隔
+func StreamHeartbeat() time.Duration {
+	return streamHeartbeat
+}
+
+// StreamStats 实时连接统计
+func StreamStats() map[string]int64 {
+	if streamHub == nil {
+		return nil
+	}
+	return streamHub.Stats()
+}
+
+// publishEvent 通过 Redis 发布事件，所有实例（包括自己）都会收到
+func publishEvent(topic, eventType string, data interface{}) {
+	payload, err := json.Marshal(&models.StreamEvent{
+		Topic: topic,
+		Type:  eventType,
+		Data:  data,
+	})
+	if err != nil {
+		zap.L().Error("marshal stream event failed", zap.String("topic", topic), zap.Error(err))
+		return
+	}
+	if err := redis.PublishEvent(topic, payload); err != nil {
+		zap.L().Error("redis.PublishEvent() failed", zap.String("topic", topic), zap.Error(err))
+	}
+}
+
+// publishVoteEvent 推送帖子最新的投票数和分数
+func publishVoteEvent(postID string) {
+	pid, err := strconv.ParseInt(postID, 10, 64)
+	if err != nil {
+		return
+	}
+	voteData, err := redis.GetPostVoteData([]string{postID})
+	if err != nil || len(voteData) == 0 {
+		zap.L().Error("redis.GetPostVoteData() failed", zap.String("post_id", postID), zap.Error(err))
+		return
+	}
+	score, err := redis.GetPostScore(postID)
+	if err != nil {
+		zap.L().Error("redis.GetPostScore() failed", zap.String("post_id", postID), zap.Error(err))
+		return
+	}
+	publishEvent(TopicPost(pid), models.StreamEventVote, map[string]interface{}{
+		"post_id":  postID,
+		"vote_num": voteData[0],
+		"score":    score,
+	})
+}
+
+// publishPostEvent 推送社区的新帖子
+func publishPostEvent(p *models.Post) {
+	publishEvent(TopicCommunity(p.CommunityID), models.StreamEventPost, map[string]interface{}{
+		"post_id":      strconv.FormatInt(p.ID, 10),
+		"community_id": p.CommunityID,
+		"author_id":    strconv.FormatInt(p.AuthorID, 10),
+		"title":        p.Title,
+	})
+}
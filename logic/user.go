@@ -1,6 +1,8 @@
 package logic
 
 import (
+	"database/sql"
+	"errors"
 	"web-app/dao/mysql"
 	"web-app/models"
 	"web-app/pkg/jwt"
@@ -27,7 +29,11 @@ func SignUp(p *models.ParamsSignUp) (err error) {
 	}
 
 	// 3.保存进数据库
-	return mysql.InsertUser(user)
+	if err := mysql.InsertUser(user); err != nil {
+		return err
+	}
+	bloomAdd(BloomUser, userID)
+	return nil
 }
 
 func Login(p *models.ParamsLogin) (user *models.User, err error) {
@@ -49,3 +55,16 @@ func Login(p *models.ParamsLogin) (user *models.User, err error) {
 	user.Token = token
 	return
 }
+
+// getUserByID 根据用户id查询用户，布隆过滤器判定一定不存在的用户直接返回，不查数据库
+func getUserByID(userID int64) (*models.User, error) {
+	if !bloomMayContain(BloomUser, userID) {
+		return nil, mysql.ErrorUserNotExist
+	}
+	user, err := mysql.GetUserByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		bloomFalsePositive(BloomUser)
+		return nil, mysql.ErrorUserNotExist
+	}
+	return user, err
+}
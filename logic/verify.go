@@ -0,0 +1,315 @@
+package logic
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// 报告中最多列出的问题数，超过时只计数
+	maxVerifyIssues = 200
+	// 分数允许的误差，投票权重是小数，多次 ZINCRBY 后会有浮点误差
+	scoreTolerance = 0.01
+)
+
+// 一致性检查发现的问题类型
+const (
+	VerifyMissing    = "missing"    // 已发布的帖子不在排行榜或者所属社区的集合中
+	VerifyOrphaned   = "orphaned"   // 排行榜或社区集合中的帖子在 MySQL 中不存在或者没有发布
+	VerifyMismatched = "mismatched" // 帖子在其他社区的集合中，或者分数和投票记录不一致
+)
+
+// VerifyIssue 一条不一致的数据
+type VerifyIssue struct {
+	Type   string `json:"type"`
+	Key    string `json:"key"`
+	PostID string `json:"post_id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// VerifyReport 一致性检查的结果
+type VerifyReport struct {
+	Posts      int64          `json:"posts"` // 检查的已发布帖子数
+	Missing    int64          `json:"missing"`
+	Orphaned   int64          `json:"orphaned"`
+	Mismatched int64          `json:"mismatched"`
+	ByKey      map[string]int `json:"by_key"` // 每个 key 的问题数，社区集合合并为 community:*
+	Fixed      bool           `json:"fixed"`
+	Issues     []VerifyIssue  `json:"issues"`
+	Truncated  bool           `json:"truncated"` // 问题太多，issues 只列出了前一部分
+}
+
+func (r *VerifyReport) add(typ, key, postID, detail string) {
+	switch typ {
+	case VerifyMissing:
+		r.Missing++
+	case VerifyOrphaned:
+		r.Orphaned++
+	case VerifyMismatched:
+		r.Mismatched++
+	}
+	group := key
+	if strings.HasPrefix(key, redis.KeyCommunitySetPF) {
+		group = redis.KeyCommunitySetPF + "*"
+	}
+	r.ByKey[group]++
+	if len(r.Issues) >= maxVerifyIssues {
+		r.Truncated = true
+		return
+	}
+	r.Issues = append(r.Issues, VerifyIssue{Type: typ, Key: key, PostID: postID, Detail: detail})
+}
+
+// VerifyOptions 一致性检查的参数
+type VerifyOptions struct {
+	BatchSize int
+	Fix       bool // 修复发现的问题：补上缺少的、删除多余的、修正不一致的
+}
+
+// VerifyRedis 检查 MySQL 中的帖子和 Redis 中的 post:time、post:score、社区集合是否一致
+// 先按 MySQL 中已发布的帖子检查 Redis 中是否缺少，再遍历 Redis 检查多余和放错社区的帖子
+// 检查期间发帖和投票会产生少量误报，修复时建议在低峰期执行，数据大面积丢失时用 rebuild-redis 重建
+func VerifyRedis(opts VerifyOptions) (report *VerifyReport, err error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultRebuildBatchSize
+	}
+	if opts.BatchSize > maxRebuildBatchSize {
+		opts.BatchSize = maxRebuildBatchSize
+	}
+	if opts.Fix {
+		// 和重建互斥，避免修复的数据被重建覆盖
+		locked, err := redis.RankRebuildLock(rebuildLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			return nil, ErrorRebuildRunning
+		}
+		defer func() {
+			if err := redis.RankRebuildUnlock(); err != nil {
+				zap.L().Error("redis.RankRebuildUnlock() failed", zap.Error(err))
+			}
+		}()
+	}
+
+	report = &VerifyReport{ByKey: make(map[string]int), Fixed: opts.Fix, Issues: make([]VerifyIssue, 0)}
+	if err := verifyPublishedPosts(opts, report); err != nil {
+		return nil, err
+	}
+	// post:views 中之前记录过草稿和待审核帖子的浏览，一并检查
+	for _, key := range []string{redis.KeyPostTimeZSet, redis.KeyPostScoreZSet, redis.KeyPostViewsZSet} {
+		if err := verifyRankZSet(key, opts, report); err != nil {
+			return nil, err
+		}
+	}
+	if err := verifyCommunitySets(opts, report); err != nil {
+		return nil, err
+	}
+	zap.L().Info("verify redis completed",
+		zap.Int64("posts", report.Posts),
+		zap.Int64("missing", report.Missing),
+		zap.Int64("orphaned", report.Orphaned),
+		zap.Int64("mismatched", report.Mismatched),
+		zap.Bool("fix", opts.Fix))
+	return report, nil
+}
+
+// verifyPublishedPosts 已发布的帖子是否都在 post:time、post:score 和所属社区的集合中，分数是否和投票记录一致
+func verifyPublishedPosts(opts VerifyOptions, report *VerifyReport) error {
+	var lastID int64
+	for {
+		posts, err := mysql.GetPostRanksAfter(lastID, opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		if len(posts) == 0 {
+			return nil
+		}
+		lastID = posts[len(posts)-1].ID
+
+		entries := make([]redis.RankEntry, 0, len(posts))
+		for _, p := range posts {
+			if p.Status != models.PostStatusNormal {
+				continue
+			}
+			publishTime := p.CreateTime
+			if p.PublishTime != nil {
+				publishTime = *p.PublishTime
+			}
+			entries = append(entries, redis.RankEntry{PostID: p.ID, CommunityID: p.CommunityID, PublishTime: publishTime, Views: p.ViewCount})
+		}
+		states, err := redis.GetPostIndexStates(entries)
+		if err != nil {
+			return err
+		}
+		report.Posts += int64(len(entries))
+
+		repairs := make([]redis.PostIndexRepair, 0)
+		for i, e := range entries {
+			s, pid := states[i], strconv.FormatInt(e.PostID, 10)
+			repair := redis.PostIndexRepair{PostID: e.PostID, CommunityID: e.CommunityID, Views: e.Views}
+			if !s.InTime {
+				report.add(VerifyMissing, redis.KeyPostTimeZSet, pid, "")
+				// 没有保存发布时间的草稿按创建时间计算
+				t := float64(e.PublishTime.Unix())
+				s.Time, repair.Time = t, &t
+			}
+			expected := s.ExpectedScore()
+			if !s.InScore {
+				report.add(VerifyMissing, redis.KeyPostScoreZSet, pid, "")
+				repair.Score = &expected
+			} else if math.Abs(s.Score-expected) > scoreTolerance {
+				report.add(VerifyMismatched, redis.KeyPostScoreZSet, pid,
+					fmt.Sprintf("score %.2f, expected %.2f", s.Score, expected))
+				repair.Score = &expected
+			}
+			if !s.InCommunity {
+				report.add(VerifyMissing, redis.KeyCommunitySetPF+strconv.FormatInt(e.CommunityID, 10), pid, "")
+				repair.Community = true
+			}
+			if repair.Time != nil || repair.Score != nil || repair.Community {
+				repairs = append(repairs, repair)
+			}
+		}
+		if opts.Fix {
+			if err := redis.RepairPostIndexes(repairs); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// verifyRankZSet 排行榜（发帖时间、分数、浏览量）中的帖子是否都是 MySQL 中已发布的帖子
+func verifyRankZSet(key string, opts VerifyOptions, report *VerifyReport) error {
+	var cursor uint64
+	for {
+		ids, next, err := redis.ScanRankZSet(key, cursor, int64(opts.BatchSize))
+		if err != nil {
+			return err
+		}
+		posts, err := getPostRanks(ids)
+		if err != nil {
+			return err
+		}
+		orphans := make([]string, 0)
+		for _, id := range ids {
+			p, ok := posts[id]
+			if ok && p.Status == models.PostStatusNormal {
+				continue
+			}
+			report.add(VerifyOrphaned, key, id, postStatusDetail(p))
+			orphans = append(orphans, id)
+		}
+		if opts.Fix {
+			if err := redis.RemoveFromRank(orphans); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// verifyCommunitySets 社区集合中的帖子是否都是这个社区已发布的帖子，MySQL 中不存在的社区的集合整个删除
+func verifyCommunitySets(opts VerifyOptions, report *VerifyReport) error {
+	setIDs, err := redis.GetCommunitySetIDs()
+	if err != nil {
+		return err
+	}
+	communityIDs, err := allCommunityIDs()
+	if err != nil {
+		return err
+	}
+	exists := make(map[int64]bool, len(communityIDs))
+	for _, id := range communityIDs {
+		exists[id] = true
+	}
+
+	for _, communityID := range setIDs {
+		key := redis.KeyCommunitySetPF + strconv.FormatInt(communityID, 10)
+		if !exists[communityID] {
+			size, err := redis.CommunitySetSize(communityID)
+			if err != nil {
+				return err
+			}
+			report.add(VerifyOrphaned, key, "", fmt.Sprintf("community not found, %d posts", size))
+			if opts.Fix {
+				if err := redis.DeleteCommunitySet(communityID); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		var cursor uint64
+		for {
+			ids, next, err := redis.ScanCommunitySet(communityID, cursor, int64(opts.BatchSize))
+			if err != nil {
+				return err
+			}
+			posts, err := getPostRanks(ids)
+			if err != nil {
+				return err
+			}
+			remove := make([]string, 0)
+			for _, id := range ids {
+				p, ok := posts[id]
+				switch {
+				case !ok || p.Status != models.PostStatusNormal:
+					report.add(VerifyOrphaned, key, id, postStatusDetail(p))
+				case p.CommunityID != communityID:
+					// 所属社区的集合中缺少的在 verifyPublishedPosts 中已经补上
+					report.add(VerifyMismatched, key, id, fmt.Sprintf("post belongs to community %d", p.CommunityID))
+				default:
+					continue
+				}
+				remove = append(remove, id)
+			}
+			if opts.Fix {
+				if err := redis.RemoveFromCommunity(communityID, remove); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				break
+			}
+			cursor = next
+		}
+	}
+	return nil
+}
+
+// getPostRanks 按 Redis 中的帖子id批量查询 MySQL，不是数字的id忽略（会被当作不存在的帖子）
+func getPostRanks(ids []string) (map[string]*models.PostRank, error) {
+	postIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if postID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			postIDs = append(postIDs, postID)
+		}
+	}
+	posts, err := mysql.GetPostRanksByIDs(postIDs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*models.PostRank, len(posts))
+	for _, p := range posts {
+		result[strconv.FormatInt(p.ID, 10)] = p
+	}
+	return result, nil
+}
+
+// postStatusDetail 帖子不在 MySQL 中或者没有发布的原因
+func postStatusDetail(p *models.PostRank) string {
+	if p == nil {
+		return "post not found"
+	}
+	return fmt.Sprintf("post status %d", p.Status)
+}
@@ -0,0 +1,202 @@
+package logic
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultViewFlushInterval   = 5 * time.Second
+	defaultViewPersistInterval = 60 * time.Second
+	viewPersistBatch           = 500
+)
+
+// viewBuffer 本机缓冲的浏览记录，帖子详情接口只写内存，由后台定时批量写入 Redis
+type viewBuffer struct {
+	mu       sync.Mutex
+	counts   map[string]int64
+	visitors map[string]map[string]struct{}
+}
+
+var (
+	views        = newViewBuffer()
+	viewCancel   context.CancelFunc
+	viewStopped  = make(chan struct{})
+	viewStopOnce sync.Once
+)
+
+func newViewBuffer() *viewBuffer {
+	return &viewBuffer{
+		counts:   make(map[string]int64),
+		visitors: make(map[string]map[string]struct{}),
+	}
+}
+
+// swap 取出当前缓冲的记录并清空
+func (b *viewBuffer) swap() (counts map[string]int64, visitors map[string][]string) {
+	b.mu.Lock()
+	oldCounts, oldVisitors := b.counts, b.visitors
+	b.counts = make(map[string]int64)
+	b.visitors = make(map[string]map[string]struct{})
+	b.mu.Unlock()
+
+	visitors = make(map[string][]string, len(oldVisitors))
+	for postID, set := range oldVisitors {
+		list := make([]string, 0, len(set))
+		for v := range set {
+			list = append(list, v)
+		}
+		visitors[postID] = list
+	}
+	return oldCounts, visitors
+}
+
+// RecordPostView 记录一次帖子浏览，visitor 是用户id或者匿名访客的指纹
+// 只统计已发布的帖子，作者查看自己的草稿、待审核的帖子不计入，也不会因此进入按浏览量排序的列表
+// 只写本机内存，不增加帖子详情接口访问 Redis 的次数
+func RecordPostView(post *models.Post, visitor string) {
+	if post == nil || post.Status != models.PostStatusNormal {
+		return
+	}
+	pid := strconv.FormatInt(post.ID, 10)
+	views.mu.Lock()
+	views.counts[pid]++
+	set, ok := views.visitors[pid]
+	if !ok {
+		set = make(map[string]struct{})
+		views.visitors[pid] = set
+	}
+	set[visitor] = struct{}{}
+	views.mu.Unlock()
+}
+
+// InitViewCounter 启动浏览量的定时刷新：内存 -> Redis -> MySQL
+func InitViewCounter(cfg *settings.ViewConfig) {
+	flushInterval, persistInterval := defaultViewFlushInterval, defaultViewPersistInterval
+	if cfg != nil {
+		if cfg.FlushInterval > 0 {
+			flushInterval = time.Duration(cfg.FlushInterval) * time.Second
+		}
+		if cfg.PersistInterval > 0 {
+			persistInterval = time.Duration(cfg.PersistInterval) * time.Second
+		}
+	}
+
+	var ctx context.Context
+	ctx, viewCancel = context.WithCancel(context.Background())
+	go func() {
+		defer close(viewStopped)
+		flushTicker := time.NewTicker(flushInterval)
+		defer flushTicker.Stop()
+		persistTicker := time.NewTicker(persistInterval)
+		defer persistTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// 停机前把缓冲的浏览记录写入 Redis
+				flushViews()
+				return
+			case <-flushTicker.C:
+				flushViews()
+			case <-persistTicker.C:
+				persistViews()
+			}
+		}
+	}()
+}
+
+// StopViewCounter 停止定时刷新并写入剩余的浏览记录，在 srv.Shutdown 之后调用
+func StopViewCounter() {
+	viewStopOnce.Do(func() {
+		if viewCancel == nil {
+			return
+		}
+		viewCancel()
+		<-viewStopped
+	})
+}
+
+// flushViews 把本机缓冲的浏览记录批量写入 Redis，失败时只把没有写入成功的部分放回缓冲等下次重试
+// 整批放回会把已经累加成功的浏览量再累加一次
+func flushViews() {
+	counts, visitors := views.swap()
+	if len(counts) == 0 {
+		return
+	}
+	failedCounts, failedVisitors, err := redis.AddPostViews(counts, visitors)
+	if err != nil {
+		zap.L().Error("redis.AddPostViews() failed", zap.Int("posts", len(counts)),
+			zap.Int("failed", len(failedCounts)), zap.Error(err))
+		views.merge(failedCounts, failedVisitors)
+	}
+}
+
+// merge 把没有写入成功的浏览记录放回缓冲
+func (b *viewBuffer) merge(counts map[string]int64, visitors map[string][]string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for pid, count := range counts {
+		b.counts[pid] += count
+	}
+	for pid, list := range visitors {
+		// 只有访客没有写入成功时也要有浏览次数的记录，下次刷新才会带上这个帖子
+		b.counts[pid] += 0
+		set, ok := b.visitors[pid]
+		if !ok {
+			set = make(map[string]struct{})
+			b.visitors[pid] = set
+		}
+		for _, v := range list {
+			set[v] = struct{}{}
+		}
+	}
+}
+
+// persistViews 把浏览量有变化的帖子同步到 MySQL
+func persistViews() {
+	ids, err := redis.PopDirtyViewPosts(viewPersistBatch)
+	if err != nil {
+		zap.L().Error("redis.PopDirtyViewPosts() failed", zap.Error(err))
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	counters, err := redis.GetPostCounters(ids)
+	if err != nil {
+		zap.L().Error("redis.GetPostCounters() failed", zap.Error(err))
+		if err := redis.MarkViewPostsDirty(ids); err != nil {
+			zap.L().Error("redis.MarkViewPostsDirty() failed", zap.Error(err))
+		}
+		return
+	}
+	failed := make([]string, 0)
+	for idx, id := range ids {
+		postID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		if err := mysql.UpdatePostViews(postID, counters[idx].Views, counters[idx].UniqueVisitors); err != nil {
+			zap.L().Error("mysql.UpdatePostViews() failed", zap.Int64("post_id", postID), zap.Error(err))
+			failed = append(failed, id)
+		}
+	}
+	if err := redis.MarkViewPostsDirty(failed); err != nil {
+		zap.L().Error("redis.MarkViewPostsDirty() failed", zap.Error(err))
+	}
+}
+
+// BackfillPostViews 把 MySQL 中已发布的帖子补进 post:views，返回处理的帖子数，由 backfill-views 子命令调用
+// 浏览量统计上线之前的帖子没有被浏览过时不在 post:views 中，按浏览量排序的列表里看不到
+// 已经在 post:views 中的帖子不修改，可以重复执行
+func BackfillPostViews(batchSize int) (int64, error) {
+	return forEachPublishedPost(batchSize, redis.AddPostViewsNX)
+}
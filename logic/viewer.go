@@ -0,0 +1,91 @@
+package logic
+
+import (
+	"strconv"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+// FillViewerState 补充帖子的赞成/反对票数、浏览量、链接预览、转发的原帖，以及当前用户的投票和收藏状态
+// userID 为 0 表示未登录，只补充计数、链接预览和原帖
+// 帖子详情可能来自缓存并被多个请求共享，这里返回浅拷贝，不修改原对象
+func FillViewerState(userID int64, data []*models.ApiPostDetail) []*models.ApiPostDetail {
+	ids := make([]string, 0, len(data))
+	postIDs := make([]int64, 0, len(data))
+	for _, detail := range data {
+		if detail != nil && detail.Post != nil {
+			ids = append(ids, strconv.FormatInt(detail.Post.ID, 10))
+			postIDs = append(postIDs, detail.Post.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return data
+	}
+
+	result := make([]*models.ApiPostDetail, 0, len(data))
+	for _, detail := range data {
+		if detail == nil || detail.Post == nil {
+			result = append(result, detail)
+			continue
+		}
+		d := *detail
+		result = append(result, &d)
+	}
+
+	counters, err := redis.GetPostCounters(ids)
+	if err != nil {
+		zap.L().Error("redis.GetPostCounters() failed", zap.Error(err))
+	} else {
+		counterMap := make(map[int64]redis.PostCounters, len(ids))
+		for idx, pid := range postIDs {
+			counterMap[pid] = counters[idx]
+		}
+		for _, d := range result {
+			if d != nil && d.Post != nil {
+				c := counterMap[d.Post.ID]
+				d.UpVotes, d.DownVotes = c.UpVotes, c.DownVotes
+				d.ViewCount, d.UniqueVisitors = c.Views, c.UniqueVisitors
+			}
+		}
+	}
+
+	fillLinkPreviews(result)
+	fillCrossposts(result)
+
+	if userID == 0 {
+		return result
+	}
+
+	// 当前用户的投票，一次 pipeline 查询所有帖子
+	votes, err := redis.GetUserVotes(strconv.FormatInt(userID, 10), ids)
+	if err != nil {
+		zap.L().Error("redis.GetUserVotes() failed", zap.Int64("user_id", userID), zap.Error(err))
+	} else {
+		voteMap := make(map[int64]int8, len(ids))
+		for idx, pid := range postIDs {
+			voteMap[pid] = votes[idx]
+		}
+		for _, d := range result {
+			if d != nil && d.Post != nil {
+				myVote := voteMap[d.Post.ID]
+				d.MyVote = &myVote
+			}
+		}
+	}
+
+	savedSet, err := mysql.GetSavedPostSet(userID, postIDs)
+	if err != nil {
+		zap.L().Error("mysql.GetSavedPostSet() failed", zap.Int64("user_id", userID), zap.Error(err))
+	} else {
+		for _, d := range result {
+			if d != nil && d.Post != nil {
+				saved := savedSet[d.Post.ID]
+				d.Saved = &saved
+			}
+		}
+	}
+	return result
+}
@@ -2,18 +2,127 @@ package logic
 
 import (
 	"strconv"
+	"web-app/dao/mysql"
 	"web-app/dao/redis"
 	"web-app/models"
 
 	"go.uber.org/zap"
 )
 
-// VoteForPost 为帖子投票
-func VoteForPost(userID int64, p *models.ParamsVote) error {
+// VoteForPost 为帖子投票，info 是投票请求的IP和设备，用于刷票检测
+func VoteForPost(userID int64, p *models.ParamsVote, info *models.ClientInfo) error {
 	zap.L().Debug("VoteForPost", 
 		zap.Int64("userID", userID), 
 		zap.String("postID", p.PostID), 
 		zap.String("postID", p.PostID), 
 		zap.Int8("direction", p.Direction))
-	return redis.VoteForPost(strconv.Itoa(int(userID)), p.PostID, float64(p.Direction))
-} 
\ No newline at end of file
+	postID, _ := strconv.ParseInt(p.PostID, 10, 64)
+	post, err := mysql.GetPostByID(postID)
+	if err != nil {
+		return err
+	}
+
+	// Redis 不可用时投票先写入本地队列，恢复后重放
+	// 队列里还有没重放完的投票时也要排队，保证同一个用户的投票按顺序生效
+	if !redis.Available() || VoteQueueLen() > 0 {
+		return enqueueVote(userID, p, info)
+	}
+	err = doVote(userID, post, p, info)
+	if redis.IsUnavailable(err) {
+		return enqueueVote(userID, p, info)
+	}
+	return err
+}
+
+// doVote 计算投票权重并更新 Redis 中的分数，之后记录投票日志、更新作者声望
+func doVote(userID int64, post *models.Post, p *models.ParamsVote, info *models.ClientInfo) error {
+	postID := post.ID
+	// 按账号可信度计算投票权重，可疑的投票降权或者不计分
+	weight := 1.0
+	if p.Direction != 0 {
+		var reasons []string
+		weight, reasons = voteWeight(userID, post.AuthorID, p.PostID, info)
+		if len(reasons) > 0 {
+			zap.L().Info("vote down-weighted",
+				zap.Int64("user_id", userID),
+				zap.String("post_id", p.PostID),
+				zap.Float64("weight", weight),
+				zap.Strings("reasons", reasons))
+		}
+	}
+
+	oldValue, delta, err := redis.VoteForPost(strconv.Itoa(int(userID)), p.PostID, float64(p.Direction), weight)
+	if err != nil {
+		return err
+	}
+	// 保存到 MySQL，Redis 数据丢失后可以重建
+	persistVote(&models.PostVote{PostID: postID, UserID: userID, Direction: p.Direction, Weight: weight})
+	// 投票改变了帖子的分数和排序
+	invalidatePostLists(post.CommunityID)
+	// 记录投票日志，失败不影响投票结果
+	if err := mysql.InsertVoteLog(&models.VoteLog{
+		PostID:       postID,
+		UserID:       userID,
+		Direction:    p.Direction,
+		OldDirection: int8(oldValue),
+		Weight:       weight,
+	}); err != nil {
+		zap.L().Error("mysql.InsertVoteLog() failed", zap.String("post_id", p.PostID), zap.Int64("user_id", userID), zap.Error(err))
+	}
+	// 作者的声望随帖子获得的加权票数变化，给自己投票不算
+	if post.AuthorID != userID && delta != 0 {
+		if err := redis.IncrKarma(post.AuthorID, delta); err != nil {
+			zap.L().Error("redis.IncrKarma() failed", zap.Int64("author_id", post.AuthorID), zap.Error(err))
+		}
+	}
+	// 只有新投票计入共同投票统计，反复改票不会累加
+	if oldValue == 0 && p.Direction != 0 {
+		go recordVoteForAbuse(userID, p.PostID)
+	}
+	// 实时推送最新的投票数
+	go publishVoteEvent(p.PostID)
+	// 赞成票可能让帖子达到投票里程碑
+	if p.Direction == 1 {
+		go checkVoteMilestone(p.PostID)
+	}
+	return nil
+}
+
+// voteDirections 查询参数中的投票方向
+var voteDirections = map[string]int8{
+	"up":     1,
+	"down":   -1,
+	"cancel": 0,
+}
+
+// GetMyVotes 按游标分页获取当前用户的投票记录
+func GetMyVotes(userID int64, p *models.ParamsVoteLogList) (data *models.ApiVoteLogList, err error) {
+	var direction *int8
+	if d, ok := voteDirections[p.Direction]; ok {
+		direction = &d
+	}
+	list, err := mysql.GetUserVoteLogs(userID, direction, p.Cursor, p.Size)
+	if err != nil {
+		zap.L().Error("mysql.GetUserVoteLogs() failed", zap.Int64("user_id", userID), zap.Error(err))
+		return nil, err
+	}
+	return newVoteLogList(list, p.Size), nil
+}
+
+// GetPostVotes 按游标分页获取帖子的投票记录，供管理员排查刷票
+func GetPostVotes(postID int64, p *models.ParamsVoteLogList) (data *models.ApiVoteLogList, err error) {
+	list, err := mysql.GetPostVoteLogs(postID, p.Cursor, p.Size)
+	if err != nil {
+		zap.L().Error("mysql.GetPostVoteLogs() failed", zap.Int64("post_id", postID), zap.Error(err))
+		return nil, err
+	}
+	return newVoteLogList(list, p.Size), nil
+}
+
+func newVoteLogList(list []*models.VoteLog, size int64) *models.ApiVoteLogList {
+	data := &models.ApiVoteLogList{List: list}
+	if int64(len(list)) == size {
+		data.NextCursor = strconv.FormatInt(list[len(list)-1].ID, 10)
+	}
+	return data
+}
@@ -0,0 +1,198 @@
+package logic
+
+import (
+	"context"
+	"sync"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	votePersistInterval   = time.Second
+	votePersistBatch      = 500
+	votePersistMaxPending = 10000 // MySQL 长时间不可用时最多缓冲的投票数，超出的可以用 export-votes 从 Redis 补回
+)
+
+type postVoteKey struct {
+	PostID int64
+	UserID int64
+}
+
+// voteBuffer 写入 MySQL 失败、等待重试的投票，同一个帖子和用户只保留最新的一次
+type voteBuffer struct {
+	mu    sync.Mutex
+	votes map[postVoteKey]*models.PostVote
+}
+
+var (
+	pendingVotes        = &voteBuffer{votes: make(map[postVoteKey]*models.PostVote)}
+	votePersistCancel   context.CancelFunc
+	votePersistStopped  = make(chan struct{})
+	votePersistStopOnce sync.Once
+)
+
+// add 把投票放入缓冲，override 为 false 时已经有同一个用户的投票则不放入
+// 缓冲已满时丢弃，返回 false
+func (b *voteBuffer) add(v *models.PostVote, override bool) bool {
+	k := postVoteKey{v.PostID, v.UserID}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.votes[k]; ok {
+		if override {
+			b.votes[k] = v
+		}
+		return true
+	}
+	if len(b.votes) >= votePersistMaxPending {
+		return false
+	}
+	b.votes[k] = v
+	return true
+}
+
+// persistVote 投票写入 Redis 成功后同步写入 MySQL 的 post_vote 表，进程退出不会丢失
+// 写入失败时放入缓冲由后台重试
+func persistVote(v *models.PostVote) {
+	err := mysql.UpsertPostVotes([]*models.PostVote{v})
+	if err == nil {
+		// 之前写入失败的投票不再重试，避免旧的投票覆盖新的
+		pendingVotes.mu.Lock()
+		delete(pendingVotes.votes, postVoteKey{v.PostID, v.UserID})
+		pendingVotes.mu.Unlock()
+		return
+	}
+	zap.L().Error("mysql.UpsertPostVotes() failed, retry later",
+		zap.Int64("post_id", v.PostID), zap.Int64("user_id", v.UserID), zap.Error(err))
+	if !pendingVotes.add(v, true) {
+		zap.L().Error("vote persist buffer full, vote dropped",
+			zap.Int64("post_id", v.PostID), zap.Int64("user_id", v.UserID))
+	}
+}
+
+// InitVotePersister 启动写入失败的投票的定时重试
+func InitVotePersister() {
+	var ctx context.Context
+	ctx, votePersistCancel = context.WithCancel(context.Background())
+	go func() {
+		defer close(votePersistStopped)
+		ticker := time.NewTicker(votePersistInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// 停机前写入剩余的投票
+				flushVotes()
+				return
+			case <-ticker.C:
+				flushVotes()
+			}
+		}
+	}()
+}
+
+// StopVotePersister 停止定时重试并最后写入一次缓冲的投票，在 srv.Shutdown 之后调用
+func StopVotePersister() {
+	votePersistStopOnce.Do(func() {
+		if votePersistCancel == nil {
+			return
+		}
+		votePersistCancel()
+		<-votePersistStopped
+	})
+}
+
+// flushVotes 把缓冲的投票分批写入 MySQL，失败时放回缓冲等下次重试
+func flushVotes() {
+	pendingVotes.mu.Lock()
+	votes := pendingVotes.votes
+	pendingVotes.votes = make(map[postVoteKey]*models.PostVote)
+	pendingVotes.mu.Unlock()
+	if len(votes) == 0 {
+		return
+	}
+
+	batch := make([]*models.PostVote, 0, votePersistBatch)
+	failed := make([]*models.PostVote, 0)
+	flush := func() {
+		if err := mysql.UpsertPostVotes(batch); err != nil {
+			zap.L().Error("mysql.UpsertPostVotes() failed", zap.Int("votes", len(batch)), zap.Error(err))
+			failed = append(failed, batch...)
+		}
+		batch = batch[:0]
+	}
+	for _, v := range votes {
+		batch = append(batch, v)
+		if len(batch) == votePersistBatch {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
+	}
+	if len(failed) == 0 {
+		return
+	}
+	// 重试期间用户又投了票时以新的为准，缓冲已满时丢弃
+	dropped := 0
+	for _, v := range failed {
+		if !pendingVotes.add(v, false) {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		zap.L().Error("vote persist buffer full, votes dropped", zap.Int("votes", dropped))
+	}
+}
+
+// ExportVotes 把 Redis 中的投票记录写入 MySQL 的 post_vote 表，返回处理的帖子数和投票数，由 export-votes 子命令调用
+// 投票写入 post_vote 之前的旧投票只保存在 Redis 中，不导出的话 rebuild-redis 会把这些帖子的分数重置
+// 以 Redis 中的投票为准覆盖 post_vote，可以重复执行
+func ExportVotes(batchSize int) (posts, votes int64, err error) {
+	if batchSize <= 0 {
+		batchSize = defaultRebuildBatchSize
+	}
+	if batchSize > maxRebuildBatchSize {
+		batchSize = maxRebuildBatchSize
+	}
+	var lastID int64
+	for {
+		list, err := mysql.GetPostRanksAfter(lastID, batchSize)
+		if err != nil {
+			return posts, votes, err
+		}
+		if len(list) == 0 {
+			return posts, votes, nil
+		}
+		lastID = list[len(list)-1].ID
+		postIDs := make([]int64, 0, len(list))
+		for _, p := range list {
+			postIDs = append(postIDs, p.ID)
+		}
+		entries, err := redis.GetPostVotes(postIDs)
+		if err != nil {
+			return posts, votes, err
+		}
+		batch := make([]*models.PostVote, 0, votePersistBatch)
+		for _, postID := range postIDs {
+			for _, e := range entries[postID] {
+				batch = append(batch, &models.PostVote{PostID: postID, UserID: e.UserID, Direction: e.Direction, Weight: e.Weight})
+				if len(batch) == votePersistBatch {
+					if err := mysql.UpsertPostVotes(batch); err != nil {
+						return posts, votes, err
+					}
+					votes += int64(len(batch))
+					batch = batch[:0]
+				}
+			}
+		}
+		if err := mysql.UpsertPostVotes(batch); err != nil {
+			return posts, votes, err
+		}
+		votes += int64(len(batch))
+		posts += int64(len(list))
+	}
+}
@@ -0,0 +1,358 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultVoteQueueFile  = "./data/vote_queue.jsonl"
+	defaultReplayInterval = 5 * time.Second
+)
+
+var ErrorVoteQueueClosed = errors.New("投票队列未启用")
+
+// queuedVote Redis 不可用时缓冲在本地文件中的一次投票，每行一条 JSON
+type queuedVote struct {
+	UserID    int64     `json:"user_id"`
+	PostID    string    `json:"post_id"`
+	Direction int8      `json:"direction"`
+	IP        string    `json:"ip,omitempty"`
+	DeviceID  string    `json:"device_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// voteQueue 投票的本地持久化队列
+// 新的投票追加到 path，重放时先把 path 改名为 path.replay，重放期间的新投票继续追加到 path
+type voteQueue struct {
+	path  string
+	apply func(line []byte) error // 重放一条投票，返回错误时停止重放，默认是 replayVote
+
+	mu   sync.Mutex
+	file *os.File
+
+	pending   atomic.Int64 // 两个文件中还没有重放的投票数
+	replaying atomic.Bool
+	replayed  atomic.Int64
+	dropped   atomic.Int64
+
+	stop     chan struct{}
+	stopped  chan struct{} // 定时重放的 goroutine 退出后关闭
+	replayMu sync.Mutex    // 重放期间持有，停止时等待正在进行的重放结束
+}
+
+var votes *voteQueue
+
+// InitVoteQueue 打开本地投票队列，统计上次退出时没有重放完的投票，并定时重放
+func InitVoteQueue(c *settings.DegradeConfig) error {
+	q := &voteQueue{path: defaultVoteQueueFile, stop: make(chan struct{}), stopped: make(chan struct{})}
+	q.apply = q.replayVote
+	interval := defaultReplayInterval
+	if c != nil {
+		if c.VoteQueueFile != "" {
+			q.path = c.VoteQueueFile
+		}
+		if c.ReplayInterval > 0 {
+			interval = time.Duration(c.ReplayInterval) * time.Second
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return err
+	}
+	for _, name := range []string{q.replayPath(), q.path} {
+		n, err := countLines(name)
+		if err != nil {
+			return err
+		}
+		q.pending.Add(n)
+	}
+	if n := q.pending.Load(); n > 0 {
+		zap.L().Info("found queued votes", zap.Int64("count", n), zap.String("file", q.path))
+	}
+	votes = q
+
+	redis.OnRecover(q.replay)
+	go func() {
+		defer close(q.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-q.stop:
+				return
+			case <-ticker.C:
+				if q.pending.Load() == 0 {
+					continue
+				}
+				if redis.Available() {
+					q.replay()
+				} else {
+					// 熔断器打开超时后 Ping 作为探测请求，成功后熔断器关闭并触发重放
+					_ = redis.Ping()
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// StopVoteQueue 停止定时重放并关闭队列文件，没有重放的投票在下次启动后继续重放
+// 等待正在进行的重放写回剩下的投票后才返回，在 srv.Shutdown 之后、关闭 Redis 和 MySQL 之前调用
+func StopVoteQueue() {
+	q := votes
+	if q == nil {
+		return
+	}
+	if q.stopping() {
+		return
+	}
+	close(q.stop)
+	<-q.stopped
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+}
+
+// VoteQueueLen 本地队列中等待重放的投票数
+func VoteQueueLen() int64 {
+	if votes == nil {
+		return 0
+	}
+	return votes.pending.Load()
+}
+
+// VoteQueueStats 本地投票队列的状态，用于健康检查
+func VoteQueueStats() map[string]interface{} {
+	if votes == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled":   true,
+		"pending":   votes.pending.Load(),
+		"replaying": votes.replaying.Load(),
+		"replayed":  votes.replayed.Load(),
+		"dropped":   votes.dropped.Load(),
+	}
+}
+
+// enqueueVote 把投票写入本地队列，写入磁盘后才返回
+func enqueueVote(userID int64, p *models.ParamsVote, info *models.ClientInfo) error {
+	q := votes
+	if q == nil {
+		return ErrorVoteQueueClosed
+	}
+	v := queuedVote{UserID: userID, PostID: p.PostID, Direction: p.Direction, Time: time.Now()}
+	if info != nil {
+		v.IP, v.DeviceID = info.IP, info.DeviceID
+	}
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file == nil {
+		f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			zap.L().Error("open vote queue failed", zap.String("file", q.path), zap.Error(err))
+			return err
+		}
+		q.file = f
+	}
+	if _, err := q.file.Write(line); err != nil {
+		zap.L().Error("write vote queue failed", zap.Error(err))
+		return err
+	}
+	if err := q.file.Sync(); err != nil {
+		zap.L().Error("sync vote queue failed", zap.Error(err))
+		return err
+	}
+	q.pending.Add(1)
+	zap.L().Info("vote queued", zap.Int64("user_id", userID), zap.String("post_id", p.PostID))
+	return nil
+}
+
+// stopping 队列是否已经停止，停止后不再重放
+func (q *voteQueue) stopping() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *voteQueue) replayPath() string {
+	return q.path + ".replay"
+}
+
+// replay 按顺序重放队列中的投票，Redis 再次不可用时停止，剩下的投票留到下次重放
+func (q *voteQueue) replay() {
+	if !q.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer q.replaying.Store(false)
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+
+	for q.pending.Load() > 0 && !q.stopping() {
+		// 上次没有重放完的文件优先，否则把当前队列换成新文件
+		if _, err := os.Stat(q.replayPath()); os.IsNotExist(err) {
+			if !q.rotate() {
+				return
+			}
+		}
+		if !q.replayFile(q.replayPath()) {
+			return
+		}
+	}
+}
+
+// rotate 把当前队列文件改名为待重放文件，之后的投票写入新文件
+func (q *voteQueue) rotate() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+	if err := os.Rename(q.path, q.replayPath()); err != nil {
+		if !os.IsNotExist(err) {
+			zap.L().Error("rotate vote queue failed", zap.String("file", q.path), zap.Error(err))
+		}
+		return false
+	}
+	return true
+}
+
+// replayFile 重放一个文件中的投票，全部处理完后删除文件
+// 中途停止时把剩下的投票写回文件，返回 false
+func (q *voteQueue) replayFile(name string) bool {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		zap.L().Error("read vote queue failed", zap.String("file", name), zap.Error(err))
+		return false
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		err := ErrorVoteQueueClosed
+		if !q.stopping() {
+			err = q.apply(line)
+		}
+		if err != nil {
+			zap.L().Warn("replay queued votes stopped", zap.Int("remaining", len(lines)-i), zap.Error(err))
+			if err := writeFileAtomic(name, bytes.Join(lines[i:], nil)); err != nil {
+				zap.L().Error("rewrite vote queue failed", zap.String("file", name), zap.Error(err))
+			}
+			return false
+		}
+		q.pending.Add(-1)
+	}
+	if err := os.Remove(name); err != nil {
+		zap.L().Error("remove vote queue failed", zap.String("file", name), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// replayVote 重放一条投票，只有 Redis 或 MySQL 不可用时返回错误，需要稍后重试
+// 帖子不存在、重复投票、投票已过期的投票直接丢弃
+func (q *voteQueue) replayVote(line []byte) error {
+	var v queuedVote
+	if err := json.Unmarshal(line, &v); err != nil {
+		zap.L().Error("invalid queued vote", zap.ByteString("line", bytes.TrimSpace(line)), zap.Error(err))
+		q.dropped.Add(1)
+		return nil
+	}
+	postID, _ := strconv.ParseInt(v.PostID, 10, 64)
+	post, err := mysql.GetPostByID(postID)
+	if errors.Is(err, mysql.ErrorInvalidID) {
+		zap.L().Info("queued vote dropped, post not found", zap.Int64("user_id", v.UserID), zap.String("post_id", v.PostID))
+		q.dropped.Add(1)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p := &models.ParamsVote{PostID: v.PostID, Direction: v.Direction}
+	err = doVote(v.UserID, post, p, &models.ClientInfo{IP: v.IP, DeviceID: v.DeviceID})
+	if redis.IsUnavailable(err) {
+		return err
+	}
+	if err != nil {
+		zap.L().Info("queued vote dropped",
+			zap.Int64("user_id", v.UserID),
+			zap.String("post_id", v.PostID),
+			zap.Time("time", v.Time),
+			zap.Error(err))
+		q.dropped.Add(1)
+		return nil
+	}
+	q.replayed.Add(1)
+	return nil
+}
+
+// countLines 文件中非空行的数量，文件不存在时为 0
+func countLines(name string) (int64, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var n int64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
+
+// writeFileAtomic 先写临时文件再改名，避免写到一半时进程退出导致文件损坏
+func writeFileAtomic(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
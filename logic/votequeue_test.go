@@ -0,0 +1,253 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"web-app/dao/redis"
+	"web-app/models"
+)
+
+// testVoteQueue 在临时目录中创建队列，重放时记录投票的用户id，用户id在 fail 中时按 Redis 不可用处理
+type testVoteQueue struct {
+	*voteQueue
+	applied []int64
+	fail    map[int64]bool
+}
+
+func newTestVoteQueue(t *testing.T) *testVoteQueue {
+	t.Helper()
+	q := &testVoteQueue{
+		voteQueue: &voteQueue{
+			path:    filepath.Join(t.TempDir(), "vote_queue.jsonl"),
+			stop:    make(chan struct{}),
+			stopped: make(chan struct{}),
+		},
+		fail: make(map[int64]bool),
+	}
+	q.apply = func(line []byte) error {
+		var v queuedVote
+		if err := json.Unmarshal(line, &v); err != nil {
+			t.Fatalf("invalid queued vote %q: %v", line, err)
+		}
+		if q.fail[v.UserID] {
+			return redis.ErrorUnavailable
+		}
+		q.applied = append(q.applied, v.UserID)
+		return nil
+	}
+	old := votes
+	votes = q.voteQueue
+	t.Cleanup(func() {
+		q.mu.Lock()
+		if q.file != nil {
+			q.file.Close()
+		}
+		q.mu.Unlock()
+		votes = old
+	})
+	return q
+}
+
+func voteLine(t *testing.T, userID int64) string {
+	t.Helper()
+	line, err := json.Marshal(queuedVote{UserID: userID, PostID: "1", Direction: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(line) + "\n"
+}
+
+// queuedUserIDs 文件中的投票的用户id，文件不存在时为 nil
+func queuedUserIDs(t *testing.T, name string) []int64 {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var v queuedVote
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("decode %s: %v", name, err)
+		}
+		ids = append(ids, v.UserID)
+	}
+	return ids
+}
+
+func TestVoteQueueReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		leftover string  // 上次没有重放完的 .replay 文件的内容
+		queued   []int64 // 调用 enqueueVote 写入队列的投票
+		fail     []int64 // 重放到这些用户的投票时 Redis 不可用
+
+		wantApplied []int64
+		wantReplay  []int64 // 停止后写回 .replay 文件的投票
+		wantQueue   []int64 // 还在队列文件中的投票
+	}{
+		{
+			name:        "all replayed",
+			queued:      []int64{1, 2, 3},
+			wantApplied: []int64{1, 2, 3},
+		},
+		{
+			name:        "leftover replayed first",
+			leftover:    "1\n2\n",
+			queued:      []int64{3, 4},
+			wantApplied: []int64{1, 2, 3, 4},
+		},
+		{
+			name:        "blank lines skipped",
+			leftover:    "\n1\n\n2\n",
+			wantApplied: []int64{1, 2},
+		},
+		{
+			name:        "stop when redis unavailable",
+			queued:      []int64{1, 2, 3},
+			fail:        []int64{2},
+			wantApplied: []int64{1},
+			wantReplay:  []int64{2, 3},
+		},
+		{
+			name:       "leftover fails before rotating the queue",
+			leftover:   "1\n2\n",
+			queued:     []int64{3},
+			fail:       []int64{1},
+			wantReplay: []int64{1, 2},
+			wantQueue:  []int64{3},
+		},
+		{
+			name:        "queue fails after leftover",
+			leftover:    "1\n",
+			queued:      []int64{2, 3},
+			fail:        []int64{3},
+			wantApplied: []int64{1, 2},
+			wantReplay:  []int64{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestVoteQueue(t)
+			if tt.leftover != "" {
+				var data string
+				for _, s := range strings.Split(tt.leftover, "\n") {
+					if s == "" {
+						data += "\n"
+						continue
+					}
+					id, _ := strconv.ParseInt(s, 10, 64)
+					data += voteLine(t, id)
+				}
+				if err := os.WriteFile(q.replayPath(), []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				// 和 InitVoteQueue 一样按文件中的非空行统计
+				n, err := countLines(q.replayPath())
+				if err != nil {
+					t.Fatal(err)
+				}
+				q.pending.Add(n)
+			}
+			for _, id := range tt.queued {
+				if err := enqueueVote(id, &models.ParamsVote{PostID: "1", Direction: 1}, nil); err != nil {
+					t.Fatalf("enqueueVote(%d) error = %v", id, err)
+				}
+			}
+			for _, id := range tt.fail {
+				q.fail[id] = true
+			}
+
+			q.replay()
+			if !reflect.DeepEqual(q.applied, tt.wantApplied) {
+				t.Errorf("applied = %v, want %v", q.applied, tt.wantApplied)
+			}
+			if got := queuedUserIDs(t, q.replayPath()); !reflect.DeepEqual(got, tt.wantReplay) {
+				t.Errorf("replay file = %v, want %v", got, tt.wantReplay)
+			}
+			if got := queuedUserIDs(t, q.path); !reflect.DeepEqual(got, tt.wantQueue) {
+				t.Errorf("queue file = %v, want %v", got, tt.wantQueue)
+			}
+			wantPending := int64(len(tt.wantReplay) + len(tt.wantQueue))
+			if got := q.pending.Load(); got != wantPending {
+				t.Errorf("pending = %d, want %d", got, wantPending)
+			}
+
+			// Redis 恢复后按原来的顺序重放剩下的投票
+			q.fail = nil
+			q.replay()
+			want := append(append(append([]int64{}, tt.wantApplied...), tt.wantReplay...), tt.wantQueue...)
+			if !reflect.DeepEqual(q.applied, want) {
+				t.Errorf("applied after recovery = %v, want %v", q.applied, want)
+			}
+			if q.pending.Load() != 0 {
+				t.Errorf("pending after recovery = %d, want 0", q.pending.Load())
+			}
+			for _, name := range []string{q.path, q.replayPath()} {
+				if _, err := os.Stat(name); !os.IsNotExist(err) {
+					t.Errorf("%s should be removed, err = %v", filepath.Base(name), err)
+				}
+			}
+		})
+	}
+}
+
+func TestVoteQueueEnqueueDuringReplay(t *testing.T) {
+	q := newTestVoteQueue(t)
+	for _, id := range []int64{1, 2} {
+		if err := enqueueVote(id, &models.ParamsVote{PostID: "1", Direction: 1}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 重放期间的新投票写入新的队列文件，不会被当前这次重放丢掉
+	apply := q.apply
+	q.apply = func(line []byte) error {
+		if len(q.applied) == 0 {
+			if err := enqueueVote(3, &models.ParamsVote{PostID: "1", Direction: 1}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return apply(line)
+	}
+	q.replay()
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(q.applied, want) {
+		t.Errorf("applied = %v, want %v", q.applied, want)
+	}
+	if q.pending.Load() != 0 {
+		t.Errorf("pending = %d, want 0", q.pending.Load())
+	}
+}
+
+func TestVoteQueueStopped(t *testing.T) {
+	q := newTestVoteQueue(t)
+	for _, id := range []int64{1, 2} {
+		if err := enqueueVote(id, &models.ParamsVote{PostID: "1", Direction: 1}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(q.stopped) // 没有启动定时重放的 goroutine
+	StopVoteQueue()
+	StopVoteQueue() // 重复调用不会 panic
+
+	// 停止后不再重放，投票留在文件中等下次启动
+	q.replay()
+	if len(q.applied) != 0 {
+		t.Errorf("applied = %v after stop", q.applied)
+	}
+	if got := queuedUserIDs(t, q.path); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("queue file = %v, want [1 2]", got)
+	}
+	if q.pending.Load() != 2 {
+		t.Errorf("pending = %d, want 2", q.pending.Load())
+	}
+}
@@ -0,0 +1,255 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+	"web-app/dao/mysql"
+	"web-app/dao/redis"
+	"web-app/models"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWarmUpPostSize  = 500
+	defaultWarmUpBatchSize = 100
+	maxWarmUpBatchSize     = 1000 // 和 mysql.BatchGetUsersByIDs 的限制一致
+)
+
+var ErrorWarmUpRunning = errors.New("缓存预热正在进行")
+
+// warmUpStats 缓存预热的进度和结果
+var warmUpStats struct {
+	running      atomic.Bool
+	runs         atomic.Int64
+	failures     atomic.Int64
+	lastStart    atomic.Int64 // unix 秒
+	lastDuration atomic.Int64 // 毫秒
+	total        atomic.Int64 // 本次需要预热的帖子数
+	posts        atomic.Int64 // 本次已经预热的帖子数
+	users        atomic.Int64
+	communities  atomic.Int64
+}
+
+var (
+	warmUpCancel context.CancelFunc
+	warmUpWG     sync.WaitGroup
+)
+
+// warmUpConfig 返回补齐默认值之后的预热配置
+func warmUpConfig(c *settings.WarmUpConfig) settings.WarmUpConfig {
+	cfg := settings.WarmUpConfig{}
+	if c != nil {
+		cfg = *c
+	}
+	if cfg.PostSize <= 0 {
+		cfg.PostSize = defaultWarmUpPostSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWarmUpBatchSize
+	}
+	if cfg.BatchSize > maxWarmUpBatchSize {
+		cfg.BatchSize = maxWarmUpBatchSize
+	}
+	return cfg
+}
+
+// InitCacheWarmer 启动时预热一次缓存，之后按 interval 定时预热
+// 多个实例之间用锁保证同一时间只有一个实例查询数据库
+func InitCacheWarmer(c *settings.WarmUpConfig) {
+	cfg := warmUpConfig(c)
+	if !cfg.Enable {
+		return
+	}
+	var ctx context.Context
+	ctx, warmUpCancel = context.WithCancel(context.Background())
+	warmUpWG.Add(1)
+	go func() {
+		defer warmUpWG.Done()
+		interval := time.Duration(cfg.Interval) * time.Second
+		for {
+			lockTTL := interval / 2
+			if lockTTL <= 0 {
+				lockTTL = time.Minute
+			}
+			if locked, err := redis.TryWarmUpLock(lockTTL); err != nil {
+				zap.L().Error("redis.TryWarmUpLock() failed", zap.Error(err))
+			} else if locked {
+				if err := WarmUpCache(ctx, cfg); err != nil && ctx.Err() == nil {
+					zap.L().Error("logic.WarmUpCache() failed", zap.Error(err))
+				}
+			}
+			if interval <= 0 {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// StopCacheWarmer 停止定时预热，正在进行的预热会在当前批次结束后退出，返回前等待退出
+func StopCacheWarmer() {
+	if warmUpCancel != nil {
+		warmUpCancel()
+		warmUpWG.Wait()
+	}
+}
+
+// WarmUpCache 预热缓存：所有社区、热度和浏览量排名靠前的帖子详情以及这些帖子的作者
+// 分批查询数据库，每批之间暂停 batch_interval，避免预热时数据库压力过大
+func WarmUpCache(ctx context.Context, cfg settings.WarmUpConfig) (err error) {
+	if !warmUpStats.running.CompareAndSwap(false, true) {
+		return ErrorWarmUpRunning
+	}
+	defer warmUpStats.running.Store(false)
+
+	start := time.Now()
+	warmUpStats.runs.Add(1)
+	warmUpStats.lastStart.Store(start.Unix())
+	warmUpStats.total.Store(0)
+	warmUpStats.posts.Store(0)
+	warmUpStats.users.Store(0)
+	warmUpStats.communities.Store(0)
+	defer func() {
+		warmUpStats.lastDuration.Store(time.Since(start).Milliseconds())
+		if err != nil {
+			warmUpStats.failures.Add(1)
+		}
+	}()
+	zap.L().Info("cache warm up started", zap.Int64("post_size", cfg.PostSize))
+
+	// 1. 所有社区
+	communities, err := mysql.GetCommunityList()
+	if err != nil {
+		zap.L().Error("mysql.GetCommunityList() failed", zap.Error(err))
+		return err
+	}
+	communityIDs := make([]int64, 0, len(communities))
+	for _, c := range communities {
+		communityIDs = append(communityIDs, c.ID)
+	}
+	for i := 0; i < len(communityIDs); i += cfg.BatchSize {
+		batch := communityIDs[i:min(i+cfg.BatchSize, len(communityIDs))]
+		communityMap, err := mysql.BatchGetCommunitiesByIDs(batch)
+		if err != nil {
+			zap.L().Error("mysql.BatchGetCommunitiesByIDs() failed", zap.Error(err))
+			return err
+		}
+		communityCache.SetMany(communityMap)
+		warmUpStats.communities.Add(int64(len(communityMap)))
+		if err := warmUpPause(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	// 2. 按热度和浏览量排名靠前的帖子，去重
+	postIDs := make([]string, 0, cfg.PostSize*2)
+	seen := make(map[string]bool)
+	for _, order := range []string{models.OrderScore, models.OrderViews} {
+		ids, err := redis.GetPostIDsInOrder(&models.ParamsPostList{Page: 1, Size: cfg.PostSize, Order: order})
+		if err != nil {
+			zap.L().Error("redis.GetPostIDsInOrder() failed", zap.String("order", order), zap.Error(err))
+			return err
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				postIDs = append(postIDs, id)
+			}
+		}
+	}
+	warmUpStats.total.Store(int64(len(postIDs)))
+
+	// 3. 分批预热帖子详情和作者
+	for i := 0; i < len(postIDs); i += cfg.BatchSize {
+		batch := postIDs[i:min(i+cfg.BatchSize, len(postIDs))]
+		if err := warmUpPosts(batch); err != nil {
+			return err
+		}
+		zap.L().Info("cache warm up progress",
+			zap.Int64("posts", warmUpStats.posts.Load()),
+			zap.Int("total", len(postIDs)),
+			zap.Int64("users", warmUpStats.users.Load()))
+		if err := warmUpPause(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	zap.L().Info("cache warm up completed",
+		zap.Int64("communities", warmUpStats.communities.Load()),
+		zap.Int64("posts", warmUpStats.posts.Load()),
+		zap.Int64("users", warmUpStats.users.Load()),
+		zap.Duration("cost", time.Since(start)))
+	return nil
+}
+
+// warmUpPosts 预热一批帖子详情及其作者
+func warmUpPosts(ids []string) error {
+	posts, err := mysql.GetPostListByIDs(ids)
+	if err != nil {
+		zap.L().Error("mysql.GetPostListByIDs() failed", zap.Error(err))
+		return err
+	}
+	authorIDs := make([]int64, 0, len(posts))
+	for _, p := range posts {
+		authorIDs = append(authorIDs, p.AuthorID)
+	}
+	userMap, err := mysql.BatchGetUsersByIDs(authorIDs)
+	if err != nil {
+		zap.L().Error("mysql.BatchGetUsersByIDs() failed", zap.Error(err))
+		return err
+	}
+	userCache.SetMany(userMap)
+	warmUpStats.users.Add(int64(len(userMap)))
+
+	details, err := buildPostDetailsWithCache(posts)
+	if err != nil {
+		return err
+	}
+	detailMap := make(map[int64]*models.ApiPostDetail, len(details))
+	for _, d := range details {
+		detailMap[d.Post.ID] = d
+	}
+	postDetailCache.SetMany(detailMap)
+	warmUpStats.posts.Add(int64(len(detailMap)))
+	return nil
+}
+
+// warmUpPause 每批之间暂停，停机时立即退出
+func warmUpPause(ctx context.Context, cfg settings.WarmUpConfig) error {
+	if cfg.BatchInterval <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(cfg.BatchInterval) * time.Millisecond):
+		return nil
+	}
+}
+
+// warmUpStatsMap 缓存预热的进度，用于 /cache/stats
+func warmUpStatsMap() map[string]interface{} {
+	result := map[string]interface{}{
+		"running":          warmUpStats.running.Load(),
+		"runs":             warmUpStats.runs.Load(),
+		"failures":         warmUpStats.failures.Load(),
+		"last_duration_ms": warmUpStats.lastDuration.Load(),
+		"total":            warmUpStats.total.Load(),
+		"posts":            warmUpStats.posts.Load(),
+		"users":            warmUpStats.users.Load(),
+		"communities":      warmUpStats.communities.Load(),
+	}
+	if last := warmUpStats.lastStart.Load(); last > 0 {
+		result["last_start"] = time.Unix(last, 0).Format(time.RFC3339)
+	}
+	return result
+}
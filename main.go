@@ -28,6 +28,8 @@ import (
 	"web-app/dao/mysql"
 	"web-app/dao/redis"
 	"web-app/logger"
+	"web-app/logic"
+	"web-app/pkg/filter"
 	"web-app/pkg/snowflake"
 	"web-app/router"
 	"web-app/settings"
@@ -45,6 +47,13 @@ func main() {
 	// 	return
 	// }
 
+	// 运维子命令：bluebell <command> [config.yaml]
+	if len(os.Args) >= 2 {
+		if _, ok := commands[os.Args[1]]; ok {
+			os.Exit(runCommand(os.Args[1], os.Args[2:]))
+		}
+	}
+
 	// 1. 加载配置
 	var err error
 	if len(os.Args) >= 2 {
@@ -87,6 +96,45 @@ func main() {
 		return
 	}
 
+	// 加载敏感词库，配置文件变更时重新加载
+	if err := filter.Init(settings.Conf.FilterConfig); err != nil {
+		fmt.Printf("filter.Init() failed, err: %v \n", err)
+		return
+	}
+	settings.OnChange(func() {
+		filter.Reload(settings.Conf.FilterConfig)
+	})
+
+	// 开启进程内一级缓存，订阅其他实例的缓存失效通知
+	logic.InitCache(settings.Conf.CacheConfig)
+	// 布隆过滤器拦截不存在的id，Redis 中没有时在后台从 MySQL 重建
+	logic.InitBloomFilter(settings.Conf.BloomConfig)
+	// 启动时和定时预热热门帖子、社区和作者的缓存
+	logic.InitCacheWarmer(settings.Conf.WarmUpConfig)
+	// 订阅实时事件频道，多个实例通过 Redis Pub/Sub 同步推送
+	logic.InitStream(settings.Conf.StreamConfig)
+	// 定时保存到截止时间的帖子投票结果
+	logic.InitPollCloser()
+	// 定时发布到时间的帖子
+	logic.InitPostScheduler()
+	// 定时把浏览量写入 Redis 和 MySQL
+	logic.InitViewCounter(settings.Conf.ViewConfig)
+	// 后台抓取链接帖子的预览信息
+	logic.InitLinkFetcher()
+	// 重试写入 MySQL 失败的投票
+	logic.InitVotePersister()
+	// Redis 不可用时投票写入本地队列，恢复后重放
+	if err := logic.InitVoteQueue(settings.Conf.DegradeConfig); err != nil {
+		fmt.Printf("logic.InitVoteQueue() failed, err: %v \n", err)
+		return
+	}
+
+	// 站点地址用于生成订阅源、站点地图中的链接，必须配置
+	if err := logic.InitSite(settings.Conf.SiteConfig); err != nil {
+		fmt.Printf("logic.InitSite() failed, err: %v \n", err)
+		return
+	}
+
 	// 初始化gin框架内置的校验器使用的翻译器
 	if err := controller.InitTrans("zh"); err != nil {
 		fmt.Printf("controller.InitTrans() failed, err: %v \n", err)
@@ -106,6 +154,9 @@ func main() {
 		Addr:    fmt.Sprintf(":%d", viper.GetInt("port")),
 		Handler: r,
 	}
+	// SSE/WebSocket 是长连接，Shutdown 不会等待它们，开始关机时主动断开
+	// RegisterOnShutdown 的回调在单独的 goroutine 中执行，Shutdown 不会等待，其他后台任务在 Shutdown 之后依次停止
+	srv.RegisterOnShutdown(logic.StopStream)
 
 	go func() {
 		// 开启一个goroutine启动服务
@@ -130,6 +181,18 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		zap.L().Fatal("Server Shutdown: ", zap.Error(err))
 	}
+	// 后台任务要在 defer 关闭 Redis 和 MySQL 之前停止，每个 Stop 都等待对应的 goroutine 退出
+	logic.StopPollCloser()
+	logic.StopPostScheduler()
+	logic.StopLinkFetcher()
+	logic.StopCacheWarmer()
+	// 正在进行的重放会把剩下的投票写回本地队列
+	logic.StopVoteQueue()
+	// 请求处理完后再把缓冲的浏览量写入 Redis
+	logic.StopViewCounter()
+	// 缓冲的投票写入 MySQL，放在重放之后，重放写入的投票也能保存
+	logic.StopVotePersister()
+	logic.StopCache()
 
 	zap.L().Info("Server exiting")
 }
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"strings"
 	"web-app/controller"
+	"web-app/logic"
 	"web-app/pkg/jwt"
 
 	"github.com/gin-gonic/gin"
@@ -44,3 +45,37 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 		c.Next() // 后续的处理函数可以用过c.Get(controller.ContextUserIDKey)来获取当前请求的用户信息
 	}
 }
+
+// OptionalJWTAuthMiddleware 可选的认证中间件
+// 携带了有效Token时保存当前用户id，用于返回收藏状态等个性化字段；没有或无效时按未登录处理，不拦截请求
+func OptionalJWTAuthMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.Request.Header.Get("Authorization"), " ", 2)
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if mc, err := jwt.ParseToken(parts[1]); err == nil {
+				c.Set(controller.ContextUserIDKey, mc.UserID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// AdminMiddleware 管理员权限中间件，需要放在 JWTAuthMiddleware 之后
+// 管理员由配置文件中的 auth.admin_ids 指定
+func AdminMiddleware() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		uid, _ := c.Get(controller.ContextUserIDKey)
+		userID, ok := uid.(int64)
+		if !ok {
+			controller.ResponseError(c, controller.CodeNeedLogin)
+			c.Abort()
+			return
+		}
+		if !logic.IsAdmin(userID) {
+			controller.ResponseError(c, controller.CodeNoPermission)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
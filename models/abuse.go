@@ -0,0 +1,15 @@
+package models
+
+// ClientInfo 请求方的IP和设备，用于刷票检测
+type ClientInfo struct {
+	IP       string
+	DeviceID string
+}
+
+// AbuseCluster 疑似互相刷票的账号团伙
+type AbuseCluster struct {
+	UserIDs    []string `json:"user_ids"`
+	Usernames  []string `json:"usernames"`
+	Pairs      int      `json:"pairs"`        // 团伙内疑似结团的账号对数
+	MaxCoVotes int64    `json:"max_co_votes"` // 账号对之间最多的共同投票数
+}
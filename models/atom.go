@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// AtomFeed Atom 订阅源，格式参考 RFC 4287
+type AtomFeed struct {
+	XMLName xml.Name     `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string       `xml:"id"`
+	Title   string       `xml:"title"`
+	Updated time.Time    `xml:"updated"`
+	Links   []AtomLink   `xml:"link"`
+	Entries []*AtomEntry `xml:"entry"`
+}
+
+// AtomEntry 订阅源中的一篇帖子
+type AtomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Published time.Time     `xml:"published"`
+	Updated   time.Time     `xml:"updated"`
+	Author    AtomPerson    `xml:"author"`
+	Links     []AtomLink    `xml:"link"`
+	Category  *AtomCategory `xml:"category,omitempty"`
+	Content   *AtomText     `xml:"content,omitempty"`
+}
+
+// AtomLink 链接，rel 为空时表示 alternate
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// AtomPerson 作者
+type AtomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+// AtomCategory 分类，对应帖子所属的社区
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+// AtomText 文本内容，type 为 text 或 html
+type AtomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
@@ -12,4 +12,13 @@ type CommunityDetail struct {
 	Name string `json:"name" db:"community_name"`
 	Introduction string `json:"introduction,omitempty" db:"introduction"`
 	CreateTime time.Time `json:"create_time" db:"create_time"`
-}
\ No newline at end of file
+}
+
+// CommunityRule 社区的发帖规则，没有配置的社区不做限制
+type CommunityRule struct {
+	CommunityID    int64 `json:"community_id" db:"community_id"`
+	AllowCrosspost bool  `json:"allow_crosspost" db:"allow_crosspost"`   // 是否接受从其他社区转发的帖子
+	AllowLink      bool  `json:"allow_link" db:"allow_link"`             // 是否允许发布链接帖子
+	MinAccountDays int   `json:"min_account_days" db:"min_account_days"` // 注册满多少天才能发帖
+	MinKarma       int   `json:"min_karma" db:"min_karma"`               // 声望达到多少才能发帖
+}
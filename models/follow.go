@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// FollowUser 关注列表/粉丝列表中的用户信息
+type FollowUser struct {
+	UserID     int64     `json:"user_id,string" db:"user_id"`
+	Username   string    `json:"username" db:"username"`
+	FollowTime time.Time `json:"follow_time" db:"create_time"`
+}
+
+// ApiFollowList 关注列表/粉丝列表接口结构体
+type ApiFollowList struct {
+	Total int64         `json:"total"` // 关注数/粉丝数
+	List  []*FollowUser `json:"list"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 链接预览的抓取状态
+const (
+	LinkStatusPending int8 = 0 // 待抓取
+	LinkStatusSuccess int8 = 1 // 抓取成功
+	LinkStatusFailed  int8 = 2 // 抓取失败
+)
+
+// LinkPreview 链接的预览信息，由后台抓取网页的 OpenGraph 标签得到
+type LinkPreview struct {
+	URLHash     string     `db:"url_hash" json:"-"`
+	URL         string     `db:"url" json:"url"`
+	Status      int8       `db:"status" json:"-"`
+	Title       string     `db:"title" json:"title"`
+	Description string     `db:"description" json:"description"`
+	Image       string     `db:"image" json:"image,omitempty"`
+	SiteName    string     `db:"site_name" json:"site_name,omitempty"`
+	FailCount   int        `db:"fail_count" json:"-"`
+	FetchTime   *time.Time `db:"fetch_time" json:"-"`
+}
+
+// LinkPost 发布过某个链接的帖子
+type LinkPost struct {
+	PostID        int64     `db:"post_id" json:"post_id"`
+	Title         string    `db:"title" json:"title"`
+	CommunityID   int64     `db:"community_id" json:"community_id"`
+	CommunityName string    `db:"community_name" json:"community_name"`
+	CreateTime    time.Time `db:"create_time" json:"create_time"`
+}
+
+// ApiLinkCheck 检查链接是否发布过的接口结构体
+type ApiLinkCheck struct {
+	URL   string       `json:"url"`            // 规范化后的链接
+	Posts []*LinkPost  `json:"posts"`          // 发布过该链接的帖子
+	Link  *LinkPreview `json:"link,omitempty"` // 已经抓取到的预览信息
+}
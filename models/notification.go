@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// 通知类型，1 预留给评论功能的回复通知，目前没有评论功能
+const (
+	NotifyTypeMention int8 = 2 // 在帖子中被 @
+	NotifyTypeVote    int8 = 3 // 帖子获得的赞成票达到里程碑
+)
+
+// Notification 站内通知
+type Notification struct {
+	ID         int64     `json:"id,string" db:"notification_id"`
+	UserID     int64     `json:"-" db:"user_id"`
+	ActorID    int64     `json:"actor_id,string" db:"actor_id"`
+	Type       int8      `json:"type" db:"type"`
+	PostID     int64     `json:"post_id,string" db:"post_id"`
+	Content    string    `json:"content" db:"content"`
+	IsRead     bool      `json:"is_read" db:"is_read"`
+	CreateTime time.Time `json:"create_time" db:"create_time"`
+}
+
+// NotificationSetting 用户的通知偏好，没有设置过时全部开启
+type NotificationSetting struct {
+	Mention bool `json:"mention" db:"mention"`
+	Vote    bool `json:"vote" db:"vote"`
+}
+
+// ApiNotificationList 通知列表接口结构体
+type ApiNotificationList struct {
+	Unread     int64           `json:"unread"`      // 未读数
+	NextCursor string          `json:"next_cursor"` // 下一页的游标，为空表示没有更多了
+	List       []*Notification `json:"list"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// PostPage 服务端渲染的帖子页面数据
+type PostPage struct {
+	Detail      *ApiPostDetail
+	SiteName    string
+	Path        string // 规范路径 /p/:id/:slug，已经转义
+	URL         string // 规范地址，用于 canonical 和 og:url
+	Description string // 摘要，用于 description 和 og:description
+	FeedURL     string // 作者的订阅源
+	Published   time.Time
+	Updated     time.Time
+}
+
+// SitemapPost 站点地图中的帖子
+type SitemapPost struct {
+	ID         int64     `db:"post_id"`
+	Title      string    `db:"title"`
+	UpdateTime time.Time `db:"update_time"`
+}
+
+// SitemapIndex 站点地图索引，格式参考 https://www.sitemaps.org/protocol.html
+type SitemapIndex struct {
+	XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []SitemapEntry `xml:"sitemap"`
+}
+
+// SitemapEntry 站点地图索引中的一个分页
+type SitemapEntry struct {
+	Loc     string     `xml:"loc"`
+	LastMod *time.Time `xml:"lastmod,omitempty"`
+}
+
+// URLSet 一页站点地图
+type URLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+// SitemapURL 站点地图中的一个地址
+type SitemapURL struct {
+	Loc     string    `xml:"loc"`
+	LastMod time.Time `xml:"lastmod"`
+}
@@ -1,10 +1,13 @@
 package models
 
+import "time"
+
 // 定义请求参数的结构体
 
 const (
 	OrderTime  = "time"
 	OrderScore = "score"
+	OrderViews = "views"
 )
 
 // ParamsSignUp 注册请求参数
@@ -39,3 +42,76 @@ type ParamsCommunityPostList struct {
 	*ParamsPostList
 	
 }
+
+// ParamsUpdatePost 编辑帖子参数，草稿自动保存时标题和内容可以为空
+type ParamsUpdatePost struct {
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	PublishAt *time.Time `json:"publish_at"` // 修改定时发布的时间，只对定时发布的帖子有效
+}
+
+// ParamsPublishPost 发布草稿参数
+type ParamsPublishPost struct {
+	PublishAt *time.Time `json:"publish_at"` // 定时发布的时间，不传表示立即发布
+}
+
+// ParamsNotificationList 通知列表的query string参数
+type ParamsNotificationList struct {
+	Cursor int64 `json:"cursor" form:"cursor"` // 上一页最后一条通知的id，第一页不传
+	Size   int64 `json:"size" form:"size"`
+}
+
+// ParamsSavePost 收藏帖子参数
+type ParamsSavePost struct {
+	Collection string `json:"collection" binding:"max=64"` // 收藏夹名称，不传表示默认收藏夹
+}
+
+// ParamsSavedList 收藏列表的query string参数
+type ParamsSavedList struct {
+	Collection *string `json:"collection" form:"collection"` // 只看某个收藏夹，不传表示全部，传空字符串表示默认收藏夹
+	Page       int64   `json:"page" form:"page"`
+	Size       int64   `json:"size" form:"size"`
+}
+
+// ParamsVoteLogList 投票记录的query string参数
+type ParamsVoteLogList struct {
+	Direction string `json:"direction" form:"direction" binding:"omitempty,oneof=up down cancel"` // 只看某种投票，不传表示全部
+	Cursor    int64  `json:"cursor" form:"cursor"`                                                // 上一页最后一条记录的id，第一页不传
+	Size      int64  `json:"size" form:"size"`
+}
+
+// ParamsCreatePost 发帖参数，帖子之外可以附带投票，定时发布的时间是帖子的 publish_at
+type ParamsCreatePost struct {
+	Post
+	Draft bool        `json:"draft"`          // 只保存为草稿，草稿不定时，标题和内容可以为空
+	Poll  *ParamsPoll `json:"poll,omitempty"` // 发帖时附带的投票，可以为空
+}
+
+// ParamsPoll 发帖时附带的投票参数
+type ParamsPoll struct {
+	Options   []string   `json:"options" binding:"required,min=2,max=10,dive,required,max=100"` // 2~10个选项
+	Multiple  bool       `json:"multiple"`                                                     // 是否多选
+	CloseTime *time.Time `json:"close_time"`                                                   // 截止时间，不传表示不截止
+}
+
+// ParamsPollVote 参与投票参数
+type ParamsPollVote struct {
+	Options []int `json:"options" binding:"required,min=1,max=10,dive,min=0,max=9"` // 选项的序号，单选时只能传一个
+}
+
+// ParamsLinkCheck 检查链接是否发布过的query string参数
+type ParamsLinkCheck struct {
+	URL string `json:"url" form:"url" binding:"required,max=2048"`
+}
+
+// ParamsCrosspost 转发帖子参数
+type ParamsCrosspost struct {
+	CommunityID int64  `json:"community_id" binding:"required"` // 转发到的社区
+	Title       string `json:"title" binding:"max=128"`         // 转发时使用的标题，不传表示使用原帖的标题
+}
+
+// ParamsVerify Redis 一致性检查的query string参数
+type ParamsVerify struct {
+	Fix       bool `json:"fix" form:"fix"`               // 是否修复发现的问题
+	BatchSize int  `json:"batch_size" form:"batch_size"` // 每批检查的帖子数
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Poll 帖子附带的投票
+type Poll struct {
+	PostID    int64      `json:"post_id,string" db:"post_id"`
+	Multiple  bool       `json:"multiple" db:"multiple"`     // 是否多选
+	CloseTime *time.Time `json:"close_time" db:"close_time"` // 截止时间，为空表示不截止
+	Closed    bool       `json:"closed" db:"closed"`         // 已截止，最终结果已经保存到 MySQL
+
+	Options        []*PollOption `json:"options" db:"-"`
+	ResultsVisible bool          `json:"results_visible" db:"-"`        // 投过票或者已截止才能看到结果
+	TotalVoters    *int64        `json:"total_voters,omitempty" db:"-"` // 参与人数，结果不可见时不返回
+	MyChoices      []int         `json:"my_choices,omitempty" db:"-"`   // 当前用户选择的选项
+}
+
+// PollOption 投票选项
+type PollOption struct {
+	Index int    `json:"index" db:"idx"`
+	Text  string `json:"text" db:"text"`
+	Votes *int64 `json:"votes,omitempty" db:"votes"` // 票数，结果不可见时不返回
+}
+
+// IsClosed 是否已经截止
+func (p *Poll) IsClosed(now time.Time) bool {
+	return p.Closed || (p.CloseTime != nil && !now.Before(*p.CloseTime))
+}
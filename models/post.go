@@ -2,21 +2,54 @@ package models
 
 import "time"
 
+// 帖子状态
+const (
+	PostStatusPending   int32 = 0 // 待审核（命中内容过滤规则）
+	PostStatusNormal    int32 = 1 // 正常
+	PostStatusDraft     int32 = 2 // 草稿，只有作者自己可见
+	PostStatusScheduled int32 = 3 // 定时发布，到发布时间前只有作者自己可见
+	PostStatusRejected  int32 = 4 // 审核未通过，只有作者自己可见
+)
+
 // 内存对齐概念
 type Post struct {
-	ID          int64     `db:"post_id" json:"id"`
-	AuthorID    int64     `db:"author_id" json:"author_id"`
-	CommunityID int64     `db:"community_id" json:"community_id" binding:"required"`
-	Status      int32     `db:"status" json:"status"`
-	Title       string    `db:"title" json:"title" binding:"required"`
-	Content     string    `db:"content" json:"content" binding:"required"`
-	CreateTime  time.Time `db:"create_time" json:"create_time"`
+	ID          int64      `db:"post_id" json:"id"`
+	AuthorID    int64      `db:"author_id" json:"author_id"`
+	CommunityID int64      `db:"community_id" json:"community_id" binding:"required"`
+	Status      int32      `db:"status" json:"status"`
+	Title       string     `db:"title" json:"title"`                                    // 发布时不能为空，草稿可以为空
+	Content     string     `db:"content" json:"content"`                                // 发布时只有链接帖子的内容可以为空，草稿可以为空
+	URL         string     `db:"url" json:"url,omitempty" binding:"omitempty,max=2048"` // 链接帖子的地址
+	URLHash     string     `db:"url_hash" json:"-"`
+	CrosspostOf int64      `db:"crosspost_of" json:"crosspost_of,omitempty"` // 转发的原帖id，为0表示不是转发
+	CreateTime  time.Time  `db:"create_time" json:"create_time"`
+	UpdateTime  *time.Time `db:"update_time" json:"update_time,omitempty"`
+	PublishTime *time.Time `db:"publish_time" json:"publish_at,omitempty"` // 定时发布的时间，为空表示立即发布
 }
 
 // ApiPostDetail 帖子详情接口结构体
 type ApiPostDetail struct {
 	AuthorName       string              `json:"author_name"` // 作者用户名
 	VoteNum            int64               `json:"vote_num"`       // 投票数
+	UpVotes          int64               `json:"up_votes"`          // 赞成票数
+	DownVotes        int64               `json:"down_votes"`        // 反对票数
+	ViewCount        int64               `json:"view_count"`        // 浏览量
+	UniqueVisitors   int64               `json:"unique_visitors"`   // 独立访客数（估算值）
+	MyVote           *int8               `json:"my_vote,omitempty"` // 当前用户的投票 1:赞成 -1:反对 0:未投票，未登录时不返回
+	Saved            *bool               `json:"saved,omitempty"` // 当前用户是否收藏，未登录时不返回
+	Link             *LinkPreview        `json:"link,omitempty"` // 链接帖子的预览信息，抓取成功后才返回
+	CrosspostParent  *ApiPostDetail      `json:"crosspost_parent,omitempty"` // 转发帖子的原帖，展示原帖的内容和作者
 	*Post                                // 嵌入帖子结构体
 	*CommunityDetail `json:"community"` // 嵌入社区信息
 }
+
+// PostRank 重建 Redis 排行榜需要的帖子字段
+type PostRank struct {
+	ID          int64      `db:"post_id"`
+	AuthorID    int64      `db:"author_id"`
+	CommunityID int64      `db:"community_id"`
+	Status      int32      `db:"status"`
+	CreateTime  time.Time  `db:"create_time"`
+	PublishTime *time.Time `db:"publish_time"`
+	ViewCount   int64      `db:"view_count"`
+}
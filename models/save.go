@@ -0,0 +1,13 @@
+package models
+
+// SavedCollection 收藏夹及其中的帖子数
+type SavedCollection struct {
+	Name  string `json:"name" db:"collection"`
+	Count int64  `json:"count" db:"count"`
+}
+
+// ApiSavedList 收藏列表接口结构体
+type ApiSavedList struct {
+	Total int64            `json:"total"`
+	List  []*ApiPostDetail `json:"list"`
+}
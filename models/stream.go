@@ -0,0 +1,15 @@
+package models
+
+// 实时事件类型
+const (
+	StreamEventVote         = "vote"         // 帖子投票数变化
+	StreamEventPost         = "post"         // 社区有新帖子
+	StreamEventNotification = "notification" // 收到新通知
+)
+
+// StreamEvent 推送给客户端的实时事件
+type StreamEvent struct {
+	Topic string      `json:"topic"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// VoteLog 投票记录，每次投票追加一条
+type VoteLog struct {
+	ID           int64     `json:"id,string" db:"id"`
+	PostID       int64     `json:"post_id,string" db:"post_id"`
+	UserID       int64     `json:"user_id,string" db:"user_id"`
+	Direction    int8      `json:"direction" db:"direction"`         // 本次投票 1:赞成 -1:反对 0:取消
+	OldDirection int8      `json:"old_direction" db:"old_direction"` // 投票前的状态
+	Weight       float64   `json:"weight" db:"weight"`               // 投票权重，低于 1 表示被刷票检测降权
+	Title        string    `json:"title,omitempty" db:"title"`       // 帖子标题，我的投票中返回
+	Username     string    `json:"username,omitempty" db:"username"` // 投票人，管理员查询时返回
+	CreateTime   time.Time `json:"create_time" db:"create_time"`
+}
+
+// ApiVoteLogList 投票记录接口结构体
+type ApiVoteLogList struct {
+	NextCursor string     `json:"next_cursor"` // 下一页的游标，为空表示没有更多了
+	List       []*VoteLog `json:"list"`
+}
+
+// PostVote 用户对帖子当前的投票，由投票接口写入，用于 Redis 数据丢失后重建排行榜
+type PostVote struct {
+	PostID    int64   `db:"post_id"`
+	UserID    int64   `db:"user_id"`
+	Direction int8    `db:"direction"` // 1:赞成 -1:反对 0:已取消
+	Weight    float64 `db:"weight"`
+}
@@ -0,0 +1,57 @@
+package bloom
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Params 布隆过滤器的参数：M 位数组的长度，K 哈希函数的个数
+// 位数组本身保存在 Redis 的 bitmap 中，这里只负责计算参数和每个元素对应的位置
+type Params struct {
+	M uint64
+	K uint64
+}
+
+// Optimal 根据预计的元素个数 n 和期望的误判率 p 计算最优的 M 和 K
+//
+//	m = -n·ln(p) / (ln2)²
+//	k = m/n · ln2
+func Optimal(n uint64, p float64) Params {
+	if n == 0 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	return Params{M: uint64(m), K: uint64(k)}
+}
+
+// Locations 元素在位数组中的 K 个位置
+// 使用双重哈希 g_i(x) = h1(x) + i·h2(x)，只需要计算两次哈希
+func (p Params) Locations(data []byte) []uint64 {
+	h1 := fnv.New64a()
+	h1.Write(data)
+	a := h1.Sum64()
+	h2 := fnv.New64()
+	h2.Write(data)
+	b := h2.Sum64() | 1 // 保证是奇数，避免 h2 为 0 时所有位置相同
+
+	locations := make([]uint64, p.K)
+	for i := uint64(0); i < p.K; i++ {
+		locations[i] = (a + i*b) % p.M
+	}
+	return locations
+}
+
+// EstimateFalsePositive 根据已经置为 1 的位数估算当前的误判率 (X/M)^K
+func (p Params) EstimateFalsePositive(setBits uint64) float64 {
+	if p.M == 0 {
+		return 0
+	}
+	return math.Pow(float64(setBits)/float64(p.M), float64(p.K))
+}
@@ -0,0 +1,129 @@
+package bloom
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestOptimal(t *testing.T) {
+	tests := []struct {
+		name string
+		n    uint64
+		p    float64
+		want Params
+	}{
+		{name: "1k items 1%", n: 1000, p: 0.01, want: Params{M: 9586, K: 7}},
+		{name: "1m items 1%", n: 1000000, p: 0.01, want: Params{M: 9585059, K: 7}},
+		{name: "10k items 0.1%", n: 10000, p: 0.001, want: Params{M: 143776, K: 10}},
+		{name: "zero items treated as one", n: 0, p: 0.01, want: Params{M: 10, K: 7}},
+		{name: "invalid rate defaults to 1%", n: 1000, p: 0, want: Params{M: 9586, K: 7}},
+		{name: "rate of one defaults to 1%", n: 1000, p: 1, want: Params{M: 9586, K: 7}},
+		{name: "at least one hash", n: 1, p: 0.5, want: Params{M: 2, K: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Optimal(tt.n, tt.p); got != tt.want {
+				t.Errorf("Optimal(%d, %v) = %+v, want %+v", tt.n, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocations(t *testing.T) {
+	p := Optimal(1000, 0.01)
+	for _, data := range []string{"", "1", "1234567890123456789", "中文"} {
+		locs := p.Locations([]byte(data))
+		if uint64(len(locs)) != p.K {
+			t.Errorf("Locations(%q) returned %d locations, want %d", data, len(locs), p.K)
+		}
+		for _, loc := range locs {
+			if loc >= p.M {
+				t.Errorf("Locations(%q) = %d, out of range [0, %d)", data, loc, p.M)
+			}
+		}
+		// 位数组保存在 Redis 中，所有实例必须算出相同的位置
+		again := p.Locations([]byte(data))
+		for i := range locs {
+			if locs[i] != again[i] {
+				t.Fatalf("Locations(%q) is not deterministic", data)
+			}
+		}
+	}
+}
+
+// bitset 用内存中的位数组模拟 Redis 的 bitmap
+type bitset map[uint64]bool
+
+func (b bitset) add(p Params, id int) {
+	for _, loc := range p.Locations([]byte(strconv.Itoa(id))) {
+		b[loc] = true
+	}
+}
+
+func (b bitset) mayContain(p Params, id int) bool {
+	for _, loc := range p.Locations([]byte(strconv.Itoa(id))) {
+		if !b[loc] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFalsePositiveRate(t *testing.T) {
+	tests := []struct {
+		n int
+		p float64
+	}{
+		{n: 10000, p: 0.01},
+		{n: 10000, p: 0.001},
+	}
+	for _, tt := range tests {
+		params := Optimal(uint64(tt.n), tt.p)
+		set := make(bitset)
+		for id := 1; id <= tt.n; id++ {
+			set.add(params, id)
+		}
+		for id := 1; id <= tt.n; id++ {
+			if !set.mayContain(params, id) {
+				t.Fatalf("n=%d p=%v: added id %d reported as missing", tt.n, tt.p, id)
+			}
+		}
+
+		const trials = 100000
+		falsePositives := 0
+		for id := tt.n + 1; id <= tt.n+trials; id++ {
+			if set.mayContain(params, id) {
+				falsePositives++
+			}
+		}
+		// 实际误判率允许在期望值的两倍以内
+		rate := float64(falsePositives) / trials
+		if rate > 2*tt.p {
+			t.Errorf("n=%d p=%v: false positive rate = %v", tt.n, tt.p, rate)
+		}
+
+		estimated := params.EstimateFalsePositive(uint64(len(set)))
+		if math.Abs(estimated-tt.p) > tt.p {
+			t.Errorf("n=%d p=%v: EstimateFalsePositive() = %v", tt.n, tt.p, estimated)
+		}
+	}
+}
+
+func TestEstimateFalsePositive(t *testing.T) {
+	tests := []struct {
+		params  Params
+		setBits uint64
+		want    float64
+	}{
+		{Params{M: 100, K: 2}, 0, 0},
+		{Params{M: 100, K: 2}, 50, 0.25},
+		{Params{M: 100, K: 3}, 100, 1},
+		{Params{M: 0, K: 3}, 10, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.params.EstimateFalsePositive(tt.setBits); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%+v.EstimateFalsePositive(%d) = %v, want %v", tt.params, tt.setBits, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen 熔断器打开，请求被直接拒绝
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State 熔断器的状态
+type State int
+
+const (
+	StateClosed   State = iota // 正常，所有请求都放行
+	StateOpen                  // 连续失败次数达到阈值，拒绝所有请求
+	StateHalfOpen              // 打开一段时间后放行一个探测请求，成功则关闭，失败则重新打开
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker 按连续失败次数熔断，并发安全
+type Breaker struct {
+	threshold   int
+	openTimeout time.Duration
+	onChange    func(from, to State)
+
+	mu       sync.Mutex
+	state    State
+	failures int       // 连续失败次数
+	openedAt time.Time // 最近一次打开的时间
+	probing  bool      // 半开状态下是否已经放行了探测请求
+
+	opens    int64 // 打开的次数
+	rejected int64 // 被拒绝的请求数
+}
+
+// Stats 熔断器的当前状态
+type Stats struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"opened_at,omitempty"`
+	Opens    int64     `json:"opens"`
+	Rejected int64     `json:"rejected"`
+}
+
+// New threshold 连续失败多少次后打开，openTimeout 打开后多久放行探测请求
+// onChange 在状态变化时调用（不持有锁），可以为 nil
+func New(threshold int, openTimeout time.Duration, onChange func(from, to State)) *Breaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if openTimeout <= 0 {
+		openTimeout = 10 * time.Second
+	}
+	return &Breaker{threshold: threshold, openTimeout: openTimeout, onChange: onChange}
+}
+
+// Allow 请求是否可以执行，返回 nil 时执行完必须调用 Done 报告结果
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	var from State
+	changed := false
+	defer func() {
+		b.mu.Unlock()
+		if changed {
+			b.notify(from, StateHalfOpen)
+		}
+	}()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			b.rejected++
+			return ErrOpen
+		}
+		from, changed = b.state, true
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			b.rejected++
+			return ErrOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Done 报告请求的结果，failed 表示依赖不可用（业务上的错误不算失败）
+func (b *Breaker) Done(failed bool) {
+	b.mu.Lock()
+	from, to := b.state, b.state
+	if failed {
+		b.failures++
+		if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.threshold) {
+			to = StateOpen
+			b.openedAt = time.Now()
+			b.opens++
+		}
+		b.probing = false
+	} else {
+		b.failures = 0
+		b.probing = false
+		to = StateClosed
+	}
+	b.state = to
+	b.mu.Unlock()
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+// State 当前状态，打开超时之后还没有探测请求时仍然是打开状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Stats 当前状态和统计
+func (b *Breaker) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return Stats{
+		State:    b.state.String(),
+		Failures: b.failures,
+		OpenedAt: b.openedAt,
+		Opens:    b.opens,
+		Rejected: b.rejected,
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
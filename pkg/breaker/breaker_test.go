@@ -0,0 +1,161 @@
+package breaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// step 对熔断器的一次操作和期望的结果
+type step struct {
+	op      string // allow：请求一次；ok/fail：报告结果；wait：等待打开超时
+	wantErr error  // allow 的返回值
+	want    State  // 操作之后的状态
+}
+
+func TestBreaker(t *testing.T) {
+	const openTimeout = 50 * time.Millisecond
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []step
+		changes   []string // 状态变化的记录，from->to
+	}{
+		{
+			name:      "failures below threshold",
+			threshold: 3,
+			steps: []step{
+				{op: "allow", want: StateClosed},
+				{op: "fail", want: StateClosed},
+				{op: "fail", want: StateClosed},
+				{op: "ok", want: StateClosed}, // 成功后重新计数
+				{op: "fail", want: StateClosed},
+				{op: "fail", want: StateClosed},
+			},
+		},
+		{
+			name:      "opens after consecutive failures",
+			threshold: 2,
+			steps: []step{
+				{op: "fail", want: StateClosed},
+				{op: "fail", want: StateOpen},
+				{op: "allow", wantErr: ErrOpen, want: StateOpen},
+			},
+			changes: []string{"closed->open"},
+		},
+		{
+			name:      "probe succeeds",
+			threshold: 1,
+			steps: []step{
+				{op: "fail", want: StateOpen},
+				{op: "wait", want: StateOpen}, // 没有请求时不会自动变成半开
+				{op: "allow", want: StateHalfOpen},
+				{op: "allow", wantErr: ErrOpen, want: StateHalfOpen}, // 同一时间只放行一个探测请求
+				{op: "ok", want: StateClosed},
+				{op: "allow", want: StateClosed},
+			},
+			changes: []string{"closed->open", "open->half_open", "half_open->closed"},
+		},
+		{
+			name:      "probe fails",
+			threshold: 1,
+			steps: []step{
+				{op: "fail", want: StateOpen},
+				{op: "wait", want: StateOpen},
+				{op: "allow", want: StateHalfOpen},
+				{op: "fail", want: StateOpen},
+				{op: "allow", wantErr: ErrOpen, want: StateOpen}, // 重新计算打开时间
+				{op: "wait", want: StateOpen},
+				{op: "allow", want: StateHalfOpen},
+			},
+			changes: []string{"closed->open", "open->half_open", "half_open->open", "open->half_open"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []string
+			b := New(tt.threshold, openTimeout, func(from, to State) {
+				changes = append(changes, from.String()+"->"+to.String())
+			})
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if err := b.Allow(); err != s.wantErr {
+						t.Fatalf("step %d: Allow() error = %v, want %v", i, err, s.wantErr)
+					}
+				case "ok":
+					b.Done(false)
+				case "fail":
+					b.Done(true)
+				case "wait":
+					time.Sleep(openTimeout + 10*time.Millisecond)
+				}
+				if got := b.State(); got != s.want {
+					t.Fatalf("step %d (%s): State() = %v, want %v", i, s.op, got, s.want)
+				}
+			}
+			if len(changes) != len(tt.changes) {
+				t.Fatalf("changes = %v, want %v", changes, tt.changes)
+			}
+			for i := range changes {
+				if changes[i] != tt.changes[i] {
+					t.Fatalf("changes = %v, want %v", changes, tt.changes)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	b := New(0, 0, nil)
+	if b.threshold != 5 || b.openTimeout != 10*time.Second {
+		t.Errorf("New(0, 0) = threshold %d, openTimeout %v, want 5, 10s", b.threshold, b.openTimeout)
+	}
+	// onChange 为 nil 时状态变化不会出错
+	for i := 0; i < 5; i++ {
+		b.Done(true)
+	}
+	if b.State() != StateOpen {
+		t.Errorf("State() = %v, want open", b.State())
+	}
+}
+
+func TestStats(t *testing.T) {
+	b := New(1, time.Hour, nil)
+	b.Done(true)
+	for i := 0; i < 3; i++ {
+		_ = b.Allow()
+	}
+	s := b.Stats()
+	if s.State != "open" || s.Failures != 1 || s.Opens != 1 || s.Rejected != 3 || s.OpenedAt.IsZero() {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+func TestHalfOpenSingleProbe(t *testing.T) {
+	b := New(1, time.Millisecond, nil)
+	b.Done(true)
+	time.Sleep(5 * time.Millisecond)
+
+	// 打开超时后并发的请求中只有一个被放行
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Allow() == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Errorf("allowed %d probes, want 1", allowed)
+	}
+}
@@ -0,0 +1,501 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// 空值的占位，查询结果不存在时缓存这个值，防止不存在的 id 每次都打到数据库
+// 正常的 JSON 不会以 '!' 开头
+const negativeValue = "!nil"
+
+// Item 写入缓存的一项
+type Item struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration
+}
+
+// Store 缓存的存储，Redis 的实现在 dao/redis
+type Store interface {
+	// MGet 按 keys 的顺序返回，没有缓存的位置为 nil
+	MGet(keys []string) ([][]byte, error)
+	MSet(items []Item) error
+	Del(keys ...string) error
+	// Lock 获取分布式锁，多个实例同时回源时只有一个实例查询数据库
+	Lock(key string, ttl time.Duration) (bool, error)
+	Unlock(key string) error
+}
+
+// Recorder 记录缓存的命中情况
+// RecordLocalHit 是一级缓存（进程内）的命中数，RecordHit/RecordMiss 是二级缓存（Redis）的命中数和未命中数
+type Recorder interface {
+	RecordLocalHit(n int)
+	RecordHit(n int)
+	RecordMiss(n int)
+	RecordError()
+}
+
+// Options Loader 的配置，Name、Store、Key 和 Load 必须设置
+type Options[K comparable, V any] struct {
+	Name  string // 缓存名称，用于日志和分布式锁的 key
+	Store Store
+	Key   func(K) string
+	// Load 缓存未命中时回源查询单个数据
+	Load func(K) (V, error)
+	// LoadBatch 批量回源，返回结果中没有的 key 视为不存在；不设置时逐个调用 Load
+	LoadBatch func([]K) (map[K]V, error)
+	// Encode 写入缓存前对数据的处理（比如去掉密码等敏感字段），可以为 nil
+	Encode func(V) V
+
+	TTL time.Duration
+	// Jitter 过期时间的随机浮动比例，比如 0.1 表示 ±10%，避免同一批数据同时过期
+	Jitter float64
+	// NotFound Load 返回该错误时缓存空值 NegativeTTL 时间，命中空值时也返回该错误
+	NotFound    error
+	NegativeTTL time.Duration
+
+	// Lock 是否在回源前获取分布式锁。同一个实例内的并发请求已经由 singleflight 合并，
+	// 分布式锁用于合并多个实例的请求；没有抢到锁时等待 LockWait 后重新读缓存，仍未命中则直接回源
+	Lock     bool
+	LockTTL  time.Duration
+	LockWait time.Duration
+
+	Recorder Recorder
+
+	// Bypass 返回 true 时不读写缓存（包括一级缓存），直接回源，比如 Redis 不可用时
+	// 这期间收不到其他实例的失效通知，一级缓存中的数据也可能是旧的
+	Bypass func() bool
+}
+
+// Loader 旁路缓存（cache-aside）：先读缓存，未命中时回源并写回缓存
+// 开启一级缓存后先读进程内的 LRU，再读 Redis
+type Loader[K comparable, V any] struct {
+	opts  Options[K, V]
+	group singleflight.Group
+	local atomic.Pointer[LRU]
+}
+
+// New 创建 Loader
+func New[K comparable, V any](opts Options[K, V]) *Loader[K, V] {
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = 10 * time.Second
+	}
+	if opts.LockWait <= 0 {
+		opts.LockWait = 50 * time.Millisecond
+	}
+	if opts.NegativeTTL <= 0 {
+		opts.NegativeTTL = time.Minute
+	}
+	return &Loader[K, V]{opts: opts}
+}
+
+// EnableLocal 开启进程内的一级缓存，size 为 0 时关闭
+// 一级缓存中的数据会被多个请求共享，调用方不能修改返回的数据
+func (l *Loader[K, V]) EnableLocal(size int, ttl time.Duration) {
+	if size <= 0 || ttl <= 0 {
+		l.local.Store(nil)
+		return
+	}
+	l.local.Store(NewLRU(size, ttl))
+}
+
+// Invalidate 只删除本实例一级缓存中的数据，keys 是缓存的 key
+// 其他实例更新数据后通过 Pub/Sub 通知时调用
+func (l *Loader[K, V]) Invalidate(keys ...string) {
+	if local := l.local.Load(); local != nil {
+		local.Delete(keys...)
+	}
+}
+
+// PurgeLocal 清空本实例的一级缓存
+func (l *Loader[K, V]) PurgeLocal() {
+	if local := l.local.Load(); local != nil {
+		local.Purge()
+	}
+}
+
+// LocalLen 一级缓存中的数量
+func (l *Loader[K, V]) LocalLen() int {
+	if local := l.local.Load(); local != nil {
+		return local.Len()
+	}
+	return 0
+}
+
+func (l *Loader[K, V]) getLocal(key string) (V, bool) {
+	if local := l.local.Load(); local != nil {
+		if v, ok := local.Get(key); ok {
+			return v.(V), true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+func (l *Loader[K, V]) setLocal(key string, v V) {
+	if local := l.local.Load(); local != nil {
+		local.Set(key, v, 0)
+	}
+}
+
+// Get 获取单个数据
+func (l *Loader[K, V]) Get(k K) (V, error) {
+	key := l.opts.Key(k)
+	if l.bypass() {
+		res, err, _ := l.group.Do("bypass:"+key, func() (interface{}, error) {
+			return l.opts.Load(k)
+		})
+		if err != nil {
+			var zero V
+			return zero, err
+		}
+		return res.(V), nil
+	}
+	if v, ok := l.getLocal(key); ok {
+		l.recordLocalHit(1)
+		return v, nil
+	}
+	if v, ok, err := l.get(key); ok {
+		if err == nil {
+			l.setLocal(key, v)
+		}
+		return v, err
+	}
+
+	// 同一个实例内同一个 key 的并发请求只回源一次
+	res, err, _ := l.group.Do(key, func() (interface{}, error) {
+		return l.load(k, key)
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return res.(V), nil
+}
+
+// get 读缓存，ok 表示命中（包括命中空值），读缓存出错按未命中处理
+func (l *Loader[K, V]) get(key string) (v V, ok bool, err error) {
+	values, err := l.opts.Store.MGet([]string{key})
+	if err != nil {
+		l.recordError()
+		zap.L().Error("cache get failed", zap.String("cache", l.opts.Name), zap.String("key", key), zap.Error(err))
+		return v, false, nil
+	}
+	v, ok, err = l.decode(key, values[0])
+	if ok {
+		l.recordHit(1)
+	} else {
+		l.recordMiss(1)
+	}
+	return v, ok, err
+}
+
+func (l *Loader[K, V]) load(k K, key string) (V, error) {
+	if l.opts.Lock {
+		lockKey := l.opts.Name + ":" + fmt.Sprint(k)
+		locked, err := l.opts.Store.Lock(lockKey, l.opts.LockTTL)
+		if err != nil {
+			zap.L().Error("cache lock failed", zap.String("cache", l.opts.Name), zap.String("key", key), zap.Error(err))
+		}
+		if locked {
+			defer func() {
+				if err := l.opts.Store.Unlock(lockKey); err != nil {
+					zap.L().Error("cache unlock failed", zap.String("cache", l.opts.Name), zap.String("key", key), zap.Error(err))
+				}
+			}()
+		} else {
+			// 其他实例正在回源，稍等之后再读一次缓存
+			time.Sleep(l.opts.LockWait)
+		}
+		// 抢到锁之后同样需要再检查一次，锁可能是在上一个实例写完缓存之后才拿到的
+		values, err := l.opts.Store.MGet([]string{key})
+		if err == nil {
+			if v, ok, err := l.decode(key, values[0]); ok {
+				if err == nil {
+					l.setLocal(key, v)
+				}
+				return v, err
+			}
+		}
+	}
+
+	v, err := l.opts.Load(k)
+	if err != nil {
+		if l.opts.NotFound != nil && errors.Is(err, l.opts.NotFound) {
+			l.setNegative([]string{key})
+		}
+		return v, err
+	}
+	l.set(map[string]V{key: v})
+	l.setLocal(key, v)
+	return v, nil
+}
+
+// GetMany 批量获取，返回结果中没有的 key 表示不存在
+// 缓存未命中的部分一次批量回源，相同的一批未命中 key 在同一个实例内只回源一次
+func (l *Loader[K, V]) GetMany(ks []K) (map[K]V, error) {
+	result := make(map[K]V, len(ks))
+	if len(ks) == 0 {
+		return result, nil
+	}
+	if l.bypass() {
+		return l.loadDirect(ks)
+	}
+
+	// 去重，一级缓存命中的不再读 Redis
+	uniq := make([]K, 0, len(ks))
+	keys := make([]string, 0, len(ks))
+	seen := make(map[K]bool, len(ks))
+	for _, k := range ks {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		key := l.opts.Key(k)
+		if v, ok := l.getLocal(key); ok {
+			result[k] = v
+			continue
+		}
+		uniq = append(uniq, k)
+		keys = append(keys, key)
+	}
+	l.recordLocalHit(len(result))
+	if len(uniq) == 0 {
+		return result, nil
+	}
+
+	missed := uniq
+	values, err := l.opts.Store.MGet(keys)
+	if err != nil {
+		l.recordError()
+		zap.L().Error("cache mget failed", zap.String("cache", l.opts.Name), zap.Error(err))
+	} else {
+		missed = make([]K, 0)
+		for i, k := range uniq {
+			v, ok, err := l.decode(keys[i], values[i])
+			switch {
+			case !ok:
+				missed = append(missed, k)
+			case err == nil:
+				result[k] = v
+				l.setLocal(keys[i], v)
+			}
+		}
+		l.recordHit(len(uniq) - len(missed))
+		l.recordMiss(len(missed))
+	}
+	if len(missed) == 0 {
+		return result, nil
+	}
+
+	missedKeys := make([]string, len(missed))
+	for i, k := range missed {
+		missedKeys[i] = l.opts.Key(k)
+	}
+	sort.Strings(missedKeys)
+	res, err, _ := l.group.Do("batch:"+strings.Join(missedKeys, ","), func() (interface{}, error) {
+		return l.loadBatch(missed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range res.(map[K]V) {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func (l *Loader[K, V]) bypass() bool {
+	return l.opts.Bypass != nil && l.opts.Bypass()
+}
+
+// loadDirect 不经过缓存直接批量回源
+func (l *Loader[K, V]) loadDirect(ks []K) (map[K]V, error) {
+	if l.opts.LoadBatch != nil {
+		return l.opts.LoadBatch(ks)
+	}
+	loaded := make(map[K]V, len(ks))
+	for _, k := range ks {
+		v, err := l.opts.Load(k)
+		if err != nil {
+			if l.opts.NotFound != nil && errors.Is(err, l.opts.NotFound) {
+				continue
+			}
+			return nil, err
+		}
+		loaded[k] = v
+	}
+	return loaded, nil
+}
+
+func (l *Loader[K, V]) loadBatch(ks []K) (map[K]V, error) {
+	if l.opts.LoadBatch == nil {
+		loaded := make(map[K]V, len(ks))
+		for _, k := range ks {
+			v, err := l.load(k, l.opts.Key(k))
+			if err != nil {
+				if l.opts.NotFound != nil && errors.Is(err, l.opts.NotFound) {
+					continue
+				}
+				return nil, err
+			}
+			loaded[k] = v
+		}
+		return loaded, nil
+	}
+
+	loaded, err := l.opts.LoadBatch(ks)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]V, len(loaded))
+	notFound := make([]string, 0)
+	for _, k := range ks {
+		if v, ok := loaded[k]; ok {
+			found[l.opts.Key(k)] = v
+		} else {
+			notFound = append(notFound, l.opts.Key(k))
+		}
+	}
+	l.set(found)
+	for key, v := range found {
+		l.setLocal(key, v)
+	}
+	if l.opts.NotFound != nil {
+		l.setNegative(notFound)
+	}
+	return loaded, nil
+}
+
+// Set 主动写入缓存
+func (l *Loader[K, V]) Set(k K, v V) {
+	l.SetMany(map[K]V{k: v})
+}
+
+// SetMany 批量写入缓存，用于缓存预热
+func (l *Loader[K, V]) SetMany(values map[K]V) {
+	byKey := make(map[string]V, len(values))
+	for k, v := range values {
+		key := l.opts.Key(k)
+		byKey[key] = v
+		l.setLocal(key, v)
+	}
+	l.set(byKey)
+}
+
+// Delete 删除缓存，数据更新后调用
+// 只删除本实例的一级缓存，其他实例的一级缓存需要由 Store.Del 负责通知
+func (l *Loader[K, V]) Delete(ks ...K) error {
+	if len(ks) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ks))
+	for i, k := range ks {
+		keys[i] = l.opts.Key(k)
+	}
+	l.Invalidate(keys...)
+	return l.opts.Store.Del(keys...)
+}
+
+func (l *Loader[K, V]) decode(key string, data []byte) (v V, ok bool, err error) {
+	if data == nil {
+		return v, false, nil
+	}
+	if string(data) == negativeValue {
+		return v, true, l.opts.NotFound
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		// 数据格式不对（比如结构体变了）按未命中处理，回源后会覆盖
+		l.recordError()
+		zap.L().Error("cache unmarshal failed", zap.String("cache", l.opts.Name), zap.String("key", key), zap.Error(err))
+		return v, false, nil
+	}
+	return v, true, nil
+}
+
+func (l *Loader[K, V]) set(values map[string]V) {
+	if len(values) == 0 {
+		return
+	}
+	items := make([]Item, 0, len(values))
+	for key, v := range values {
+		if l.opts.Encode != nil {
+			v = l.opts.Encode(v)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			zap.L().Error("cache marshal failed", zap.String("cache", l.opts.Name), zap.String("key", key), zap.Error(err))
+			continue
+		}
+		items = append(items, Item{Key: key, Value: data, TTL: l.ttl(l.opts.TTL)})
+	}
+	l.write(items)
+}
+
+func (l *Loader[K, V]) setNegative(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	items := make([]Item, len(keys))
+	for i, key := range keys {
+		items[i] = Item{Key: key, Value: []byte(negativeValue), TTL: l.ttl(l.opts.NegativeTTL)}
+	}
+	l.write(items)
+}
+
+// write 写缓存失败不影响返回数据，只记录日志
+func (l *Loader[K, V]) write(items []Item) {
+	if len(items) == 0 {
+		return
+	}
+	if err := l.opts.Store.MSet(items); err != nil {
+		l.recordError()
+		zap.L().Error("cache set failed", zap.String("cache", l.opts.Name), zap.Int("count", len(items)), zap.Error(err))
+	}
+}
+
+// ttl 在 ttl 的基础上随机浮动 Jitter 比例
+func (l *Loader[K, V]) ttl(ttl time.Duration) time.Duration {
+	if l.opts.Jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	delta := int64(float64(ttl) * l.opts.Jitter)
+	if delta <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int64N(2*delta+1)-delta)
+}
+
+func (l *Loader[K, V]) recordLocalHit(n int) {
+	if l.opts.Recorder != nil && n > 0 {
+		l.opts.Recorder.RecordLocalHit(n)
+	}
+}
+
+func (l *Loader[K, V]) recordHit(n int) {
+	if l.opts.Recorder != nil && n > 0 {
+		l.opts.Recorder.RecordHit(n)
+	}
+}
+
+func (l *Loader[K, V]) recordMiss(n int) {
+	if l.opts.Recorder != nil && n > 0 {
+		l.opts.Recorder.RecordMiss(n)
+	}
+}
+
+func (l *Loader[K, V]) recordError() {
+	if l.opts.Recorder != nil {
+		l.opts.Recorder.RecordError()
+	}
+}
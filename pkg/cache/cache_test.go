@@ -0,0 +1,374 @@
+package cache
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+// memStore 内存实现的 Store，记录调用次数
+type memStore struct {
+	mu     sync.Mutex
+	data   map[string][]byte
+	ttls   map[string]time.Duration
+	locks  map[string]bool
+	mgets  int
+	mgetFn func() error // 不为 nil 时 MGet 返回它的错误
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		data:  make(map[string][]byte),
+		ttls:  make(map[string]time.Duration),
+		locks: make(map[string]bool),
+	}
+}
+
+func (s *memStore) MGet(keys []string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mgets++
+	if s.mgetFn != nil {
+		if err := s.mgetFn(); err != nil {
+			return nil, err
+		}
+	}
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = s.data[key]
+	}
+	return values, nil
+}
+
+func (s *memStore) MSet(items []Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		s.data[item.Key] = item.Value
+		s.ttls[item.Key] = item.TTL
+	}
+	return nil
+}
+
+func (s *memStore) Del(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+func (s *memStore) Lock(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locks[key] {
+		return false, nil
+	}
+	s.locks[key] = true
+	return true, nil
+}
+
+func (s *memStore) Unlock(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locks, key)
+	return nil
+}
+
+func (s *memStore) get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.data[key])
+}
+
+type user struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Password string `json:"password,omitempty"`
+}
+
+// source 模拟数据库，id 为负数的不存在，id 为 0 时返回错误
+type source struct {
+	loads   atomic.Int64
+	batches atomic.Int64
+	delay   time.Duration
+}
+
+func (s *source) load(id int64) (*user, error) {
+	s.loads.Add(1)
+	time.Sleep(s.delay)
+	switch {
+	case id < 0:
+		return nil, errNotFound
+	case id == 0:
+		return nil, errors.New("db error")
+	}
+	return &user{ID: id, Name: "u" + strconv.FormatInt(id, 10), Password: "secret"}, nil
+}
+
+func (s *source) loadBatch(ids []int64) (map[int64]*user, error) {
+	s.batches.Add(1)
+	res := make(map[int64]*user)
+	for _, id := range ids {
+		if id > 0 {
+			res[id] = &user{ID: id, Name: "u" + strconv.FormatInt(id, 10)}
+		}
+	}
+	return res, nil
+}
+
+func newUserLoader(store Store, src *source, batch bool) *Loader[int64, *user] {
+	opts := Options[int64, *user]{
+		Name:     "user",
+		Store:    store,
+		Key:      func(id int64) string { return "user:" + strconv.FormatInt(id, 10) },
+		Load:     src.load,
+		TTL:      time.Hour,
+		NotFound: errNotFound,
+		Encode: func(u *user) *user {
+			c := *u
+			c.Password = ""
+			return &c
+		},
+	}
+	if batch {
+		opts.LoadBatch = src.loadBatch
+	}
+	return New(opts)
+}
+
+func TestLoaderGet(t *testing.T) {
+	store := newMemStore()
+	src := &source{}
+	l := newUserLoader(store, src, false)
+
+	tests := []struct {
+		name      string
+		id        int64
+		wantErr   error
+		wantName  string
+		wantLoads int64
+		wantCache string
+	}{
+		{name: "miss loads and caches", id: 1, wantName: "u1", wantLoads: 1, wantCache: `{"id":1,"name":"u1"}`},
+		{name: "hit", id: 1, wantName: "u1", wantLoads: 1, wantCache: `{"id":1,"name":"u1"}`},
+		{name: "not found caches negative", id: -1, wantErr: errNotFound, wantLoads: 2, wantCache: negativeValue},
+		{name: "negative hit", id: -1, wantErr: errNotFound, wantLoads: 2, wantCache: negativeValue},
+		{name: "error not cached", id: 0, wantErr: errors.New("db error"), wantLoads: 3},
+		{name: "error retried", id: 0, wantErr: errors.New("db error"), wantLoads: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := l.Get(tt.id)
+			if (err == nil) != (tt.wantErr == nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("Get(%d) error = %v, want %v", tt.id, err, tt.wantErr)
+			}
+			if err == nil && u.Name != tt.wantName {
+				t.Errorf("Get(%d).Name = %q, want %q", tt.id, u.Name, tt.wantName)
+			}
+			if got := src.loads.Load(); got != tt.wantLoads {
+				t.Errorf("loads = %d, want %d", got, tt.wantLoads)
+			}
+			if got := store.get(l.opts.Key(tt.id)); got != tt.wantCache {
+				t.Errorf("cache = %q, want %q", got, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestLoaderGetSingleflight(t *testing.T) {
+	src := &source{delay: 50 * time.Millisecond}
+	l := newUserLoader(newMemStore(), src, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u, err := l.Get(7); err != nil || u.ID != 7 {
+				t.Errorf("Get(7) = %v, %v", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := src.loads.Load(); got != 1 {
+		t.Errorf("loads = %d, want 1", got)
+	}
+}
+
+func TestLoaderLock(t *testing.T) {
+	store := newMemStore()
+	src := &source{}
+	l := newUserLoader(store, src, false)
+	l.opts.Lock = true
+	l.opts.LockWait = time.Millisecond
+
+	// 其他实例持有锁并在等待期间写好了缓存，本实例不再回源
+	store.locks["user:3"] = true
+	store.mgetFn = func() error {
+		if store.mgets == 2 {
+			store.data["user:3"] = []byte(`{"id":3,"name":"from other"}`)
+		}
+		return nil
+	}
+	u, err := l.Get(3)
+	if err != nil || u.Name != "from other" {
+		t.Fatalf("Get(3) = %v, %v, want value written by other instance", u, err)
+	}
+	if got := src.loads.Load(); got != 0 {
+		t.Errorf("loads = %d, want 0", got)
+	}
+
+	// 抢到锁时回源，完成后释放锁
+	store.mgetFn = nil
+	if _, err := l.Get(4); err != nil {
+		t.Fatal(err)
+	}
+	if src.loads.Load() != 1 || store.locks["user:4"] {
+		t.Errorf("loads = %d, lock held = %v, want 1 load and lock released", src.loads.Load(), store.locks["user:4"])
+	}
+}
+
+func TestLoaderGetMany(t *testing.T) {
+	for _, batch := range []bool{true, false} {
+		t.Run("batch="+strconv.FormatBool(batch), func(t *testing.T) {
+			store := newMemStore()
+			src := &source{}
+			l := newUserLoader(store, src, batch)
+			store.data["user:2"] = []byte(`{"id":2,"name":"cached"}`)
+			store.data["user:-2"] = []byte(negativeValue)
+
+			got, err := l.GetMany([]int64{1, 2, 1, -1, -2, 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make(map[int64]string)
+			for id, u := range got {
+				names[id] = u.Name
+			}
+			want := map[int64]string{1: "u1", 2: "cached", 3: "u3"}
+			if !reflect.DeepEqual(names, want) {
+				t.Errorf("GetMany() = %v, want %v", names, want)
+			}
+			// 不存在的 id 缓存空值，再次查询不回源
+			if store.get("user:-1") != negativeValue {
+				t.Errorf("user:-1 = %q, want negative value", store.get("user:-1"))
+			}
+			loads, batches := src.loads.Load(), src.batches.Load()
+			if _, err := l.GetMany([]int64{1, -1, 3}); err != nil {
+				t.Fatal(err)
+			}
+			if src.loads.Load() != loads || src.batches.Load() != batches {
+				t.Errorf("second GetMany() loaded from source again")
+			}
+		})
+	}
+}
+
+func TestLoaderCorruptValue(t *testing.T) {
+	store := newMemStore()
+	src := &source{}
+	l := newUserLoader(store, src, false)
+	store.data["user:5"] = []byte(`{"id":"not a number"}`)
+
+	u, err := l.Get(5)
+	if err != nil || u.Name != "u5" {
+		t.Fatalf("Get(5) = %v, %v", u, err)
+	}
+	if got := store.get("user:5"); got != `{"id":5,"name":"u5"}` {
+		t.Errorf("cache = %q, want overwritten value", got)
+	}
+}
+
+func TestLoaderStoreError(t *testing.T) {
+	store := newMemStore()
+	store.mgetFn = func() error { return errors.New("redis down") }
+	src := &source{}
+	l := newUserLoader(store, src, true)
+
+	if u, err := l.Get(1); err != nil || u.ID != 1 {
+		t.Errorf("Get(1) = %v, %v, want fallback to source", u, err)
+	}
+	got, err := l.GetMany([]int64{1, 2})
+	if err != nil || len(got) != 2 {
+		t.Errorf("GetMany() = %v, %v, want fallback to source", got, err)
+	}
+}
+
+func TestLoaderBypass(t *testing.T) {
+	store := newMemStore()
+	src := &source{}
+	l := newUserLoader(store, src, true)
+	var bypass atomic.Bool
+	l.opts.Bypass = bypass.Load
+
+	store.data["user:1"] = []byte(`{"id":1,"name":"cached"}`)
+	bypass.Store(true)
+	u, err := l.Get(1)
+	if err != nil || u.Name != "u1" {
+		t.Fatalf("Get(1) = %v, %v, want value from source", u, err)
+	}
+	if _, err := l.Get(-1); !errors.Is(err, errNotFound) {
+		t.Errorf("Get(-1) error = %v, want errNotFound", err)
+	}
+	got, err := l.GetMany([]int64{1, 2, -1})
+	if err != nil || len(got) != 2 {
+		t.Errorf("GetMany() = %v, %v", got, err)
+	}
+	if store.mgets != 0 || store.get("user:-1") != "" || store.get("user:1") != `{"id":1,"name":"cached"}` {
+		t.Errorf("bypass should not touch the store: mgets = %d", store.mgets)
+	}
+
+	bypass.Store(false)
+	if u, _ := l.Get(1); u.Name != "cached" {
+		t.Errorf("Get(1).Name = %q, want cached after bypass ends", u.Name)
+	}
+}
+
+func TestLoaderTTLJitter(t *testing.T) {
+	store := newMemStore()
+	l := newUserLoader(store, &source{}, true)
+	l.opts.Jitter = 0.1
+	ids := make([]int64, 100)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	if _, err := l.GetMany(ids); err != nil {
+		t.Fatal(err)
+	}
+	distinct := make(map[time.Duration]bool)
+	for key, ttl := range store.ttls {
+		if ttl < 54*time.Minute || ttl > 66*time.Minute {
+			t.Errorf("%s ttl = %v, want within ±10%% of 1h", key, ttl)
+		}
+		distinct[ttl] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("ttl has no jitter")
+	}
+}
+
+func TestLoaderDelete(t *testing.T) {
+	store := newMemStore()
+	src := &source{}
+	l := newUserLoader(store, src, false)
+	if _, err := l.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if store.get("user:1") != "" {
+		t.Errorf("Delete() left the cached value")
+	}
+	if _, err := l.Get(1); err != nil || src.loads.Load() != 2 {
+		t.Errorf("Get() after Delete() loads = %d, want 2", src.loads.Load())
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	type op struct {
+		action string // set、get、del
+		key    string
+		value  int
+		want   int // get 的期望值，0 表示不存在
+	}
+	tests := []struct {
+		name    string
+		ops     []op
+		wantLen int
+	}{
+		{
+			name: "evict least recently used",
+			ops: []op{
+				{action: "set", key: "a", value: 1},
+				{action: "set", key: "b", value: 2},
+				{action: "set", key: "c", value: 3},
+				{action: "set", key: "d", value: 4},
+				{action: "get", key: "a"},
+				{action: "get", key: "d", want: 4},
+			},
+			wantLen: 3,
+		},
+		{
+			name: "get refreshes recency",
+			ops: []op{
+				{action: "set", key: "a", value: 1},
+				{action: "set", key: "b", value: 2},
+				{action: "set", key: "c", value: 3},
+				{action: "get", key: "a", want: 1},
+				{action: "set", key: "d", value: 4},
+				{action: "get", key: "a", want: 1},
+				{action: "get", key: "b"},
+			},
+			wantLen: 3,
+		},
+		{
+			name: "overwrite keeps size",
+			ops: []op{
+				{action: "set", key: "a", value: 1},
+				{action: "set", key: "a", value: 2},
+				{action: "get", key: "a", want: 2},
+			},
+			wantLen: 1,
+		},
+		{
+			name: "delete",
+			ops: []op{
+				{action: "set", key: "a", value: 1},
+				{action: "set", key: "b", value: 2},
+				{action: "del", key: "a"},
+				{action: "del", key: "missing"},
+				{action: "get", key: "a"},
+				{action: "get", key: "b", want: 2},
+			},
+			wantLen: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU(3, time.Minute)
+			for i, o := range tt.ops {
+				switch o.action {
+				case "set":
+					c.Set(o.key, o.value, 0)
+				case "del":
+					c.Delete(o.key)
+				case "get":
+					v, ok := c.Get(o.key)
+					if o.want == 0 && ok {
+						t.Errorf("op %d: Get(%q) = %v, want missing", i, o.key, v)
+					}
+					if o.want != 0 && (!ok || v.(int) != o.want) {
+						t.Errorf("op %d: Get(%q) = %v, %v, want %d", i, o.key, v, ok, o.want)
+					}
+				}
+			}
+			if got := c.Len(); got != tt.wantLen {
+				t.Errorf("Len() = %d, want %d", got, tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestLRUExpire(t *testing.T) {
+	c := NewLRU(10, 200*time.Millisecond)
+	c.Set("default", 1, 0)
+	c.Set("short", 2, 10*time.Millisecond)
+	c.Set("capped", 3, time.Hour) // 不超过 LRU 的过期时间
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("short should have expired")
+	}
+	if _, ok := c.Get("default"); !ok {
+		t.Error("default should not have expired yet")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	for _, key := range []string{"default", "capped"} {
+		if _, ok := c.Get(key); ok {
+			t.Errorf("%s should have expired", key)
+		}
+	}
+	if got := c.Len(); got != 0 {
+		t.Errorf("Len() = %d, want expired entries removed on Get", got)
+	}
+}
+
+func TestLRUPurge(t *testing.T) {
+	c := NewLRU(10, time.Minute)
+	for i := 0; i < 5; i++ {
+		c.Set(strconv.Itoa(i), i, 0)
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d after Purge(), want 0", c.Len())
+	}
+	c.Set("a", 1, 0)
+	if v, ok := c.Get("a"); !ok || v.(int) != 1 {
+		t.Errorf("Get(a) = %v, %v after Purge()", v, ok)
+	}
+}
+
+func TestLRUConcurrent(t *testing.T) {
+	c := NewLRU(64, time.Minute)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa((g*31 + i) % 128)
+				c.Set(key, i, 0)
+				c.Get(key)
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if c.Len() > 64 {
+		t.Errorf("Len() = %d, want at most 64", c.Len())
+	}
+}
+
+func TestLoaderLocal(t *testing.T) {
+	store := newMemStore()
+	src := &source{}
+	l := newUserLoader(store, src, true)
+	l.EnableLocal(10, time.Minute)
+
+	if _, err := l.Get(1); err != nil {
+		t.Fatal(err)
+	}
+	// 一级缓存命中时不读 Redis
+	mgets := store.mgets
+	u, err := l.Get(1)
+	if err != nil || u.ID != 1 || store.mgets != mgets {
+		t.Errorf("Get(1) = %v, %v, mgets %d -> %d, want local hit", u, err, mgets, store.mgets)
+	}
+	if got, err := l.GetMany([]int64{1}); err != nil || len(got) != 1 || store.mgets != mgets {
+		t.Errorf("GetMany() = %v, %v, want local hit", got, err)
+	}
+
+	// 其他实例更新后通过 Invalidate 删除本实例的一级缓存，下次从 Redis 读到新数据
+	store.data["user:1"] = []byte(`{"id":1,"name":"updated"}`)
+	if u, _ := l.Get(1); u.Name == "updated" {
+		t.Fatal("local cache should still hold the old value")
+	}
+	l.Invalidate("user:1")
+	if u, _ := l.Get(1); u.Name != "updated" {
+		t.Errorf("Get(1).Name = %q after Invalidate(), want updated", u.Name)
+	}
+
+	// 不存在的数据不进一级缓存
+	if _, err := l.Get(-1); err == nil {
+		t.Fatal("Get(-1) should fail")
+	}
+	if l.LocalLen() != 1 {
+		t.Errorf("LocalLen() = %d, want 1", l.LocalLen())
+	}
+
+	l.PurgeLocal()
+	if l.LocalLen() != 0 {
+		t.Errorf("LocalLen() = %d after PurgeLocal(), want 0", l.LocalLen())
+	}
+	l.EnableLocal(0, time.Minute)
+	if _, err := l.Get(1); err != nil || l.LocalLen() != 0 {
+		t.Errorf("local cache should be disabled, LocalLen() = %d", l.LocalLen())
+	}
+}
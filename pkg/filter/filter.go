@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"web-app/settings"
+
+	"go.uber.org/zap"
+)
+
+// 词库文件格式：每行一个词，可以用逗号追加处理动作，缺省为 mask
+//   赌博,reject
+//   代开发票,review
+//   傻瓜
+// 以 # 开头的行是注释
+
+var (
+	matcher atomic.Pointer[Matcher]
+	linkRe  = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+)
+
+// Result 内容检查结果
+type Result struct {
+	Action Action   // 最终处理动作
+	Text   string   // 处理后的文本（mask 后的）
+	Hits   []string // 命中的敏感词
+}
+
+// Init 加载词库
+func Init(cfg *settings.FilterConfig) (err error) {
+	m, err := load(cfg)
+	if err != nil {
+		return err
+	}
+	matcher.Store(m)
+	zap.L().Info("sensitive word filter loaded", zap.Int("words", m.Len()))
+	return nil
+}
+
+// Reload 重新加载词库，失败时保留旧的词库继续工作
+func Reload(cfg *settings.FilterConfig) {
+	m, err := load(cfg)
+	if err != nil {
+		zap.L().Error("filter.Reload() failed, keep the old word list", zap.Error(err))
+		return
+	}
+	matcher.Store(m)
+	zap.L().Info("sensitive word filter reloaded", zap.Int("words", m.Len()))
+}
+
+func load(cfg *settings.FilterConfig) (*Matcher, error) {
+	words := make(map[string]Action)
+	if cfg == nil || cfg.WordFile == "" {
+		return NewMatcher(words), nil
+	}
+
+	f, err := os.Open(cfg.WordFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, actionStr, _ := strings.Cut(line, ",")
+		word = strings.TrimSpace(word)
+		action := ActionMask
+		if actionStr != "" {
+			a, ok := ParseAction(strings.TrimSpace(actionStr))
+			if !ok {
+				zap.L().Warn("unknown filter action, fallback to mask",
+					zap.String("word", word), zap.String("action", actionStr))
+			} else {
+				action = a
+			}
+		}
+		// 同一个词出现多次时取更严重的动作
+		if old, ok := words[word]; !ok || action > old {
+			words[word] = action
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewMatcher(words), nil
+}
+
+// Check 检查文本，返回最严重的处理动作以及 mask 后的文本
+func Check(text string) *Result {
+	res := &Result{Action: ActionPass, Text: text}
+	matches := matcher.Load().FindAll(text)
+	if len(matches) == 0 {
+		return res
+	}
+
+	runes := []rune(text)
+	masked := false
+	for _, m := range matches {
+		res.Hits = append(res.Hits, m.Word)
+		if m.Action > res.Action {
+			res.Action = m.Action
+		}
+		if m.Action == ActionMask {
+			for i := m.Start; i < m.End; i++ {
+				runes[i] = '*'
+			}
+			masked = true
+		}
+	}
+	if masked {
+		res.Text = string(runes)
+	}
+	return res
+}
+
+// CountLinks 统计文本中的链接数量
+func CountLinks(text string) int {
+	return len(linkRe.FindAllStringIndex(text, -1))
+}
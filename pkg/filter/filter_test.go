@@ -0,0 +1,182 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"web-app/settings"
+)
+
+func TestMatcherFindAll(t *testing.T) {
+	m := NewMatcher(map[string]Action{
+		"he":   ActionMask,
+		"she":  ActionMask,
+		"his":  ActionReview,
+		"hers": ActionReject,
+		"赌博":   ActionReject,
+		"abc":  ActionMask,
+		"发票":   ActionReview,
+		"代开发票": ActionReview,
+		"":     ActionReject, // 空词忽略
+		"ｘｙｚ":  ActionMask,
+	})
+
+	tests := []struct {
+		name string
+		text string
+		want []Match
+	}{
+		{name: "word prefix", text: "hello world", want: []Match{{"he", ActionMask, 0, 2}}},
+		{name: "empty text", text: "", want: nil},
+		{
+			name: "overlapping words via fail links",
+			text: "ushers",
+			want: []Match{
+				{"she", ActionMask, 1, 4},
+				{"he", ActionMask, 2, 4},
+				{"hers", ActionReject, 2, 6},
+			},
+		},
+		{name: "fail back to root", text: "ahishe", want: []Match{
+			{"his", ActionReview, 1, 4},
+			{"she", ActionMask, 3, 6},
+			{"he", ActionMask, 4, 6},
+		}},
+		{name: "chinese rune offsets", text: "一起赌博吧", want: []Match{{"赌博", ActionReject, 2, 4}}},
+		{name: "nested chinese words", text: "代开发票", want: []Match{
+			{"代开发票", ActionReview, 0, 4},
+			{"发票", ActionReview, 2, 4},
+		}},
+		{name: "case insensitive", text: "xABCx", want: []Match{{"abc", ActionMask, 1, 4}}},
+		{name: "full width", text: "ＡＢＣ", want: []Match{{"abc", ActionMask, 0, 3}}},
+		{name: "full width word", text: "xyz", want: []Match{{"ｘｙｚ", ActionMask, 0, 3}}},
+		{name: "repeated", text: "abcabc", want: []Match{
+			{"abc", ActionMask, 0, 3},
+			{"abc", ActionMask, 3, 6},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := m.FindAll(tt.text)
+			sortMatches(got)
+			sortMatches(tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAll(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherEmpty(t *testing.T) {
+	var nilMatcher *Matcher
+	for _, m := range []*Matcher{nilMatcher, NewMatcher(nil), NewMatcher(map[string]Action{"": ActionMask})} {
+		if got := m.FindAll("anything"); got != nil {
+			t.Errorf("FindAll() = %v, want nil", got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	matcher.Store(NewMatcher(map[string]Action{
+		"傻瓜":   ActionMask,
+		"代开发票": ActionReview,
+		"赌博":   ActionReject,
+	}))
+	defer matcher.Store(nil)
+
+	tests := []struct {
+		name   string
+		text   string
+		action Action
+		masked string
+		hits   []string
+	}{
+		{name: "pass", text: "正常的内容", action: ActionPass, masked: "正常的内容"},
+		{name: "mask", text: "你这个傻瓜", action: ActionMask, masked: "你这个**", hits: []string{"傻瓜"}},
+		{name: "review keeps text", text: "代开发票找我", action: ActionReview, masked: "代开发票找我", hits: []string{"代开发票"}},
+		{name: "most severe wins", text: "傻瓜才赌博", action: ActionReject, masked: "**才赌博", hits: []string{"傻瓜", "赌博"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Check(tt.text)
+			if res.Action != tt.action {
+				t.Errorf("Action = %v, want %v", res.Action, tt.action)
+			}
+			if res.Text != tt.masked {
+				t.Errorf("Text = %q, want %q", res.Text, tt.masked)
+			}
+			if !reflect.DeepEqual(res.Hits, tt.hits) {
+				t.Errorf("Hits = %v, want %v", res.Hits, tt.hits)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "words.txt")
+	content := "# 注释\n\n傻瓜\n赌博, reject\n代开发票,review\n傻瓜,reject\n未知,unknown\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := load(&settings.FilterConfig{WordFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text   string
+		action Action
+	}{
+		{"傻瓜", ActionReject}, // 重复的词取更严重的动作
+		{"赌博", ActionReject},
+		{"代开发票", ActionReview},
+		{"未知", ActionMask}, // 不认识的动作按 mask 处理
+		{"注释", ActionPass},
+	}
+	for _, tt := range tests {
+		var got Action
+		for _, match := range m.FindAll(tt.text) {
+			if match.Action > got {
+				got = match.Action
+			}
+		}
+		if got != tt.action {
+			t.Errorf("%q: action = %v, want %v", tt.text, got, tt.action)
+		}
+	}
+
+	if _, err := load(&settings.FilterConfig{WordFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("load() with missing file should fail")
+	}
+	if m, err := load(nil); err != nil || m.Len() != 0 {
+		t.Errorf("load(nil) = %v, %v, want empty matcher", m, err)
+	}
+}
+
+func TestCountLinks(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"没有链接", 0},
+		{"看这里 https://example.com/a 和 http://b.cn", 2},
+		{"www.example.com 还有 HTTPS://X.COM", 2},
+		{"ftp://example.com", 0},
+	}
+	for _, tt := range tests {
+		if got := CountLinks(tt.text); got != tt.want {
+			t.Errorf("CountLinks(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func sortMatches(ms []Match) {
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].End != ms[j].End {
+			return ms[i].End < ms[j].End
+		}
+		return ms[i].Start < ms[j].Start
+	})
+}
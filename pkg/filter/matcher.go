@@ -0,0 +1,165 @@
+package filter
+
+import "unicode"
+
+// Action 命中敏感词后的处理方式
+type Action int8
+
+// 动作按严重程度递增，多个词同时命中时取最严重的那个
+const (
+	ActionPass   Action = iota // 未命中
+	ActionMask                 // 替换为 *
+	ActionReview               // 进入人工审核
+	ActionReject               // 直接拒绝
+)
+
+// ParseAction 把词库文件中的动作名转换成 Action
+func ParseAction(s string) (Action, bool) {
+	switch s {
+	case "mask":
+		return ActionMask, true
+	case "review":
+		return ActionReview, true
+	case "reject":
+		return ActionReject, true
+	}
+	return ActionPass, false
+}
+
+func (a Action) String() string {
+	switch a {
+	case ActionMask:
+		return "mask"
+	case ActionReview:
+		return "review"
+	case ActionReject:
+		return "reject"
+	}
+	return "pass"
+}
+
+// Match 一次命中记录，Start/End 是 rune 下标，左闭右开
+type Match struct {
+	Word   string
+	Action Action
+	Start  int
+	End    int
+}
+
+// node Aho-Corasick 自动机的节点
+type node struct {
+	children map[rune]*node
+	fail     *node
+	// 以该节点结尾的词（包括通过 fail 链继承的），只记录下标避免重复拷贝
+	outputs []int
+}
+
+// Matcher Aho-Corasick 多模式匹配器
+// 构建完成后只读，可以被多个 goroutine 并发使用
+type Matcher struct {
+	root    *node
+	words   []string
+	actions []Action
+	lengths []int
+}
+
+// NewMatcher 根据词表构建匹配器，key 为敏感词，value 为对应的处理动作
+func NewMatcher(words map[string]Action) *Matcher {
+	m := &Matcher{root: &node{children: make(map[rune]*node)}}
+	for word, action := range words {
+		m.insert(word, action)
+	}
+	m.build()
+	return m
+}
+
+// Len 返回词表大小
+func (m *Matcher) Len() int {
+	return len(m.words)
+}
+
+func (m *Matcher) insert(word string, action Action) {
+	runes := normalize([]rune(word))
+	if len(runes) == 0 {
+		return
+	}
+	cur := m.root
+	for _, r := range runes {
+		next, ok := cur.children[r]
+		if !ok {
+			next = &node{children: make(map[rune]*node)}
+			cur.children[r] = next
+		}
+		cur = next
+	}
+	m.words = append(m.words, word)
+	m.actions = append(m.actions, action)
+	m.lengths = append(m.lengths, len(runes))
+	cur.outputs = append(cur.outputs, len(m.words)-1)
+}
+
+// build 按层序遍历生成 fail 指针
+func (m *Matcher) build() {
+	queue := make([]*node, 0, len(m.root.children))
+	for _, child := range m.root.children {
+		child.fail = m.root
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range cur.children {
+			f := cur.fail
+			for f != nil && f.children[r] == nil {
+				f = f.fail
+			}
+			if f == nil {
+				child.fail = m.root
+			} else {
+				child.fail = f.children[r]
+			}
+			child.outputs = append(child.outputs, child.fail.outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// FindAll 返回文本中所有命中的敏感词
+func (m *Matcher) FindAll(text string) []Match {
+	if m == nil || len(m.words) == 0 {
+		return nil
+	}
+	runes := normalize([]rune(text))
+	var matches []Match
+	cur := m.root
+	for i, r := range runes {
+		for cur != m.root && cur.children[r] == nil {
+			cur = cur.fail
+		}
+		if next, ok := cur.children[r]; ok {
+			cur = next
+		}
+		for _, idx := range cur.outputs {
+			matches = append(matches, Match{
+				Word:   m.words[idx],
+				Action: m.actions[idx],
+				Start:  i + 1 - m.lengths[idx],
+				End:    i + 1,
+			})
+		}
+	}
+	return matches
+}
+
+// normalize 统一大小写以及全角字符，让 "ＡＢＣ" 和 "abc" 命中同一个词
+// 只做一对一的 rune 替换，保证下标和原文一致
+func normalize(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds 延迟直方图每个桶的上限
+var latencyBounds = []time.Duration{
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// Histogram 延迟直方图，并发安全
+// 最后一个桶记录超过所有上限的数据
+type Histogram struct {
+	counts [13]atomic.Int64 // len(latencyBounds) + 1
+	count  atomic.Int64
+	errors atomic.Int64
+	sum    atomic.Int64 // 纳秒
+}
+
+// HistogramSnapshot 直方图某一时刻的统计结果，分位数是所在桶的上限
+type HistogramSnapshot struct {
+	Count   int64            `json:"count"`
+	Errors  int64            `json:"errors"`
+	AvgMs   float64          `json:"avg_ms"`
+	P50Ms   float64          `json:"p50_ms"`
+	P95Ms   float64          `json:"p95_ms"`
+	P99Ms   float64          `json:"p99_ms"`
+	Buckets map[string]int64 `json:"buckets"`
+}
+
+// Observe 记录一次耗时，failed 表示这次操作出错
+func (h *Histogram) Observe(d time.Duration, failed bool) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+	if failed {
+		h.errors.Add(1)
+	}
+}
+
+// Snapshot 当前的统计结果
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	counts := make([]int64, len(h.counts))
+	var total int64
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+		total += counts[i]
+	}
+	s := HistogramSnapshot{
+		Count:   h.count.Load(),
+		Errors:  h.errors.Load(),
+		Buckets: make(map[string]int64, len(counts)),
+	}
+	if s.Count > 0 {
+		s.AvgMs = ms(time.Duration(h.sum.Load() / s.Count))
+	}
+	for i, c := range counts {
+		s.Buckets[bucketName(i)] = c
+	}
+	s.P50Ms = quantile(counts, total, 0.50)
+	s.P95Ms = quantile(counts, total, 0.95)
+	s.P99Ms = quantile(counts, total, 0.99)
+	return s
+}
+
+// quantile 第 q 分位数所在桶的上限，落在最后一个桶时返回最大的上限
+func quantile(counts []int64, total int64, q float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	target := int64(float64(total)*q + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for i, c := range counts {
+		seen += c
+		if seen >= target && i < len(latencyBounds) {
+			return ms(latencyBounds[i])
+		}
+	}
+	return ms(latencyBounds[len(latencyBounds)-1])
+}
+
+func bucketName(i int) string {
+	if i < len(latencyBounds) {
+		return fmt.Sprintf("le_%gms", ms(latencyBounds[i]))
+	}
+	return fmt.Sprintf("gt_%gms", ms(latencyBounds[len(latencyBounds)-1]))
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	bucketWidth = 10 * time.Second
+	bucketCount = 360 // 最长统计最近 1 小时
+)
+
+// MaxWindow 滑动窗口最长可以统计的时间
+const MaxWindow = bucketWidth * bucketCount
+
+type bucket struct {
+	slot   int64 // 所属的时间片，时间片过期后清零重新使用
+	values []int64
+}
+
+// Window 滑动窗口计数器，按 10 秒一个时间片记录最近 1 小时内 n 个指标的增量
+type Window struct {
+	mu      sync.Mutex
+	n       int
+	buckets [bucketCount]bucket
+}
+
+// NewWindow n 每个时间片记录的指标个数
+func NewWindow(n int) *Window {
+	w := &Window{n: n}
+	for i := range w.buckets {
+		w.buckets[i].values = make([]int64, n)
+	}
+	return w
+}
+
+func currentSlot(now time.Time) int64 {
+	return now.UnixNano() / int64(bucketWidth)
+}
+
+// Add 第 i 个指标增加 delta
+func (w *Window) Add(i int, delta int64) {
+	slot := currentSlot(time.Now())
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[slot%bucketCount]
+	if b.slot != slot {
+		b.slot = slot
+		for j := range b.values {
+			b.values[j] = 0
+		}
+	}
+	b.values[i] += delta
+}
+
+// Sum 最近 d 时间内每个指标的总和，d 按时间片向上取整，最长 1 小时
+func (w *Window) Sum(d time.Duration) []int64 {
+	if d > MaxWindow {
+		d = MaxWindow
+	}
+	slots := int64((d + bucketWidth - 1) / bucketWidth)
+	now := currentSlot(time.Now())
+	result := make([]int64, w.n)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.slot > now-slots && b.slot <= now {
+			for j, v := range b.values {
+				result[j] += v
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,44 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxRunes slug 最多保留的字符数，太长的标题截断
+const MaxRunes = 48
+
+// Make 根据标题生成 URL 中使用的 slug
+// 英文和数字转为小写，中文等其他文字原样保留（浏览器和搜索引擎都能正确展示 UTF-8 路径），
+// 其余的标点、空白统一替换为 -，连续的 - 合并，首尾的 - 去掉
+// 标题里没有可用字符时返回空字符串
+func Make(title string) string {
+	var b strings.Builder
+	count := 0
+	dash := false
+	for _, r := range title {
+		if count >= MaxRunes {
+			break
+		}
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(unicode.ToLower(r))
+		case r >= unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			// 全角字母数字转成半角，避免同一个标题生成两种 slug
+			if r >= '０' && r <= '９' || r >= 'Ａ' && r <= 'Ｚ' || r >= 'ａ' && r <= 'ｚ' {
+				r = unicode.ToLower(r - 0xFEE0)
+			}
+			b.WriteRune(r)
+		default:
+			if b.Len() > 0 && !dash {
+				b.WriteByte('-')
+				dash = true
+				count++
+			}
+			continue
+		}
+		dash = false
+		count++
+	}
+	return strings.TrimRight(b.String(), "-")
+}
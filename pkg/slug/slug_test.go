@@ -0,0 +1,57 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{name: "ascii", title: "Hello World", want: "hello-world"},
+		{name: "punctuation collapsed", title: "Go 1.22: what's new?!", want: "go-1-22-what-s-new"},
+		{name: "leading and trailing", title: "  --Hello--  ", want: "hello"},
+		{name: "chinese kept", title: "Go 语言入门", want: "go-语言入门"},
+		{name: "chinese punctuation", title: "你好，世界！", want: "你好-世界"},
+		{name: "full width to half width", title: "ＧＯ１２３", want: "go123"},
+		{name: "full width lower", title: "ｇｏ", want: "go"},
+		{name: "only punctuation", title: "？！...", want: ""},
+		{name: "empty", title: "", want: ""},
+		{name: "emoji dropped", title: "Go 🚀 fast", want: "go-fast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Make(tt.title); got != tt.want {
+				t.Errorf("Make(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{name: "long ascii", title: strings.Repeat("a", 100), want: strings.Repeat("a", MaxRunes)},
+		{name: "long chinese", title: strings.Repeat("中", 100), want: strings.Repeat("中", MaxRunes)},
+		// 截断的位置刚好是分隔符时不保留结尾的 -
+		{name: "cut at dash", title: strings.Repeat("a", MaxRunes-1) + " bbb", want: strings.Repeat("a", MaxRunes-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Make(tt.title)
+			if got != tt.want {
+				t.Errorf("Make() = %q, want %q", got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > MaxRunes {
+				t.Errorf("Make() returned %d runes, want at most %d", n, MaxRunes)
+			}
+		})
+	}
+}
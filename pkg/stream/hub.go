@@ -0,0 +1,170 @@
+package stream
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Hub 本实例内的事件分发中心
+// 跨实例的一致性由 Redis Pub/Sub 保证：每个实例都订阅同一组频道，收到消息后再交给 Hub 分发给本机的连接
+
+var ErrHubClosed = errors.New("stream hub closed")
+
+// Client 一个订阅连接
+type Client struct {
+	topics  []string
+	ch      chan []byte
+	done    chan struct{}
+	once    sync.Once
+	dropped int32
+}
+
+// Events 待推送的事件
+func (c *Client) Events() <-chan []byte {
+	return c.ch
+}
+
+// Done 连接被服务端关闭（停机或者消费过慢被踢掉）时关闭
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Client) close() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// Hub 按 topic 管理订阅连接
+type Hub struct {
+	mu         sync.RWMutex
+	topics     map[string]map[*Client]struct{}
+	closed     bool
+	bufferSize int
+	maxDropped int32
+
+	clients int64 // 当前连接数
+	dropped int64 // 因为缓冲区满而丢弃的事件总数
+	kicked  int64 // 因为消费过慢被踢掉的连接数
+}
+
+// NewHub bufferSize 每个连接的缓冲区大小，maxDropped 连续丢弃超过该数量的事件时断开连接
+func NewHub(bufferSize, maxDropped int) *Hub {
+	return &Hub{
+		topics:     make(map[string]map[*Client]struct{}),
+		bufferSize: bufferSize,
+		maxDropped: int32(maxDropped),
+	}
+}
+
+// Subscribe 订阅一组 topic
+func (h *Hub) Subscribe(topics []string) (*Client, error) {
+	c := &Client{
+		topics: topics,
+		ch:     make(chan []byte, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+	for _, topic := range topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			subs = make(map[*Client]struct{})
+			h.topics[topic] = subs
+		}
+		subs[c] = struct{}{}
+	}
+	atomic.AddInt64(&h.clients, 1)
+	return c, nil
+}
+
+// Unsubscribe 取消订阅，连接结束时调用
+func (h *Hub) Unsubscribe(c *Client) {
+	h.mu.Lock()
+	h.remove(c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// remove 调用方需要持有写锁
+func (h *Hub) remove(c *Client) {
+	removed := false
+	for _, topic := range c.topics {
+		subs, ok := h.topics[topic]
+		if !ok {
+			continue
+		}
+		if _, ok := subs[c]; ok {
+			delete(subs, c)
+			removed = true
+		}
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	if removed {
+		atomic.AddInt64(&h.clients, -1)
+	}
+}
+
+// Publish 把事件分发给订阅了 topic 的本机连接
+// 不会阻塞：连接缓冲区满时丢弃事件，连续丢弃过多的连接会被断开，由客户端重连后重新拉取最新状态
+func (h *Hub) Publish(topic string, payload []byte) {
+	var slow []*Client
+
+	h.mu.RLock()
+	for c := range h.topics[topic] {
+		select {
+		case c.ch <- payload:
+			atomic.StoreInt32(&c.dropped, 0)
+		default:
+			atomic.AddInt64(&h.dropped, 1)
+			if atomic.AddInt32(&c.dropped, 1) > h.maxDropped {
+				slow = append(slow, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, c := range slow {
+		h.remove(c)
+		c.close()
+		atomic.AddInt64(&h.kicked, 1)
+	}
+	h.mu.Unlock()
+}
+
+// Close 关闭所有连接，之后的订阅都会失败
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, subs := range h.topics {
+		for c := range subs {
+			c.close()
+		}
+	}
+	h.topics = make(map[string]map[*Client]struct{})
+	atomic.StoreInt64(&h.clients, 0)
+}
+
+// Stats 连接及丢弃统计
+func (h *Hub) Stats() map[string]int64 {
+	return map[string]int64{
+		"clients": atomic.LoadInt64(&h.clients),
+		"dropped": atomic.LoadInt64(&h.dropped),
+		"kicked":  atomic.LoadInt64(&h.kicked),
+	}
+}
@@ -0,0 +1,140 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// 预览信息的最大长度，和 link_preview 表的字段长度一致
+const (
+	maxTitleRunes       = 256
+	maxDescriptionRunes = 512
+	maxSiteNameRunes    = 128
+	maxImageBytes       = 2048
+)
+
+// parse 解析 <head> 中的 title 和 meta 标签
+// 网页可能是 GBK 等编码，按 Content-Type 和 <meta charset> 转换成 UTF-8
+func parse(r io.Reader, contentType string, base *url.URL) (*Metadata, error) {
+	r, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		title, description, image, siteName string
+		ogTitle, ogDescription, ogImage     string
+	)
+	z := html.NewTokenizer(r)
+	inTitle := false
+loop:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// 读到 MaxBytes 截断的位置或者网页结束
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				break loop
+			case "title":
+				inTitle = tt == html.StartTagToken && title == ""
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for {
+					k, v, more := z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						key = strings.ToLower(string(v))
+					case "content":
+						content = string(v)
+					}
+					if !more {
+						break
+					}
+				}
+				switch key {
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					ogDescription = content
+				case "og:image", "og:image:url":
+					if ogImage == "" {
+						ogImage = content
+					}
+				case "og:site_name":
+					siteName = content
+				case "twitter:title":
+					title = firstNonEmpty(title, content)
+				case "twitter:description", "description":
+					description = firstNonEmpty(description, content)
+				case "twitter:image":
+					image = firstNonEmpty(image, content)
+				}
+			}
+		case html.TextToken:
+			if inTitle {
+				title = string(z.Text())
+				inTitle = false
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if string(name) == "head" {
+				break loop
+			}
+			inTitle = false
+		}
+	}
+
+	return &Metadata{
+		Title:       clean(firstNonEmpty(ogTitle, title), maxTitleRunes),
+		Description: clean(firstNonEmpty(ogDescription, description), maxDescriptionRunes),
+		Image:       resolveImage(base, firstNonEmpty(ogImage, image)),
+		SiteName:    clean(siteName, maxSiteNameRunes),
+	}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean 合并连续的空白并截断到 n 个字符
+func clean(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// resolveImage 图片可能是相对地址，按网页地址补全，只保留 http/https 图片
+func resolveImage(base *url.URL, image string) string {
+	image = strings.TrimSpace(image)
+	if image == "" {
+		return ""
+	}
+	u, err := base.Parse(image)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	s := u.String()
+	if len(s) > maxImageBytes {
+		return ""
+	}
+	return s
+}
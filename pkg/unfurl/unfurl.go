@@ -0,0 +1,234 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unfurl: 只支持 http/https 链接")
+	ErrBlockedAddress    = errors.New("unfurl: 禁止访问内网或保留地址")
+	ErrTooManyRedirects  = errors.New("unfurl: 重定向次数过多")
+	ErrNotHTML           = errors.New("unfurl: 不是网页")
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBytes     = 512 << 10
+	defaultMaxRedirects = 3
+	defaultUserAgent    = "Mozilla/5.0 (compatible; bluebell-unfurl/1.0)"
+)
+
+// 除了 netip 能识别的私有、回环、链路本地地址之外，还需要拦截的保留地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF 协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档示例
+	netip.MustParsePrefix("198.18.0.0/15"),   // 基准测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档示例
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档示例
+	netip.MustParsePrefix("240.0.0.0/4"),     // 保留
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64，可以映射到任意 IPv4 地址
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // 本地 NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档示例
+	netip.MustParsePrefix("2002::/16"),       // 6to4，可以映射到任意 IPv4 地址
+	netip.MustParsePrefix("fec0::/10"),       // 已废弃的站点本地地址
+}
+
+// Metadata 网页的预览信息，优先取 OpenGraph 标签
+type Metadata struct {
+	URL         string // 重定向之后的最终地址
+	Title       string
+	Description string
+	Image       string
+	SiteName    string
+}
+
+// Options 抓取的限制
+type Options struct {
+	Timeout      time.Duration // 整个请求（包括重定向和读取内容）的超时时间
+	MaxBytes     int64         // 最多读取的网页大小，预览信息都在 <head> 里，不需要读完整个网页
+	MaxRedirects int
+	UserAgent    string
+	// AllowPrivate 允许访问内网地址和任意端口，只在本地开发和测试时打开
+	AllowPrivate bool
+}
+
+// Fetcher 抓取网页的预览信息
+// 在建立连接时检查实际连接的 IP，DNS 重绑定和重定向到内网地址都会被拦截
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+}
+
+// New 创建 Fetcher，opts 中没有设置的限制使用默认值
+func New(opts Options) *Fetcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = defaultMaxBytes
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = defaultUserAgent
+	}
+
+	f := &Fetcher{opts: opts}
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: f.control,
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // 不使用环境变量中的代理，否则检查的是代理的地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   opts.Timeout,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   opts.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedScheme
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// control 在建立连接之前检查目标地址，此时域名已经解析成 IP
+func (f *Fetcher) control(network, address string, _ syscall.RawConn) error {
+	if f.opts.AllowPrivate {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if port != "80" && port != "443" {
+		return ErrBlockedAddress
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if IsBlockedIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// IsBlockedIP 是否是不允许访问的地址：私有、回环、链路本地、组播及各种保留地址
+func IsBlockedIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch 抓取网页并解析预览信息
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unfurl: unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	meta, err := parse(io.LimitReader(resp.Body, f.opts.MaxBytes), contentType, resp.Request.URL)
+	if err != nil {
+		return nil, err
+	}
+	meta.URL = resp.Request.URL.String()
+	return meta, nil
+}
+
+// Normalize 规范化链接，用于检测重复链接
+// 协议和域名转小写，去掉默认端口、锚点和 utm_ 开头的追踪参数，查询参数按名称排序
+func Normalize(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", ErrUnsupportedScheme
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return "", fmt.Errorf("unfurl: invalid url %q", rawURL)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
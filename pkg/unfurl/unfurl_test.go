@@ -0,0 +1,275 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// newServer 启动返回给定内容的测试网站
+func newServer(t *testing.T, contentType, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchOpenGraph(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Metadata
+	}{
+		{
+			name: "og tags take precedence",
+			body: `<html><head>
+<title>页面标题</title>
+<meta name="description" content="普通描述">
+<meta name="twitter:title" content="推特标题">
+<meta property="og:title" content="  OG   标题 ">
+<meta property="og:description" content="OG 描述">
+<meta property="og:image" content="/img/cover.png">
+<meta property="og:image" content="/img/second.png">
+<meta property="og:site_name" content="示例站点">
+</head><body></body></html>`,
+			want: Metadata{Title: "OG 标题", Description: "OG 描述", Image: "/img/cover.png", SiteName: "示例站点"},
+		},
+		{
+			name: "twitter tags before title",
+			body: `<head><meta name="twitter:title" content="推特标题"><title>页面标题</title>
+<meta name="twitter:image" content="https://cdn.example.com/a.png"></head>`,
+			want: Metadata{Title: "推特标题", Image: "https://cdn.example.com/a.png"},
+		},
+		{
+			name: "fallback to title and description",
+			body: `<head><title>页面标题</title><meta name="description" content="普通描述"></head>`,
+			want: Metadata{Title: "页面标题", Description: "普通描述"},
+		},
+		{
+			name: "stop at body",
+			body: `<head><title>页面标题</title></head><body><meta property="og:title" content="正文里的标签"></body>`,
+			want: Metadata{Title: "页面标题"},
+		},
+		{
+			name: "non http image dropped",
+			body: `<head><meta property="og:image" content="javascript:alert(1)"></head>`,
+			want: Metadata{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(t, "text/html; charset=utf-8", tt.body)
+			f := New(Options{AllowPrivate: true})
+			meta, err := f.Fetch(context.Background(), srv.URL+"/page")
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			want := tt.want
+			want.URL = srv.URL + "/page"
+			if strings.HasPrefix(want.Image, "/") {
+				want.Image = srv.URL + want.Image
+			}
+			if *meta != want {
+				t.Errorf("Fetch() = %+v, want %+v", *meta, want)
+			}
+		})
+	}
+}
+
+func TestFetchNotHTML(t *testing.T) {
+	for _, contentType := range []string{"application/json", "image/png", "text/plain", ""} {
+		srv := newServer(t, contentType, `<head><title>x</title></head>`)
+		// 没有 Content-Type 时 net/http 会按内容推断，这里需要明确设置为空
+		if contentType == "" {
+			srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = nil
+				fmt.Fprint(w, `{"title":"x"}`)
+			})
+		}
+		_, err := New(Options{AllowPrivate: true}).Fetch(context.Background(), srv.URL)
+		if !errors.Is(err, ErrNotHTML) {
+			t.Errorf("Content-Type %q: Fetch() error = %v, want ErrNotHTML", contentType, err)
+		}
+	}
+}
+
+func TestFetchMaxBytes(t *testing.T) {
+	padding := `<meta name="keywords" content="` + strings.Repeat("a", 1024) + `">`
+	tests := []struct {
+		name     string
+		maxBytes int64
+		want     string
+	}{
+		{name: "title after cutoff", maxBytes: 512, want: ""},
+		{name: "title within limit", maxBytes: 4096, want: "标题"},
+	}
+	srv := newServer(t, "text/html; charset=utf-8", "<html><head>"+padding+"<title>标题</title></head></html>")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := New(Options{AllowPrivate: true, MaxBytes: tt.maxBytes}).Fetch(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if meta.Title != tt.want {
+				t.Errorf("Title = %q, want %q", meta.Title, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	// /r/n 重定向 n 次之后到达 /page
+	mux := http.NewServeMux()
+	mux.HandleFunc("/r/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/r/"), "%d", &n)
+		if n <= 1 {
+			http.Redirect(w, r, "/page", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/r/%d", n-1), http.StatusFound)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<head><title>终点</title></head>`)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		maxRedirects int
+		wantErr      error
+	}{
+		{name: "within default limit", path: "/r/3"},
+		{name: "over default limit", path: "/r/4", wantErr: ErrTooManyRedirects},
+		{name: "custom limit", path: "/r/1", maxRedirects: 1},
+		{name: "over custom limit", path: "/r/2", maxRedirects: 1, wantErr: ErrTooManyRedirects},
+		{name: "unsupported scheme", path: "/ftp", wantErr: ErrUnsupportedScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New(Options{AllowPrivate: true, MaxRedirects: tt.maxRedirects})
+			meta, err := f.Fetch(context.Background(), srv.URL+tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Fetch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if meta.URL != srv.URL+"/page" || meta.Title != "终点" {
+				t.Errorf("Fetch() = %+v, want final page", *meta)
+			}
+		})
+	}
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true}, // 云服务器元数据
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"2002:a00:1::", true},
+		{"8.8.8.8", false},
+		{"93.184.216.34", false},
+		{"2606:4700:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := IsBlockedIP(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("IsBlockedIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	tests := []struct {
+		address      string
+		allowPrivate bool
+		wantErr      bool
+	}{
+		{"93.184.216.34:443", false, false},
+		{"93.184.216.34:80", false, false},
+		{"93.184.216.34:8080", false, true}, // 只允许 80 和 443 端口
+		{"127.0.0.1:80", false, true},
+		{"[::1]:443", false, true},
+		{"169.254.169.254:80", false, true},
+		{"127.0.0.1:8080", true, false},
+	}
+	for _, tt := range tests {
+		f := New(Options{AllowPrivate: tt.allowPrivate})
+		err := f.control("tcp", tt.address, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("control(%s, allowPrivate=%v) error = %v, wantErr %v", tt.address, tt.allowPrivate, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFetchBlocksPrivate(t *testing.T) {
+	srv := newServer(t, "text/html", `<head><title>内网</title></head>`)
+	_, err := New(Options{}).Fetch(context.Background(), srv.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch(%s) error = %v, want ErrBlockedAddress", srv.URL, err)
+	}
+}
+
+func TestFetchBlocksRedirectToPrivate(t *testing.T) {
+	targets := []string{
+		"http://127.0.0.1/",
+		"http://10.0.0.1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+	}
+	for _, target := range targets {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, target, http.StatusFound)
+		}))
+
+		// 测试网站本身在回环地址上，用 public.test 代替一个公网网站：
+		// 只有连接 public.test 时绕过检查，重定向之后的连接仍然经过 control
+		f := New(Options{})
+		dialer := &net.Dialer{Control: f.control}
+		f.client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == "public.test:80" {
+				return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		_, err := f.Fetch(context.Background(), "http://public.test/")
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("redirect to %s: Fetch() error = %v, want ErrBlockedAddress", target, err)
+		}
+		srv.Close()
+	}
+}
@@ -9,6 +9,8 @@ package router
 // @tag.description 帖子相关接口
 // @tag.name 投票
 // @tag.description 投票相关接口
+// @tag.name 通知
+// @tag.description 站内通知相关接口
 import (
 	"net/http"
 	"time"
@@ -39,7 +41,7 @@ func Setup(mode string) *gin.Engine {
 	// Swagger 文档路由
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	r.LoadHTMLFiles("templates/index.html")
+	r.LoadHTMLFiles("templates/index.html", "templates/post.html")
 	r.Static("/static", "./static")
 
 	// 访问首页
@@ -47,7 +49,19 @@ func Setup(mode string) *gin.Engine {
 		c.HTML(http.StatusOK, "index.html", nil)
 	})
 
+	// Atom 订阅源，gin 的路径参数不能带后缀，:id 形如 1.atom
+	r.GET("/feeds/all.atom", controller.SiteAtomFeedHandler)
+	r.GET("/feeds/community/:id", controller.CommunityAtomFeedHandler)
+	r.GET("/feeds/user/:id", controller.UserAtomFeedHandler)
+
+	// 服务端渲染的帖子页面及站点地图，给爬虫和链接预览使用
+	r.GET("/p/:id", controller.PostPageHandler)
+	r.GET("/p/:id/:slug", controller.PostPageHandler)
+	r.GET("/sitemap.xml", controller.SitemapIndexHandler)
+	r.GET("/sitemaps/posts/:page", controller.SitemapHandler)
+
 	v1 := r.Group("/api/v1")
+	v1.Use(middlewares.OptionalJWTAuthMiddleware()) // 登录用户访问公开接口时返回收藏状态等个性化字段
 
 	// 注册
 	v1.POST("/signup", controller.SignUpHandler)
@@ -63,6 +77,8 @@ func Setup(mode string) *gin.Engine {
 	v1.GET("/post/:id", controller.GetPostDetailHandler)                      // 帖子详情
 	v1.GET("/post/:id/concurrent", controller.GetPostDetailConcurrentHandler) // 帖子详情（并发优化版本）
 	v1.GET("/post/:id/cached", controller.GetPostDetailCachedHandler)         // 帖子详情（缓存版本）
+	v1.GET("/post/:id/poll", controller.GetPollHandler)                       // 帖子的投票
+	v1.GET("/link/check", controller.CheckLinkHandler)                        // 检查链接是否发布过
 	v1.GET("/cache/stats", controller.GetCacheStatsHandler)                   // 缓存统计信息
 
 	// 数据库监控相关接口
@@ -70,13 +86,58 @@ func Setup(mode string) *gin.Engine {
 	v1.GET("/db/health", controller.GetDBHealthHandler)       // 数据库健康检查
 	v1.POST("/db/optimize", controller.OptimizeDBPoolHandler) // 连接池优化建议（开发环境）
 
+	v1.GET("/stream", controller.StreamHandler) // 实时事件推送（SSE/WebSocket）
+
+	v1.GET("/users/:id/followers", controller.GetFollowersHandler) // 粉丝列表
+	v1.GET("/users/:id/following", controller.GetFollowingHandler) // 关注列表
+
+	// 需要登录但不限速的读接口
+	authed := v1.Group("", middlewares.JWTAuthMiddleware())
+	{
+		authed.GET("/feed/following", controller.GetFollowingFeedHandler) // 关注动态
+		authed.POST("/stream/ticket", controller.StreamTicketHandler)     // 订阅通知的一次性凭证
+
+		authed.GET("/me/saved", controller.GetSavedPostsHandler)                   // 我的收藏
+		authed.GET("/me/saved/collections", controller.GetSavedCollectionsHandler) // 我的收藏夹
+		authed.GET("/me/votes", controller.GetMyVotesHandler)                      // 我的投票记录
+		authed.GET("/me/drafts", controller.GetMyDraftsHandler)                    // 我的草稿
+
+		authed.GET("/notifications", controller.GetNotificationsHandler)                   // 通知列表
+		authed.GET("/notifications/unread", controller.GetUnreadCountHandler)              // 未读通知数
+		authed.POST("/notifications/:id/read", controller.MarkNotificationReadHandler)     // 标记已读
+		authed.POST("/notifications/read_all", controller.MarkAllNotificationsReadHandler) // 全部已读
+		authed.GET("/notifications/settings", controller.GetNotificationSettingHandler)    // 通知偏好
+		authed.PUT("/notifications/settings", controller.UpdateNotificationSettingHandler) // 更新通知偏好
+	}
+
+	// 管理员接口
+	admin := authed.Group("/admin", middlewares.AdminMiddleware())
+	{
+		admin.GET("/post/:id/votes", controller.GetPostVotesHandler)     // 帖子的投票记录
+		admin.GET("/abuse/clusters", controller.GetAbuseClustersHandler) // 疑似刷票团伙
+		admin.POST("/redis/verify", controller.VerifyRedisHandler)       // 检查 MySQL 和 Redis 是否一致
+		admin.GET("/review/posts", controller.GetReviewPostsHandler)     // 审核队列
+		admin.POST("/post/:id/approve", controller.ApprovePostHandler)   // 审核通过
+		admin.POST("/post/:id/reject", controller.RejectPostHandler)     // 审核不通过
+	}
+
 	// v1.Use(middlewares.JWTAuthMiddleware())
 	v1.Use(middlewares.JWTAuthMiddleware(), middlewares.RateLimitMiddleware(2*time.Second, 1)) // 需要登录认证之后才能访问的接口
 	// 下面这些需要认证
 	// api 限速
 	{
-		v1.POST("/post", controller.CreatePostHandler)  // 发帖
-		v1.POST("/vote", controller.PostVoteController) // 点赞踩)
+		v1.POST("/post", controller.CreatePostHandler)               // 发帖
+		v1.PUT("/post/:id", controller.UpdatePostHandler)            // 编辑帖子（草稿自动保存）
+		v1.POST("/post/:id/publish", controller.PublishDraftHandler) // 发布草稿
+		v1.POST("/post/:id/crosspost", controller.CrosspostHandler)  // 转发到其他社区
+		v1.POST("/vote", controller.PostVoteController)              // 点赞踩)
+
+		v1.POST("/post/:id/save", controller.SavePostHandler)      // 收藏
+		v1.DELETE("/post/:id/save", controller.UnsavePostHandler)  // 取消收藏
+		v1.POST("/post/:id/poll/vote", controller.VotePollHandler) // 参与帖子的投票
+
+		v1.POST("/users/:id/follow", controller.FollowHandler)     // 关注
+		v1.DELETE("/users/:id/follow", controller.UnfollowHandler) // 取消关注
 	}
 
 	pprof.Register(r) // 注册性能分析相关的路由
@@ -83,11 +83,172 @@ CREATE TABLE `post` (
     `content` varchar(8192) COLLATE utf8mb4_general_ci NOT NULL COMMENT '内容',
     `author_id` bigint(20) NOT NULL COMMENT '作者的用户id',
     `community_id` bigint(20) NOT NULL COMMENT '所属社区',
-    `status` tinyint(4) NOT NULL DEFAULT '1' COMMENT '帖子状态',
+    `status` tinyint(4) NOT NULL DEFAULT '1' COMMENT '帖子状态 0:待审核 1:正常 2:草稿 3:定时发布 4:审核未通过',
     `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     `update_time` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    `publish_time` timestamp NULL DEFAULT NULL COMMENT '定时发布的时间',
+    `view_count` bigint(20) NOT NULL DEFAULT '0' COMMENT '浏览量，定时从Redis同步',
+    `unique_visitors` bigint(20) NOT NULL DEFAULT '0' COMMENT '独立访客数（HyperLogLog估算），定时从Redis同步',
+    `url` varchar(2048) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '链接帖子的地址（规范化后）',
+    `url_hash` char(40) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '链接的sha1，用于检测重复链接',
+    `crosspost_of` bigint(20) NOT NULL DEFAULT '0' COMMENT '转发的原帖id，0表示不是转发',
     PRIMARY KEY (`id`),
     UNIQUE KEY `idx_post_id` (`post_id`),
     KEY `idx_author_id` (`author_id`),
-    KEY `idx_community_id` (`community_id`)
-) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
\ No newline at end of file
+    KEY `idx_community_id` (`community_id`),
+    KEY `idx_status_publish_time` (`status`, `publish_time`),
+    KEY `idx_url_hash` (`url_hash`),
+    KEY `idx_crosspost_of` (`crosspost_of`, `community_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建关注关系表
+DROP TABLE IF EXISTS `follow`;
+
+CREATE TABLE `follow` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `user_id` bigint(20) NOT NULL COMMENT '被关注的用户id',
+    `follower_id` bigint(20) NOT NULL COMMENT '粉丝的用户id',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '关注时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_user_follower` (`user_id`, `follower_id`),
+    KEY `idx_follower_id` (`follower_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建通知表
+DROP TABLE IF EXISTS `notification`;
+
+CREATE TABLE `notification` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `notification_id` bigint(20) NOT NULL COMMENT '通知id',
+    `user_id` bigint(20) NOT NULL COMMENT '接收通知的用户id',
+    `actor_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '触发通知的用户id',
+    `type` tinyint(4) NOT NULL COMMENT '通知类型 2提及 3投票里程碑',
+    `post_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '关联的帖子id',
+    `content` varchar(256) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '通知内容',
+    `is_read` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否已读',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_notification_id` (`notification_id`),
+    KEY `idx_user_notification` (`user_id`, `notification_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建通知设置表
+DROP TABLE IF EXISTS `notification_setting`;
+
+CREATE TABLE `notification_setting` (
+    `user_id` bigint(20) NOT NULL COMMENT '用户id',
+    `mention` tinyint(4) NOT NULL DEFAULT '1' COMMENT '接收提及通知',
+    `vote` tinyint(4) NOT NULL DEFAULT '1' COMMENT '接收投票里程碑通知',
+    `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`user_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建收藏表
+DROP TABLE IF EXISTS `post_save`;
+
+CREATE TABLE `post_save` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `user_id` bigint(20) NOT NULL COMMENT '收藏的用户id',
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `collection` varchar(64) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '收藏夹名称，空表示默认收藏夹',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '收藏时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_user_post` (`user_id`, `post_id`),
+    KEY `idx_user_collection` (`user_id`, `collection`, `create_time`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建投票记录表，只追加不修改
+DROP TABLE IF EXISTS `vote_log`;
+
+CREATE TABLE `vote_log` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `user_id` bigint(20) NOT NULL COMMENT '投票的用户id',
+    `direction` tinyint(4) NOT NULL COMMENT '本次投票 1:赞成 -1:反对 0:取消',
+    `old_direction` tinyint(4) NOT NULL DEFAULT '0' COMMENT '投票前的状态',
+    `weight` decimal(4,2) NOT NULL DEFAULT '1.00' COMMENT '投票权重，由刷票检测按账号可信度计算',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '投票时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`, `id`),
+    KEY `idx_post_id` (`post_id`, `id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建帖子当前投票表，投票后异步写入，Redis 数据丢失后用于重建排行榜
+-- 已有数据升级时可以用投票记录初始化：
+-- insert into post_vote(post_id, user_id, direction, weight)
+-- select v.post_id, v.user_id, v.direction, v.weight from vote_log v
+-- join (select max(id) as id from vote_log group by post_id, user_id) l on l.id = v.id;
+DROP TABLE IF EXISTS `post_vote`;
+
+CREATE TABLE `post_vote` (
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `user_id` bigint(20) NOT NULL COMMENT '投票的用户id',
+    `direction` tinyint(4) NOT NULL COMMENT '当前的投票 1:赞成 -1:反对 0:已取消',
+    `weight` decimal(4,2) NOT NULL DEFAULT '1.00' COMMENT '投票权重',
+    `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后一次投票的时间',
+    PRIMARY KEY (`post_id`, `user_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建帖子投票表
+DROP TABLE IF EXISTS `poll`;
+
+CREATE TABLE `poll` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `multiple` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否多选',
+    `close_time` timestamp NULL DEFAULT NULL COMMENT '截止时间，为空表示不截止',
+    `closed` tinyint(1) NOT NULL DEFAULT '0' COMMENT '是否已截止并保存了最终结果',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_post_id` (`post_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建投票选项表
+DROP TABLE IF EXISTS `poll_option`;
+
+CREATE TABLE `poll_option` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `post_id` bigint(20) NOT NULL COMMENT '帖子id',
+    `idx` tinyint(4) NOT NULL COMMENT '选项序号，从0开始',
+    `text` varchar(128) COLLATE utf8mb4_general_ci NOT NULL COMMENT '选项内容',
+    `votes` bigint(20) NOT NULL DEFAULT '0' COMMENT '最终票数，截止后写入',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_post_option` (`post_id`, `idx`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建链接预览表，同一个链接的帖子共用一条预览
+DROP TABLE IF EXISTS `link_preview`;
+
+CREATE TABLE `link_preview` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `url_hash` char(40) COLLATE utf8mb4_general_ci NOT NULL COMMENT '链接的sha1',
+    `url` varchar(2048) COLLATE utf8mb4_general_ci NOT NULL COMMENT '链接地址',
+    `status` tinyint(4) NOT NULL DEFAULT '0' COMMENT '抓取状态 0:待抓取 1:成功 2:失败',
+    `title` varchar(256) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '网页标题',
+    `description` varchar(512) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '网页描述',
+    `image` varchar(2048) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '预览图地址',
+    `site_name` varchar(128) COLLATE utf8mb4_general_ci NOT NULL DEFAULT '' COMMENT '网站名称',
+    `fail_count` int(11) NOT NULL DEFAULT '0' COMMENT '连续抓取失败的次数',
+    `fetch_time` timestamp NULL DEFAULT NULL COMMENT '最近一次抓取成功的时间',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_url_hash` (`url_hash`),
+    KEY `idx_status_update_time` (`status`, `update_time`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
+
+-- 创建社区发帖规则表，没有记录的社区使用默认规则（不做限制）
+DROP TABLE IF EXISTS `community_rule`;
+
+CREATE TABLE `community_rule` (
+    `id` bigint(20) NOT NULL AUTO_INCREMENT,
+    `community_id` int(10) unsigned NOT NULL COMMENT '社区id',
+    `allow_crosspost` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否接受从其他社区转发的帖子',
+    `allow_link` tinyint(1) NOT NULL DEFAULT '1' COMMENT '是否允许发布链接帖子',
+    `min_account_days` int(11) NOT NULL DEFAULT '0' COMMENT '注册满多少天才能发帖',
+    `min_karma` int(11) NOT NULL DEFAULT '0' COMMENT '声望达到多少才能发帖',
+    `create_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `update_time` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_community_id` (`community_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_general_ci;
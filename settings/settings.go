@@ -2,6 +2,7 @@ package settings
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -10,6 +11,11 @@ import (
 var Conf = new(AppConfig)
 var defaultFilePath = "./conf/config.yaml"
 
+var (
+	hooksMu     sync.Mutex
+	changeHooks []func()
+)
+
 type AppConfig struct {
 	Name      string `mapstructure:"name"`
 	Mode      string `mapstructure:"mode"`
@@ -18,9 +24,27 @@ type AppConfig struct {
 	MachineID int64  `mapstructure:"machine_id"`
 	Port      int    `mapstructure:"port"`
 
-	*LogConfig   `mapstructure:"log"`
-	*MySQLConfig `mapstructure:"mysql"`
-	*RedisConfig `mapstructure:"redis"`
+	*AuthConfig    `mapstructure:"auth"`
+	*LogConfig     `mapstructure:"log"`
+	*MySQLConfig   `mapstructure:"mysql"`
+	*RedisConfig   `mapstructure:"redis"`
+	*FilterConfig  `mapstructure:"filter"`
+	*FeedConfig    `mapstructure:"feed"`
+	*StreamConfig  `mapstructure:"stream"`
+	*AbuseConfig   `mapstructure:"abuse"`
+	*ViewConfig    `mapstructure:"view"`
+	*SiteConfig    `mapstructure:"site"`
+	*LinkConfig    `mapstructure:"link"`
+	*CacheConfig   `mapstructure:"cache"`
+	*BloomConfig   `mapstructure:"bloom"`
+	*WarmUpConfig  `mapstructure:"warmup"`
+	*DegradeConfig `mapstructure:"degrade"`
+}
+
+// AuthConfig 认证及权限配置
+type AuthConfig struct {
+	JWTExpire int     `mapstructure:"jwt_expire"` // Token过期时间(小时)
+	AdminIDs  []int64 `mapstructure:"admin_ids"`  // 管理员用户id
 }
 
 type MySQLConfig struct {
@@ -38,12 +62,117 @@ type MySQLConfig struct {
 }
 
 type RedisConfig struct {
+	Mode         string `mapstructure:"mode"` // standalone(默认) / sentinel / cluster
 	Host         string `mapstructure:"host"`
 	Password     string `mapstructure:"password"`
 	Port         int    `mapstructure:"port"`
 	DB           int    `mapstructure:"db"`
 	PoolSize     int    `mapstructure:"pool_size"`
 	MinIdleConns int    `mapstructure:"min_idle_conns"`
+
+	MasterName    string   `mapstructure:"master_name"`    // 哨兵模式下主节点的名称
+	SentinelAddrs []string `mapstructure:"sentinel_addrs"` // 哨兵模式下哨兵的地址 host:port
+	ClusterAddrs  []string `mapstructure:"cluster_addrs"`  // 集群模式下的节点地址 host:port，不需要列出所有节点
+
+	BreakerFailures int `mapstructure:"breaker_failures"` // 连续多少次连接失败后熔断，熔断期间不再访问 Redis
+	BreakerTimeout  int `mapstructure:"breaker_timeout"`  // 熔断多久后(秒)尝试恢复
+}
+
+// FilterConfig 内容过滤配置
+type FilterConfig struct {
+	WordFile        string `mapstructure:"word_file"`        // 敏感词库文件
+	MaxLinks        int    `mapstructure:"max_links"`        // 单帖最多允许的链接数，超过进入审核
+	DuplicateWindow int    `mapstructure:"duplicate_window"` // 重复内容检测窗口(分钟)
+	VelocityWindow  int    `mapstructure:"velocity_window"`  // 发帖频率统计窗口(秒)
+	VelocityLimit   int    `mapstructure:"velocity_limit"`   // 窗口内最多允许的发帖数
+}
+
+// FeedConfig 关注动态配置
+type FeedConfig struct {
+	FanoutThreshold int64 `mapstructure:"fanout_threshold"` // 粉丝数超过该值的作者发帖不再推送到粉丝收件箱
+	InboxSize       int64 `mapstructure:"inbox_size"`       // 每个用户收件箱保留的帖子数
+}
+
+// StreamConfig 实时推送配置
+type StreamConfig struct {
+	HeartbeatInterval int `mapstructure:"heartbeat_interval"` // 心跳间隔(秒)
+	BufferSize        int `mapstructure:"buffer_size"`        // 每个连接的事件缓冲区大小
+	MaxDropped        int `mapstructure:"max_dropped"`        // 连续丢弃超过该数量的事件时断开连接
+	TicketTTL         int `mapstructure:"ticket_ttl"`         // 订阅通知的一次性凭证的有效期(秒)
+}
+
+// AbuseConfig 刷票检测配置
+type AbuseConfig struct {
+	Enable         bool    `mapstructure:"enable"`           // 是否按账号可信度给投票加权
+	NewAccountDays int     `mapstructure:"new_account_days"` // 注册不满该天数的账号投票降权
+	MinKarma       float64 `mapstructure:"min_karma"`        // 新注册账号的声望低于该值时投票再降权
+	RingMinCoVotes int64   `mapstructure:"ring_min_covotes"` // 两个账号至少共同投票多少次才判断是否结团
+	RingRatio      float64 `mapstructure:"ring_ratio"`       // 共同投票数占双方投票数的比例都超过该值视为结团
+	RecentVoters   int64   `mapstructure:"recent_voters"`    // 每个帖子保留最近多少个投票人用于统计共同投票
+}
+
+// ViewConfig 浏览量统计配置
+type ViewConfig struct {
+	FlushInterval   int `mapstructure:"flush_interval"`   // 本机缓冲的浏览记录写入Redis的间隔(秒)
+	PersistInterval int `mapstructure:"persist_interval"` // Redis中的浏览量同步到MySQL的间隔(秒)
+}
+
+// SiteConfig 站点对外访问的配置，用于生成订阅源等绝对地址
+type SiteConfig struct {
+	BaseURL     string `mapstructure:"base_url"`     // 站点地址，如 https://bluebell.example.com，必须配置
+	Title       string `mapstructure:"title"`        // 站点名称
+	FeedSize    int64  `mapstructure:"feed_size"`    // 订阅源返回的帖子数
+	FeedMaxAge  int    `mapstructure:"feed_max_age"` // 订阅源、站点地图允许客户端缓存的时间(秒)
+	SitemapSize int64  `mapstructure:"sitemap_size"` // 每页站点地图包含的帖子数，不能超过50000
+}
+
+// LinkConfig 链接帖子预览信息的抓取配置
+type LinkConfig struct {
+	Workers       int   `mapstructure:"workers"`        // 同时抓取的网页数
+	QueueSize     int   `mapstructure:"queue_size"`     // 等待抓取的队列长度，队列满时由定时任务补抓
+	Timeout       int   `mapstructure:"timeout"`        // 抓取单个网页的超时时间(秒)
+	MaxBytes      int64 `mapstructure:"max_bytes"`      // 单个网页最多读取的字节数
+	RefreshHours  int   `mapstructure:"refresh_hours"`  // 预览信息超过该时间后再次被发布时重新抓取(小时)
+	MaxFailures   int   `mapstructure:"max_failures"`   // 连续失败超过该次数后不再重试
+	SweepInterval int   `mapstructure:"sweep_interval"` // 检查漏抓和失败重试的间隔(秒)
+	AllowPrivate  bool  `mapstructure:"allow_private"`  // 允许抓取内网地址，只在本地开发时打开
+}
+
+// CacheConfig 进程内一级缓存配置，大小为 0 表示不使用一级缓存
+type CacheConfig struct {
+	LocalPostSize      int `mapstructure:"local_post_size"`      // 一级缓存最多保存的帖子详情数
+	LocalPostTTL       int `mapstructure:"local_post_ttl"`       // 帖子详情在一级缓存中的过期时间(秒)
+	LocalUserSize      int `mapstructure:"local_user_size"`      // 一级缓存最多保存的用户数
+	LocalUserTTL       int `mapstructure:"local_user_ttl"`       // 用户在一级缓存中的过期时间(秒)
+	LocalCommunitySize int `mapstructure:"local_community_size"` // 一级缓存最多保存的社区数
+	LocalCommunityTTL  int `mapstructure:"local_community_ttl"`  // 社区在一级缓存中的过期时间(秒)
+
+	PostListStaleWhileRevalidate bool `mapstructure:"post_list_stale_while_revalidate"` // 帖子列表有变化时先返回旧的分页，后台重新生成
+	PostListStaleTTL             int  `mapstructure:"post_list_stale_ttl"`              // 旧的分页最多保留多久(秒)
+}
+
+// BloomConfig 布隆过滤器配置，预计数量或误判率变化后启动时会自动重建
+type BloomConfig struct {
+	Enable              bool    `mapstructure:"enable"`               // 是否用布隆过滤器拦截不存在的id
+	ExpectedPosts       uint64  `mapstructure:"expected_posts"`       // 预计的帖子数
+	ExpectedUsers       uint64  `mapstructure:"expected_users"`       // 预计的用户数
+	ExpectedCommunities uint64  `mapstructure:"expected_communities"` // 预计的社区数
+	FalsePositiveRate   float64 `mapstructure:"false_positive_rate"`  // 期望的误判率
+}
+
+// WarmUpConfig 缓存预热配置
+type WarmUpConfig struct {
+	Enable        bool  `mapstructure:"enable"`         // 是否在启动时和定时预热缓存
+	PostSize      int64 `mapstructure:"post_size"`      // 按热度和浏览量各预热前多少篇帖子
+	Interval      int   `mapstructure:"interval"`       // 定时预热的间隔(秒)，0 表示只在启动时预热
+	BatchSize     int   `mapstructure:"batch_size"`     // 每批查询数据库的数量
+	BatchInterval int   `mapstructure:"batch_interval"` // 每批之间的间隔(毫秒)，避免预热时数据库压力过大
+}
+
+// DegradeConfig Redis 不可用时的降级配置
+type DegradeConfig struct {
+	VoteQueueFile  string `mapstructure:"vote_queue_file"` // 投票本地队列的文件
+	ReplayInterval int    `mapstructure:"replay_interval"` // 重放投票的间隔(秒)
 }
 
 type LogConfig struct {
@@ -94,7 +223,21 @@ func Init(filePath string) (err error) {
 		fmt.Println("配置文件修改了...")
 		if err := viper.Unmarshal(Conf); err != nil {
 			fmt.Printf("viper.Unmarshal failed, err:%v\n", err)
+			return
+		}
+		hooksMu.Lock()
+		hooks := append([]func(){}, changeHooks...)
+		hooksMu.Unlock()
+		for _, fn := range hooks {
+			fn()
 		}
 	})
 	return
 }
+
+// OnChange 注册配置文件变更后的回调，回调在 Conf 更新完成之后执行
+func OnChange(fn func()) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	changeHooks = append(changeHooks, fn)
+}